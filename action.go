@@ -1,9 +1,14 @@
 package profile
 
 import (
+	"context"
+	"fmt"
 	"io"
-	"log"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -12,8 +17,49 @@ type BaseAction struct {
 	*scheduler
 	*targeter
 	*signaler
-	fn      func(io.Writer) error
-	lastErr error
+	fn         func(io.Writer) error
+	ctxFn      func(context.Context, io.Writer) error
+	timeout    time.Duration
+	name       string
+	ext        string
+	triggers   []trigger
+	cooldown   time.Duration
+	burst      int
+	burstGap   time.Duration
+	skipBusy   bool
+	asyncQueue int
+	onError    func(error)
+	onSuccess  func(RunInfo)
+	maxFails   int
+	statePath  string
+	jsonLines  bool
+	speedscope bool
+	lockDir    string
+	limiter    *rateLimiter
+	pauser     *pauser
+	labels     map[string]string
+	bus        *Bus
+	statsd     *StatsD
+	etw        *ETW
+	reporter   ErrorReporter
+	manifest   *manifestFile
+	alertURL   string
+	middleware []Middleware
+	lastErr    error
+
+	// Runtime state, guarded by mu. The configuration above is frozen
+	// when the action starts, so changing it while the action is
+	// running only takes effect once it's restarted.
+	mu      sync.Mutex
+	running bool
+	active  []trigger
+	runner  *runner
+	state   *stateFile
+	skipped uint64
+	runs    uint64
+	stats   runStats
+	// paused is set by Pause, independently of the Swat's pauser.
+	paused pauser
 }
 
 var _ Action = &BaseAction{}
@@ -29,16 +75,92 @@ var _ Action = &BaseAction{}
 // The Signaller is activated by calling OnSignal, and will trigger
 // an action to be run when a process gets a POSIX signal.
 //
+// Conditions are activated by calling When, and will trigger an
+// action to be run whenever an application-defined predicate holds.
+//
 // The output of the action can be sent to a writer. You can specify
 // a writer using ToWriter, and there's a shortcut for specifying
 // a file output using ToFile.
+//
+// Runs of an action never overlap: when several triggers fire at
+// once, such as a signal arriving during a scheduled run, they take
+// turns, so output written to a shared file won't be interleaved.
 func NewAction(fn func(io.Writer) error) *BaseAction {
 	return &BaseAction{
 		scheduler: new(scheduler),
 		targeter:  new(targeter),
 		signaler:  new(signaler),
 		fn:        fn,
+		ext:       "out",
+	}
+}
+
+// `Name` names the action, for use in logs and descriptions.
+func (b *BaseAction) Name(name string) *BaseAction {
+	b.name = name
+	return b
+}
+
+// Returns the name of the action, or "action" if it has none.
+func (b *BaseAction) displayName() string {
+	if b.name == "" {
+		return "action"
+	}
+
+	return b.name
+}
+
+// Returns the name given with Name, if any.
+func (b *BaseAction) actionName() string {
+	return b.name
+}
+
+// Returns the extension of the action's output files.
+func (b *BaseAction) outputExt() string {
+	if b.jsonLines {
+		return "jsonl"
 	}
+
+	return b.contentExt()
+}
+
+// Returns the extension of the action's output, once converted by
+// Speedscope but before any JSONLines envelope.
+func (b *BaseAction) contentExt() string {
+	if b.speedscope {
+		return "speedscope.json"
+	}
+
+	return b.ext
+}
+
+// Returns a summary of the action, its triggers, and its output,
+// such as "heap every 5m0s for 1h0m0s → /var/dumps/heap.pprof".
+func (b *BaseAction) Describe() string {
+	parts := []string{b.displayName()}
+	if b.scheduler.isActivated() {
+		parts = append(parts, b.scheduler.String())
+	}
+	if len(b.signals) > 0 {
+		parts = append(parts, b.signaler.String())
+	}
+	for _, t := range b.triggers {
+		parts = append(parts, t.String())
+	}
+	if b.burst > 1 {
+		parts = append(parts, fmt.Sprintf("in bursts of %d (%s apart)", b.burst, b.burstGap))
+	}
+	if b.cooldown > 0 {
+		parts = append(parts, "with cooldown "+b.cooldown.String())
+	}
+
+	vars := pathVars{Name: b.displayName(), Ext: b.outputExt(), Time: "*"}
+	return strings.Join(parts, " ") + " → " + b.targeter.describe(vars)
+}
+
+// Implements fmt.Stringer, see Describe.
+func (b *BaseAction) String() string {
+	return b.Describe()
 }
 
 // `After` starts something after a given duration. Cannot be used
@@ -65,6 +187,48 @@ func (b *BaseAction) Every(every time.Duration) *BaseAction {
 	return b
 }
 
+// `Splayed` offsets the first run by a deterministic hash of the
+// seed, within the `Every` interval, so that a fleet of replicas
+// doesn't take its dumps all at once. An empty seed uses the
+// hostname, which is the pod name on Kubernetes. Requires `Every`.
+func (b *BaseAction) Splayed(seed string) *BaseAction {
+	b.scheduler.Splayed(seed)
+	return b
+}
+
+// `WithProbability` makes each scheduled run go ahead only with
+// probability p, between 0 and 1, so that a very large fleet can keep
+// its total volume of profiles manageable while every instance is
+// configured identically. With `Every(time.Hour).WithProbability(0.01)`,
+// a fleet of a thousand instances takes about ten profiles an hour.
+// Triggers other than the scheduler aren't affected.
+func (b *BaseAction) WithProbability(p float64) *BaseAction {
+	if b.lastErr == nil && !(p > 0 && p <= 1) {
+		b.lastErr = fmt.Errorf("%w: 'WithProbability' requires a probability above 0 and at most 1", ErrInvalidOption)
+	}
+
+	b.scheduler.WithProbability(p)
+	return b
+}
+
+// `ExceptBetween` suppresses scheduled runs between the start and
+// end times, such as during a known peak in traffic. Runs resume
+// on schedule afterwards. Triggers other than the scheduler, like
+// signals, are not affected.
+func (b *BaseAction) ExceptBetween(start, end time.Time) *BaseAction {
+	b.scheduler.ExceptBetween(start, end)
+	return b
+}
+
+// `ExceptDaily` suppresses scheduled runs every day between two
+// offsets from local midnight. For example, `ExceptDaily(9*time.Hour,
+// 17*time.Hour)` skips runs during business hours. If `to` is before
+// `from`, the window spans midnight.
+func (b *BaseAction) ExceptDaily(from, to time.Duration) *BaseAction {
+	b.scheduler.ExceptDaily(from, to)
+	return b
+}
+
 // `For` specifies how long `Every` runs. annot be
 // used with `Until`. Omitting both `For` and `Every` cause
 // the event to run for an infinite time.
@@ -81,29 +245,359 @@ func (b *BaseAction) Until(until time.Time) *BaseAction {
 	return b
 }
 
-// Used to run an action when an OS signal is received.
+// Used to run an action when an OS signal is received. WebAssembly
+// hosts don't deliver signals, so there Start returns ErrUnsupported.
 func (b *BaseAction) OnSignal(signals ...os.Signal) *BaseAction {
 	b.signaler.OnSignal(signals...)
 	return b
 }
 
+// `ForwardSignal` passes signals which trigger the action on to the
+// processes returned by `children`, such as exec'd workers, so that
+// one SIGUSR1 produces dumps from the whole process tree. It's called
+// each time a signal arrives, so the set of children may change.
+func (b *BaseAction) ForwardSignal(children func() []*os.Process) *BaseAction {
+	b.signaler.forward = children
+	return b
+}
+
+// `OnInfo` runs the action when the user presses Ctrl+T, which sends
+// SIGINFO on macOS and the BSDs. It's the idiomatic way to ask an
+// interactive program what it's doing there. On other platforms it
+// does nothing, so it's safe to use unconditionally.
+func (b *BaseAction) OnInfo() *BaseAction {
+	if SIGINFO != nil {
+		signals := b.signaler.signals
+		b.signaler.signals = append(signals[:len(signals):len(signals)], SIGINFO)
+	}
+
+	return b
+}
+
+// `When` runs the action whenever `pred` returns true, checking it
+// once every `poll` interval. This can be used to fire dumps on
+// arbitrary internal state, such as queue depths or error counters.
+func (b *BaseAction) When(pred func() bool, poll time.Duration) *BaseAction {
+	if b.lastErr == nil && poll <= 0 {
+		b.lastErr = fmt.Errorf("%w: 'When' requires a positive poll interval", ErrInvalidOption)
+	}
+
+	b.triggers = append(b.triggers, newPoller(pred, poll))
+	return b
+}
+
+// `AfterEveryNGCs` runs the action right after every nth garbage
+// collection, which is when heap profiles best reflect live data.
+func (b *BaseAction) AfterEveryNGCs(n int) *BaseAction {
+	if b.lastErr == nil && n <= 0 {
+		b.lastErr = fmt.Errorf("%w: 'AfterEveryNGCs' requires a positive count", ErrInvalidOption)
+	}
+
+	b.triggers = append(b.triggers, newGCWatcher(n))
+	return b
+}
+
+// `OnChannel` runs the action every time a value is received on
+// the channel, so that application code can fire a configured dump
+// programmatically, for example from an error-handling path.
+func (b *BaseAction) OnChannel(ch <-chan struct{}) *BaseAction {
+	b.triggers = append(b.triggers, newChannelTrigger(ch))
+	return b
+}
+
+// `OnUDP` runs the action when a UDP packet containing the magic
+// payload is received on the address, like ":7946". This provides
+// a network-reachable trigger for processes that can't easily be
+// signaled, such as those behind orchestration layers.
+func (b *BaseAction) OnUDP(addr string, magic []byte) *BaseAction {
+	b.triggers = append(b.triggers, newUDPTrigger(addr, magic))
+	return b
+}
+
+// `OnPipeCommand` runs the action when the command is written, as a
+// line, to the process's named pipe, PipeName, as with
+// `echo heap > \\.\pipe\swat-1234`. It gives Windows services, which
+// can't be sent signals, an equivalent to OnSignal. Actions share the
+// pipe, and several may listen for the same command. It's only
+// supported on Windows; elsewhere Start returns ErrUnsupported.
+func (b *BaseAction) OnPipeCommand(command string) *BaseAction {
+	if b.lastErr == nil && (command == "" || strings.TrimSpace(command) != command) {
+		b.lastErr = fmt.Errorf("%w: 'OnPipeCommand' requires a non-empty command without surrounding space", ErrInvalidOption)
+	}
+
+	b.triggers = append(b.triggers, newPipeTrigger(command))
+	return b
+}
+
+// `Cooldown` limits signal and condition triggers to running the
+// action at most once per period, so that a persistently breached
+// condition doesn't produce a storm of dumps. Scheduled runs are
+// not affected. Runs dropped during the cooldown are counted by
+// Skipped. The period is measured by the action's Clock.
+func (b *BaseAction) Cooldown(period time.Duration) *BaseAction {
+	b.cooldown = period
+	return b
+}
+
+// `SkipIfBusy` makes triggers skip a run, rather than wait, when the
+// previous run is still in progress. This keeps runs from queuing
+// up behind a sink that can't keep up, such as a slow disk or
+// network upload. Skipped runs are counted, see Skipped.
+func (b *BaseAction) SkipIfBusy() *BaseAction {
+	b.skipBusy = true
+	return b
+}
+
+// `Async` makes the action capture each dump into memory, and hand
+// it to a background goroutine to be written out. This minimizes the
+// time spent collecting the dump, at the cost of holding it in
+// memory. At most `queue` dumps wait to be written; runs are skipped
+// while the queue is full.
+func (b *BaseAction) Async(queue int) *BaseAction {
+	if b.lastErr == nil && queue <= 0 {
+		b.lastErr = fmt.Errorf("%w: 'Async' requires a positive queue size", ErrInvalidOption)
+	}
+
+	b.asyncQueue = queue
+	return b
+}
+
+// `OnError` calls the function, instead of logging, whenever a run
+// fails. It may be called concurrently from several goroutines.
+func (b *BaseAction) OnError(fn func(error)) *BaseAction {
+	b.onError = fn
+	return b
+}
+
+// `OnSuccess` calls the function after every successful run, with
+// details of the run, such as how long it took and where its output
+// was written. It may be called concurrently from several goroutines.
+func (b *BaseAction) OnSuccess(fn func(RunInfo)) *BaseAction {
+	b.onSuccess = fn
+	return b
+}
+
+// `StopAfterFailures` disables the action after n consecutive failed
+// runs, so that a persistently broken output doesn't log the same
+// error every interval forever. A final error wrapping
+// ErrTooManyFailures is reported when this happens. Runs whose output
+// was written to the fallback given to ToWithFallback don't count as
+// failures here, though their errors are still reported. Manual
+// triggers still run, and restarting the action enables it again.
+func (b *BaseAction) StopAfterFailures(n int) *BaseAction {
+	if b.lastErr == nil && n <= 0 {
+		b.lastErr = fmt.Errorf("%w: 'StopAfterFailures' requires a positive count", ErrInvalidOption)
+	}
+
+	b.maxFails = n
+	return b
+}
+
+// Returns the number of runs that have been skipped, either because
+// a previous run was still in progress, the async write queue was
+// full, the Swat's rate limit was reached, or the cooldown hadn't
+// passed.
+func (b *BaseAction) Skipped() uint64 {
+	return atomic.LoadUint64(&b.skipped)
+}
+
+// `PersistState` saves the action's run count, last run, and `For`
+// deadline to a small JSON file, and resumes from it when started.
+// This lets a campaign like "every 10m for 6h" survive a restart of
+// the process, rather than silently starting its 6 hours over. Remove
+// the file to start the campaign afresh.
+func (b *BaseAction) PersistState(path string) *BaseAction {
+	b.statePath = path
+	return b
+}
+
+// `Coordinate` elects a single process on the host to run the action,
+// for when several processes share a dump directory. Each process
+// tries to take an flock on "<name>.lock" in the directory before
+// running; whichever holds it runs the action until it ends or exits,
+// and the others skip their runs, counting them in Skipped. Manual
+// triggers always run. The action must be named, so that actions
+// don't share a lock; Start returns ErrInvalidOption otherwise, and
+// ErrUnsupported on platforms without flock.
+func (b *BaseAction) Coordinate(dir string) *BaseAction {
+	b.lockDir = dir
+	return b
+}
+
+// Returns when the scheduler will next run the action, or the zero
+// time if the action isn't running or has no more scheduled runs.
+// Runs may still be skipped, for example during blackout windows.
+func (b *BaseAction) NextRun() time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.running {
+		return time.Time{}
+	}
+
+	return b.scheduler.nextRun()
+}
+
+// Returns when the action last ran, whatever triggered it, and the
+// error that run produced. The time is zero if it has never run.
+func (b *BaseAction) LastRun() (time.Time, error) {
+	return b.stats.last()
+}
+
+// Returns a summary of every run of the action so far: how many there
+// were, how many failed, how much they wrote, and where.
+func (b *BaseAction) Summary() ActionSummary {
+	sum := b.stats.summary()
+	sum.Action = b.Describe()
+	return sum
+}
+
+// Returns the state of the action, and the time and bytes its runs
+// have taken so far. The same figures are emitted for each run by
+// EmitStatsD.
+func (b *BaseAction) Status() ActionStatus {
+	status := b.stats.status()
+	status.Skipped = b.Skipped()
+
+	b.mu.Lock()
+	status.Running = b.running
+	if b.running {
+		status.NextRun = b.scheduler.nextRun()
+	}
+	b.mu.Unlock()
+
+	return status
+}
+
 // Writes the output of the action to the writer.
 func (b *BaseAction) ToWriter(w io.Writer) *BaseAction {
 	b.targeter.ToWriter(w)
 	return b
 }
 
-// Writes the output of the action to the writer.
+// `ToWithFallback` writes the output of each run to the primary
+// sink, or, if that fails, to the fallback, such as WriterSink of
+// os.Stderr or DirSink of a local directory, so that a full disk or
+// an unreachable endpoint doesn't drop the dump. The primary's error
+// is still reported, noting the fallback, but such runs don't count
+// toward StopAfterFailures.
+func (b *BaseAction) ToWithFallback(primary, fallback Sink) *BaseAction {
+	b.targeter.To(fallbackSink{primary, fallback})
+	return b
+}
+
+// `Speedscope` converts the output of the action, which must be a
+// pprof profile such as DumpPProfLookup("goroutine", 0) writes, into
+// speedscope's JSON format as it's written, so that dumps can be
+// opened directly at https://www.speedscope.app without the pprof
+// toolchain. Files written by the action get the extension
+// "speedscope.json".
+func (b *BaseAction) Speedscope() *BaseAction {
+	b.speedscope = true
+	return b
+}
+
+// `JSONLines` wraps the output of each run in a JSON object on a
+// single line, holding the action's name and extension, the host
+// name, and the time, along with the output itself: inline if it's
+// text, or base64 encoded if it's binary. Multi-line text dumps can
+// then flow through log shippers like Fluent Bit without multi-line
+// parsing. Files written by the action get the extension "jsonl".
+func (b *BaseAction) JSONLines() *BaseAction {
+	b.jsonLines = true
+	return b
+}
+
+// `To` writes the output of each run to a writer opened from the
+// sink, such as a Ring.
+func (b *BaseAction) To(s Sink) *BaseAction {
+	if v, ok := s.(invalidSink); ok && v.invalid() != nil {
+		b.lastErr = v.invalid()
+	}
+
+	b.targeter.To(s)
+	return b
+}
+
+// `ToPipe` writes the output of the action into a pipe, and returns
+// its read side, so that a consumer in the same process, such as a
+// websocket streamer, can process dumps live rather than from disk.
+// Runs block until their output has been read, so the reader must
+// keep up, or the action should be made Async. Ending the action
+// closes the pipe, and the reader sees EOF.
+func (b *BaseAction) ToPipe() io.Reader {
+	return b.targeter.ToPipe()
+}
+
+// Writes the output of the action to the file, which is created
+// when the action starts.
 func (b *BaseAction) ToFile(f string) *BaseAction {
-	if b.lastErr == nil {
-		b.lastErr = b.targeter.ToFile(f)
+	b.targeter.ToFile(f)
+	return b
+}
+
+// Writes the output of each run to a new file, whose path is given
+// by a text/template. The template can use {{.Name}}, the name of
+// the action, {{.Time}}, the time of the run, {{.Ext}}, the
+// extension of the output, {{.Index}}, the number of the run, and
+// {{.Source}}, why it happened, as in RunInfo. If the file exists, a
+// numeric suffix is added to the name.
+func (b *BaseAction) ToFileTemplate(pattern string) *BaseAction {
+	if err := b.targeter.ToFileTemplate(pattern); err != nil && b.lastErr == nil {
+		b.lastErr = err
 	}
 
 	return b
 }
 
-// Implements Action.Start
+// `HashNames` names the files written by ToDir and ToFileTemplate
+// after the SHA-256 of their contents, as in "heap-<hash>.pb.gz" in
+// the directory the template gives. Identical dumps are kept only
+// once, so collections synced between machines deduplicate.
+func (b *BaseAction) HashNames() *BaseAction {
+	b.targeter.hashNames = true
+	return b
+}
+
+// `Checksums` writes a sidecar next to each file written by ToDir and
+// ToFileTemplate, named after it with ".sha256" appended, holding its
+// SHA-256 in the format read by `sha256sum -c`.
+func (b *BaseAction) Checksums() *BaseAction {
+	b.targeter.sidecars = true
+	return b
+}
+
+// `Synced` fsyncs files written by ToFile, ToDir, and ToFileTemplate
+// after each run, so that a dump taken moments before the process or
+// its node dies isn't lost with the page cache. Runs fail if the sync
+// does. Sinks decide their own durability.
+func (b *BaseAction) Synced() *BaseAction {
+	b.targeter.synced = true
+	return b
+}
+
+// Writes the output of each run to a new file in the directory,
+// named like "heap-2006-01-02T15-04-05.000.txt". The directory is
+// created if needed.
+func (b *BaseAction) ToDir(dir string) *BaseAction {
+	if err := b.targeter.ToDir(dir); err != nil && b.lastErr == nil {
+		b.lastErr = err
+	}
+
+	return b
+}
+
+// Implements Action.Start. Starting an action which is already
+// running returns ErrAlreadyStarted. Once ended, an action may be
+// started again.
 func (b *BaseAction) Start() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.running {
+		return ErrAlreadyStarted
+	}
+
 	if b.lastErr != nil {
 		return b.lastErr
 	}
@@ -112,26 +606,320 @@ func (b *BaseAction) Start() error {
 		return err
 	}
 
-	fn := func() {
-		if err := b.fn(b.writer); err != nil {
-			log.Printf("Swat Error: %s", err)
+	if b.lockDir != "" && b.name == "" {
+		return fmt.Errorf("%w: 'Coordinate' requires a name for the action", ErrInvalidOption)
+	}
+
+	if b.lockDir != "" && !flockSupported {
+		return ErrUnsupported
+	}
+
+	b.state = nil
+	if b.statePath != "" {
+		state, err := loadStateFile(b.statePath)
+		if err != nil {
+			return err
 		}
+
+		b.state = state
+	}
+	b.scheduler.resume(b.state)
+
+	if err := b.targeter.start(); err != nil {
+		return err
+	}
+
+	r := b.newRunner()
+
+	// Signals and conditions share a cooldown, but the schedule isn't
+	// subject to it.
+	var c *cooldown
+	if b.cooldown > 0 {
+		c = newCooldown(b.cooldown)
+		c.clock, c.skipped = b.scheduler.getClock(), &b.skipped
+	}
+
+	if err := b.scheduler.start(b.wrapRun(r.runFor(SourceSchedule), false, nil)); err != nil {
+		r.end()
+		b.targeter.end()
+		return err
 	}
 
-	b.scheduler.fn = fn
-	b.signaler.fn = fn
+	started := []trigger{b.scheduler}
+	for _, t := range append([]trigger{b.signaler}, b.triggers...) {
+		run := r.runFor(sourceOf(t))
+		if _, ok := t.(*poller); ok {
+			run = r.bursting(r.alerting(t), run)
+		} else if sourceOf(t) != SourceSchedule {
+			run = r.bursting(run, run)
+		}
 
-	go b.scheduler.start()
-	go b.signaler.start()
+		if err := t.start(b.wrapRun(run, t == trigger(b.signaler), c)); err != nil {
+			endAll(started)
+			r.end()
+			b.targeter.end()
+			return err
+		}
 
+		started = append(started, t)
+	}
+
+	b.running = true
+	b.active = started
+	b.runner = r
 	return nil
 }
 
-// Implements Action.End
+// Wraps the function a trigger runs, so that runs are dropped while
+// the action or its Swat is paused, or during the cooldown, if any.
+func (b *BaseAction) wrapRun(fn func(), signal bool, c *cooldown) func() {
+	fn = b.paused.wrap(fn, signal)
+	if b.pauser != nil {
+		fn = b.pauser.wrap(fn, signal)
+	}
+
+	if c != nil {
+		fn = c.wrap(fn)
+	}
+
+	return fn
+}
+
+// `Signal` handles the signal as if the process had received it,
+// running the action if it was started with OnSignal for the signal,
+// subject to its cooldown and pausing, and forwarding the signal as
+// ForwardSignal would. Unlike a real signal, it returns once the run
+// is done. It returns false if the action isn't running or listening
+// for the signal. This lets tests, such as those using the swattest
+// package, and relays, such as admin endpoints, deliver signals.
+func (b *BaseAction) Signal(sig os.Signal) bool {
+	b.mu.Lock()
+	handle := b.signaler.handler(sig)
+	b.mu.Unlock()
+
+	if handle == nil {
+		return false
+	}
+
+	handle()
+	return true
+}
+
+// Implements Action.Trigger. If the action isn't running, its output
+// is opened just for this run. When the action is Async, the output
+// is written in the background, and errors writing it are logged
+// rather than returned.
+func (b *BaseAction) Trigger(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// The run happens without holding the lock, so that callbacks can
+	// call the action's other methods, and they don't wait for it.
+	b.mu.Lock()
+	if b.running {
+		r := b.runner
+		b.mu.Unlock()
+		return r.trigger(ctx)
+	}
+
+	if b.lastErr != nil {
+		b.mu.Unlock()
+		return b.lastErr
+	}
+
+	if err := b.targeter.start(); err != nil {
+		b.mu.Unlock()
+		return err
+	}
+
+	r := b.newRunner()
+	closer := b.targeter.detach()
+	b.mu.Unlock()
+
+	defer func() {
+		r.end()
+		if closer != nil {
+			closer.Close()
+		}
+	}()
+
+	return r.trigger(ctx)
+}
+
+// Returns a copy of the action's configuration: its function, schedule,
+// triggers, and output, so that one canonical action can be defined
+// and reused with a different target, as in:
+//
+//	local := incidentHeap.Clone().ToDir(os.TempDir())
+//
+// The copy isn't running and has its own stats. It writes to the same
+// file or sink as the original until given another target, except for
+// ToPipe, whose pipe isn't shared. Triggers which listen on something
+// exclusive, like OnUDP, can't run in both at once. The copy isn't part
+// of any Swat the original was booted in.
+//
+// Types built on an action with Extend have their own Start and End, so
+// cloning their BaseAction only copies the underlying action.
+func (b *BaseAction) Clone() *BaseAction {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	labels := make(map[string]string, len(b.labels))
+	for k, v := range b.labels {
+		labels[k] = v
+	}
+
+	triggers := make([]trigger, len(b.triggers))
+	for i, t := range b.triggers {
+		triggers[i] = t.clone()
+	}
+
+	return &BaseAction{
+		scheduler:  b.scheduler.clone().(*scheduler),
+		targeter:   b.targeter.clone(),
+		signaler:   b.signaler.clone().(*signaler),
+		fn:         b.fn,
+		ctxFn:      b.ctxFn,
+		timeout:    b.timeout,
+		name:       b.name,
+		ext:        b.ext,
+		triggers:   triggers,
+		cooldown:   b.cooldown,
+		burst:      b.burst,
+		burstGap:   b.burstGap,
+		skipBusy:   b.skipBusy,
+		asyncQueue: b.asyncQueue,
+		onError:    b.onError,
+		onSuccess:  b.onSuccess,
+		maxFails:   b.maxFails,
+		statePath:  b.statePath,
+		jsonLines:  b.jsonLines,
+		speedscope: b.speedscope,
+		lockDir:    b.lockDir,
+		labels:     labels,
+		bus:        b.bus,
+		statsd:     b.statsd,
+		etw:        b.etw,
+		reporter:   b.reporter,
+		alertURL:   b.alertURL,
+		middleware: append([]Middleware(nil), b.middleware...),
+		lastErr:    b.lastErr,
+	}
+}
+
+// Creates a runner from the action's current configuration. The
+// targeter must already be started.
+func (b *BaseAction) newRunner() *runner {
+	target := *b.targeter
+	r := &runner{
+		fn:       b.fn,
+		name:     b.displayName(),
+		ext:      b.outputExt(),
+		target:   &target,
+		skipBusy: b.skipBusy,
+		burst:    b.burst,
+		burstGap: b.burstGap,
+		limiter:  b.limiter,
+		skipped:  &b.skipped,
+		runs:     &b.runs,
+		stats:    &b.stats,
+		state:    b.state,
+		statsd:   b.statsd,
+		manifest: b.manifest,
+		etw:      b.etw,
+		clock:    b.scheduler.getClock(),
+		serial:   make(chan struct{}, 1),
+
+		onError:     b.onError,
+		onSuccess:   b.onSuccess,
+		maxFailures: int32(b.maxFails),
+	}
+
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+	if b.ctxFn != nil {
+		r.fn = b.withContext(r.ctx)
+	}
+
+	if b.reporter != nil || b.alertURL != "" {
+		r.description = b.Describe()
+	}
+
+	r.reporter = b.reporter
+	if b.alertURL != "" {
+		r.alert = newAlertWebhook(b.alertURL)
+	}
+
+	if b.speedscope {
+		r.fn = speedscope(r.name, r.fn)
+	}
+
+	if b.jsonLines {
+		r.fn = envelop(r.name, b.contentExt(), r.fn)
+	}
+
+	r.fn = b.wrapMiddleware(r.fn)
+
+	if b.lockDir != "" {
+		r.leader = newLeaderLock(filepath.Join(b.lockDir, r.name+".lock"))
+	}
+
+	if bus, onSuccess := b.bus, r.onSuccess; bus != nil {
+		r.onSuccess = func(info RunInfo) {
+			if onSuccess != nil {
+				onSuccess(info)
+			}
+			bus.Publish(info)
+		}
+	}
+
+	if b.asyncQueue > 0 {
+		r.pipeline = newAsyncWriter(&target, b.asyncQueue, r.report)
+	}
+
+	return r
+}
+
+func (b *BaseAction) useLimiter(r *rateLimiter) {
+	b.limiter = r
+}
+
+func (b *BaseAction) usePauser(p *pauser) {
+	b.pauser = p
+	b.paused.signals = p.signals
+}
+
+// Implements Action.End. Ending an action which isn't running
+// does nothing.
 func (b *BaseAction) End() {
-	parallel(
-		b.signaler.end,
-		b.scheduler.end,
-		b.targeter.end,
-	)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.running {
+		return
+	}
+
+	// Cancelling the runner's context, and closing the pipe, first
+	// unblocks runs in progress, which the triggers wait for.
+	b.runner.cancel()
+	b.targeter.closePipe()
+	endAll(b.active)
+	for _, t := range b.active {
+		setStalled(t, false)
+	}
+	b.runner.end()
+	b.targeter.end()
+	b.running = false
+	b.active = nil
+	b.runner = nil
+}
+
+// Ends all the triggers in parallel, and waits for them to finish.
+func endAll(triggers []trigger) {
+	fns := make([]func(), len(triggers))
+	for i, t := range triggers {
+		fns[i] = t.end
+	}
+
+	parallel(fns...)
 }
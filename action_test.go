@@ -0,0 +1,528 @@
+package profile
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newCountingAction() (*BaseAction, *int32) {
+	var runs int32
+	return NewAction(func(w io.Writer) error {
+		atomic.AddInt32(&runs, 1)
+		return nil
+	}), &runs
+}
+
+func TestEndBeforeStartIsNoop(t *testing.T) {
+	a, _ := newCountingAction()
+	a.End()
+	a.End()
+}
+
+func TestDoubleStartErrors(t *testing.T) {
+	a, _ := newCountingAction()
+	assert.Nil(t, a.Start())
+	assert.Equal(t, ErrAlreadyStarted, a.Start())
+	a.End()
+	a.End()
+}
+
+func TestRestartAfterEnd(t *testing.T) {
+	a, runs := newCountingAction()
+	a.Every(10 * time.Millisecond)
+
+	assert.Nil(t, a.Start())
+	time.Sleep(25 * time.Millisecond)
+	a.End()
+
+	before := atomic.LoadInt32(runs)
+	assert.True(t, before > 0)
+
+	assert.Nil(t, a.Start())
+	time.Sleep(25 * time.Millisecond)
+	a.End()
+
+	assert.True(t, atomic.LoadInt32(runs) > before)
+}
+
+func TestConcurrentTriggersAndConfiguration(t *testing.T) {
+	a, runs := newCountingAction()
+	ch := make(chan struct{})
+	a.Every(time.Millisecond).
+		When(func() bool { return true }, time.Millisecond).
+		OnChannel(ch)
+
+	assert.Nil(t, a.Start())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			ch <- struct{}{}
+		}
+	}()
+
+	// Reconfiguring a running action must not race with its triggers.
+	for i := 0; i < 20; i++ {
+		a.Every(2 * time.Millisecond).ToWriter(io.Discard).Cooldown(0)
+		time.Sleep(time.Millisecond)
+	}
+
+	<-done
+	a.End()
+	assert.True(t, atomic.LoadInt32(runs) >= 20)
+}
+
+func TestRunsAreSerialized(t *testing.T) {
+	var inFlight, overlaps int32
+	a := NewAction(func(w io.Writer) error {
+		if atomic.AddInt32(&inFlight, 1) > 1 {
+			atomic.AddInt32(&overlaps, 1)
+		}
+
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		return nil
+	})
+
+	a.Every(time.Millisecond).
+		When(func() bool { return true }, time.Millisecond).
+		When(func() bool { return true }, time.Millisecond)
+
+	assert.Nil(t, a.Start())
+	time.Sleep(50 * time.Millisecond)
+	a.End()
+
+	assert.Equal(t, int32(0), atomic.LoadInt32(&overlaps))
+}
+
+func TestSkipIfBusy(t *testing.T) {
+	release := make(chan struct{})
+	a := NewAction(func(w io.Writer) error {
+		<-release
+		return nil
+	})
+
+	ch := make(chan struct{})
+	a.OnChannel(ch).OnChannel(ch).SkipIfBusy()
+	assert.Nil(t, a.Start())
+
+	ch <- struct{}{}
+	ch <- struct{}{}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	a.End()
+
+	assert.Equal(t, uint64(1), a.Skipped())
+}
+
+func TestAsyncWritesEverythingBeforeEnd(t *testing.T) {
+	out := new(bytes.Buffer)
+	ch := make(chan struct{})
+	a := NewAction(func(w io.Writer) error {
+		_, err := w.Write([]byte("x"))
+		return err
+	}).OnChannel(ch).ToWriter(out).Async(10)
+
+	assert.Nil(t, a.Start())
+	for i := 0; i < 5; i++ {
+		ch <- struct{}{}
+	}
+	a.End()
+
+	assert.Equal(t, "xxxxx", out.String())
+}
+
+func TestStartReturnsSentinelErrors(t *testing.T) {
+	a, _ := newCountingAction()
+	a.At(time.Now()).After(time.Second)
+	assert.Equal(t, ErrConflictingStart, a.Start())
+
+	a, _ = newCountingAction()
+	a.Every(time.Second).For(time.Second).Until(time.Now())
+	assert.Equal(t, ErrConflictingEnd, a.Start())
+
+	a, _ = newCountingAction()
+	a.For(time.Second)
+	assert.Equal(t, ErrMissingInterval, a.Start())
+
+	a, _ = newCountingAction()
+	a.When(func() bool { return true }, 0)
+	assert.True(t, errors.Is(a.Start(), ErrInvalidOption))
+}
+
+func TestTriggerReturnsError(t *testing.T) {
+	fail := errors.New("failed")
+	a := NewAction(func(w io.Writer) error { return fail })
+	assert.Equal(t, fail, a.Trigger(context.Background()))
+
+	assert.Nil(t, a.Start())
+	assert.Equal(t, fail, a.Trigger(context.Background()))
+	a.End()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, a.Trigger(ctx))
+}
+
+func TestDescribe(t *testing.T) {
+	a := DumpHeap().
+		Every(5 * time.Minute).
+		For(time.Hour).
+		OnSignal(os.Interrupt).
+		ToFile("/var/dumps/heap.pprof")
+
+	assert.Equal(t, "heap every 5m0s for 1h0m0s on signal interrupt → /var/dumps/heap.pprof", a.Describe())
+
+	b, _ := newCountingAction()
+	b.ToWriter(os.Stdout)
+	assert.Equal(t, "action → stdout", b.String())
+}
+
+func TestToDirWritesFilePerRun(t *testing.T) {
+	dir := t.TempDir()
+	a := NewAction(func(w io.Writer) error {
+		_, err := w.Write([]byte("dump"))
+		return err
+	}).Name("test").ToDir(dir)
+
+	assert.Equal(t, "test → "+filepath.Join(dir, "test-*.out"), a.Describe())
+
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, a.Trigger(context.Background()))
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "test-*.out"))
+	assert.Nil(t, err)
+	assert.Len(t, files, 3)
+}
+
+func TestStopAfterFailures(t *testing.T) {
+	var runs int32
+	var reported []error
+	mu := new(sync.Mutex)
+
+	ch := make(chan struct{})
+	a := NewAction(func(w io.Writer) error {
+		atomic.AddInt32(&runs, 1)
+		return errors.New("disk full")
+	}).OnChannel(ch).StopAfterFailures(2).OnError(func(err error) {
+		mu.Lock()
+		reported = append(reported, err)
+		mu.Unlock()
+	})
+
+	assert.Nil(t, a.Start())
+	for i := 0; i < 4; i++ {
+		ch <- struct{}{}
+	}
+	a.End()
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&runs))
+	assert.Len(t, reported, 3)
+	assert.True(t, errors.Is(reported[2], ErrTooManyFailures))
+}
+
+func TestOnSuccessReportsRunInfo(t *testing.T) {
+	dir := t.TempDir()
+	var info RunInfo
+	a := NewAction(func(w io.Writer) error {
+		_, err := w.Write([]byte("dump"))
+		return err
+	}).ToDir(dir).OnSuccess(func(i RunInfo) { info = i })
+
+	assert.Nil(t, a.Trigger(context.Background()))
+	assert.Equal(t, int64(4), info.Bytes)
+	assert.Equal(t, dir, filepath.Dir(info.Path))
+	assert.False(t, info.Start.IsZero())
+}
+
+func TestNextRunAndLastRun(t *testing.T) {
+	a, _ := newCountingAction()
+	a.After(time.Hour).Every(time.Hour)
+
+	last, err := a.LastRun()
+	assert.True(t, last.IsZero())
+	assert.Nil(t, err)
+	assert.True(t, a.NextRun().IsZero())
+
+	assert.Nil(t, a.Start())
+	defer a.End()
+	time.Sleep(10 * time.Millisecond)
+	assertTimeWithin(t, a.NextRun(), time.Now().Add(time.Hour), time.Second)
+
+	assert.Nil(t, a.Trigger(context.Background()))
+	last, err = a.LastRun()
+	assertTimeWithin(t, last, time.Now(), time.Second)
+	assert.Nil(t, err)
+}
+
+func TestPersistStateResumesCampaign(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	a, _ := newCountingAction()
+	a.Every(time.Hour).For(6 * time.Hour).PersistState(path)
+	assert.Nil(t, a.Start())
+	time.Sleep(10 * time.Millisecond)
+	a.End()
+
+	state, err := loadStateFile(path)
+	assert.Nil(t, err)
+	saved := state.snapshot()
+	assert.Equal(t, uint64(1), saved.Runs)
+	assertTimeWithin(t, saved.Until, time.Now().Add(6*time.Hour), time.Second)
+
+	// A restarted action keeps the deadline and waits out the interval.
+	b, runs := newCountingAction()
+	b.Every(time.Hour).For(6 * time.Hour).PersistState(path)
+	assert.Nil(t, b.Start())
+	defer b.End()
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, int32(0), atomic.LoadInt32(runs))
+	assertTimeWithin(t, b.NextRun(), saved.LastRun.Add(time.Hour), time.Second)
+}
+
+func TestCoordinateElectsOneLeader(t *testing.T) {
+	if !flockSupported {
+		t.Skip("flock is not supported on this platform")
+	}
+
+	dir := t.TempDir()
+	a, aRuns := newCountingAction()
+	b, bRuns := newCountingAction()
+	a.Name("heap").Every(5 * time.Millisecond).Coordinate(dir)
+	b.Name("heap").Every(5 * time.Millisecond).Coordinate(dir)
+
+	assert.Nil(t, a.Start())
+	time.Sleep(20 * time.Millisecond)
+	assert.Nil(t, b.Start())
+	time.Sleep(20 * time.Millisecond)
+	assert.NotZero(t, atomic.LoadInt32(aRuns))
+	assert.Zero(t, atomic.LoadInt32(bRuns))
+	assert.NotZero(t, b.Skipped())
+
+	// Once the leader ends, the other process takes over.
+	a.End()
+	defer b.End()
+	time.Sleep(20 * time.Millisecond)
+	assert.NotZero(t, atomic.LoadInt32(bRuns))
+}
+
+func TestCoordinateRequiresName(t *testing.T) {
+	a, _ := newCountingAction()
+	err := a.Every(time.Hour).Coordinate(t.TempDir()).Start()
+	assert.True(t, errors.Is(err, ErrInvalidOption))
+}
+
+func TestOnInfoAddsSIGINFO(t *testing.T) {
+	a, _ := newCountingAction()
+	a.OnSignal(os.Interrupt).OnInfo()
+	if SIGINFO == nil {
+		assert.Equal(t, []os.Signal{os.Interrupt}, a.signals)
+	} else {
+		assert.Equal(t, []os.Signal{os.Interrupt, SIGINFO}, a.signals)
+	}
+}
+
+func TestToPipeStreamsRuns(t *testing.T) {
+	a := NewAction(func(w io.Writer) error {
+		_, err := w.Write([]byte("dump"))
+		return err
+	}).Every(time.Millisecond)
+	r := a.ToPipe()
+	assert.Equal(t, "pipe", a.targeter.describe(pathVars{}))
+	assert.Nil(t, a.Start())
+
+	buf := make([]byte, 8)
+	_, err := io.ReadFull(r, buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "dumpdump", string(buf))
+
+	// Ending doesn't wait on the reader, which then sees EOF.
+	a.End()
+	_, err = io.ReadAll(r)
+	assert.Nil(t, err)
+}
+
+func TestDumpFullHeap(t *testing.T) {
+	buf := new(bytes.Buffer)
+	assert.Nil(t, DumpFullHeap().ToWriter(buf).Trigger(context.Background()))
+	assert.True(t, bytes.HasPrefix(buf.Bytes(), []byte("go1.7 heap dump\n")))
+
+	// Files are written to directly.
+	path := filepath.Join(t.TempDir(), "heap.dump")
+	var info RunInfo
+	a := DumpFullHeap().ToFile(path).OnSuccess(func(i RunInfo) { info = i })
+	assert.Nil(t, a.Trigger(context.Background()))
+	data, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.True(t, bytes.HasPrefix(data, []byte("go1.7 heap dump\n")))
+	assert.Equal(t, int64(len(data)), info.Bytes)
+}
+
+func TestDumpAllArchivesEveryProfile(t *testing.T) {
+	custom := pprof.Lookup("swat.test.all")
+	if custom == nil {
+		custom = pprof.NewProfile("swat.test.all")
+	}
+
+	buf := new(bytes.Buffer)
+	a := DumpAll().ToWriter(buf)
+	assert.Equal(t, "zip", a.outputExt())
+	assert.Nil(t, a.Trigger(context.Background()))
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.Nil(t, err)
+	var names []string
+	for _, f := range archive.File {
+		names = append(names, f.Name)
+		r, err := f.Open()
+		assert.Nil(t, err)
+		_, err = gzip.NewReader(r)
+		assert.Nil(t, err, f.Name)
+		r.Close()
+	}
+
+	for _, p := range []string{"goroutine", "heap", "allocs", "threadcreate", custom.Name()} {
+		assert.Contains(t, names, p+".pb.gz")
+	}
+}
+
+func TestCloneSwapsTarget(t *testing.T) {
+	orig, local := new(bytes.Buffer), new(bytes.Buffer)
+	a := newOutputAction("heap", "dump").Every(time.Hour).OnSignal(os.Interrupt).
+		When(func() bool { return false }, time.Hour).ToWriter(orig)
+	c := a.Clone().ToWriter(local)
+
+	assert.Equal(t, "heap every 1h0m0s on signal interrupt when condition holds (polled every 1h0m0s) → *bytes.Buffer", c.Describe())
+	assert.Nil(t, c.Trigger(context.Background()))
+	assert.Equal(t, "dump", local.String())
+	assert.Empty(t, orig.String())
+
+	// Changing the clone leaves the original alone.
+	c.Every(time.Minute)
+	assert.Equal(t, time.Hour, a.every)
+	assert.Nil(t, a.Start())
+	assert.Nil(t, c.Start())
+	a.End()
+	c.End()
+}
+
+func TestStatusAccountsForRuns(t *testing.T) {
+	a := newOutputAction("heap", "dump").Every(time.Hour).After(time.Hour)
+	assert.Nil(t, a.Start())
+	defer a.End()
+
+	assert.Nil(t, a.Trigger(context.Background()))
+	assert.Nil(t, a.Trigger(context.Background()))
+	status := a.Status()
+	assert.True(t, status.Running)
+	assert.Equal(t, uint64(2), status.Runs)
+	assert.Equal(t, int64(4), status.LastBytes)
+	assert.Equal(t, int64(4), status.MaxBytes)
+	assert.Equal(t, int64(8), status.TotalBytes)
+	assert.True(t, status.TotalDuration >= status.MaxDuration)
+	assert.True(t, status.MaxDuration > 0)
+	assert.Eventually(t, func() bool { return !a.Status().NextRun.IsZero() }, time.Second, time.Millisecond)
+}
+
+func TestSyncedWritesFiles(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "heap.out")
+	a := newOutputAction("heap", "dump").ToFile(file).Synced()
+	assert.Nil(t, a.Trigger(context.Background()))
+	data, err := os.ReadFile(file)
+	assert.Nil(t, err)
+	assert.Equal(t, "dump", string(data))
+
+	a = newOutputAction("heap", "dump").ToDir(dir).Synced()
+	assert.Nil(t, a.Trigger(context.Background()))
+	paths := a.Summary().Paths
+	if assert.Len(t, paths, 1) {
+		data, err = os.ReadFile(paths[0])
+		assert.Nil(t, err)
+		assert.Equal(t, "dump", string(data))
+	}
+}
+
+func TestSummaryKeepsLatestPaths(t *testing.T) {
+	s := new(runStats)
+	for i := 0; i < maxPaths+10; i++ {
+		s.record(RunInfo{Path: fmt.Sprintf("heap-%d.out", i)}, nil)
+		s.record(RunInfo{Path: fmt.Sprintf("heap-%d.out", i)}, nil)
+	}
+
+	paths := s.summary().Paths
+	assert.Len(t, paths, maxPaths)
+	assert.Len(t, s.seen, maxPaths)
+	assert.Equal(t, "heap-10.out", paths[0])
+	assert.Equal(t, fmt.Sprintf("heap-%d.out", maxPaths+9), paths[maxPaths-1])
+}
+
+func TestCallbacksCanUseActionDuringTrigger(t *testing.T) {
+	var a *BaseAction
+	var status ActionStatus
+	a = newOutputAction("heap", "dump").Every(time.Hour).After(time.Hour).
+		OnSuccess(func(RunInfo) {
+			status = a.Status()
+			a.NextRun()
+		})
+	assert.Nil(t, a.Start())
+	defer a.End()
+
+	done := make(chan error)
+	go func() { done <- a.Trigger(context.Background()) }()
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+		assert.True(t, status.Running)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Trigger deadlocked")
+	}
+
+	// Callbacks of actions which aren't running can too.
+	b := newOutputAction("heap", "dump")
+	b.OnSuccess(func(RunInfo) { b.End() })
+	assert.Nil(t, b.Trigger(context.Background()))
+}
+
+func TestRunInfoSaysWhyRunsHappened(t *testing.T) {
+	dir := t.TempDir()
+	infos := make(chan RunInfo, 10)
+	var fire int32 = 1
+	a := newOutputAction("heap", "dump").Every(time.Hour).OnSignal(os.Interrupt).
+		When(func() bool { return atomic.SwapInt32(&fire, 0) == 1 }, time.Millisecond).
+		ToFileTemplate(filepath.Join(dir, "{{.Name}}-{{.Index}}-{{.Source}}.{{.Ext}}")).
+		OnSuccess(func(info RunInfo) { infos <- info })
+	assert.Nil(t, a.Start())
+	defer a.End()
+
+	// The schedule and the condition both run at once, in either order.
+	first, second := <-infos, <-infos
+	assert.ElementsMatch(t, []TriggerSource{SourceSchedule, SourceThreshold}, []TriggerSource{first.Source, second.Source})
+	assert.Equal(t, []uint64{1, 2}, []uint64{first.Index, second.Index})
+
+	assert.True(t, a.Signal(os.Interrupt))
+	info := <-infos
+	assert.Equal(t, SourceSignal, info.Source)
+	assert.Equal(t, uint64(3), info.Index)
+
+	assert.Nil(t, a.Trigger(context.Background()))
+	info = <-infos
+	assert.Equal(t, SourceManual, info.Source)
+	assert.Equal(t, filepath.Join(dir, "heap-4-manual.out"), info.Path)
+	_, err := os.Stat(filepath.Join(dir, "heap-3-signal.out"))
+	assert.Nil(t, err)
+}
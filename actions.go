@@ -1,26 +1,49 @@
 package profile
 
 import (
-	"errors"
+	"archive/zip"
+	"encoding/json"
+	"fmt"
 	"io"
+	"os"
+	"runtime"
+	"runtime/debug"
 	"runtime/pprof"
+	"time"
 )
 
 // Returns an action that dumps a pprof lookup, with the
-// given name and debug constant.
+// given name and debug constant. The action is named after
+// the profile. Only the profiles which stop the world, such as
+// goroutine and heap, wait for a slot to do so; see
+// LimitConcurrentDumps.
 func DumpPProfLookup(name string, debug int) *BaseAction {
-	return NewAction(func(w io.Writer) error {
+	dump := func(w io.Writer) error {
 		pp := pprof.Lookup(name)
 		if pp == nil {
-			return errors.New("unknown pprof " + name)
+			return fmt.Errorf("%w %s", ErrUnknownProfile, name)
 		}
 
 		if err := pp.WriteTo(w, debug); err != nil {
-			return errors.New("error writing pprof: " + err.Error())
+			return fmt.Errorf("error writing pprof: %w", err)
 		}
 
 		return nil
-	})
+	}
+	if stopsTheWorld(name) {
+		dump = stoppingTheWorld(dump)
+	}
+
+	b := NewAction(dump).Name(name)
+
+	// Profiles are gzipped protobufs unless debug output is asked for.
+	if debug == 0 {
+		b.ext = "pb.gz"
+	} else {
+		b.ext = "txt"
+	}
+
+	return b
 }
 
 // Returns an action that dumps all running goroutines,
@@ -45,3 +68,155 @@ func DumpBlocking() *BaseAction {
 func DumpThreadCreate() *BaseAction {
 	return DumpPProfLookup("threadcreate", 1)
 }
+
+// Returns an action that writes the runtime's memory statistics as
+// a line of JSON, so that runs appended to one file can be read as
+// JSON lines. Each object has a "Time" field with the time of the
+// run, alongside the fields of runtime.MemStats.
+func DumpMemStats() *BaseAction {
+	b := NewAction(stoppingTheWorld(func(w io.Writer) error {
+		var out struct {
+			Time time.Time
+			runtime.MemStats
+		}
+
+		out.Time = time.Now()
+		runtime.ReadMemStats(&out.MemStats)
+		return json.NewEncoder(w).Encode(&out)
+	})).Name("memstats")
+
+	b.ext = "json"
+	return b
+}
+
+// Returns an action that writes a full heap dump with
+// debug.WriteHeapDump, for the rare cases where the whole object
+// graph is needed rather than a sampled profile, such as for viewcore.
+// The world is stopped while the heap is written, and the dump is
+// as large as the heap. The runtime can only write the dump to a file
+// descriptor, so unless the action writes to a file, as with ToFile,
+// it's written to a temporary file, and then copied to the output.
+func DumpFullHeap() *BaseAction {
+	b := NewAction(func(w io.Writer) error {
+		if f, counter := outputFile(w); f != nil {
+			start, _ := f.Seek(0, io.SeekCurrent)
+			release := acquireSTW()
+			debug.WriteHeapDump(f.Fd())
+			release()
+
+			// Count what the runtime wrote, where the file's offset says.
+			if end, err := f.Seek(0, io.SeekCurrent); err == nil && counter != nil {
+				counter.n += end - start
+			}
+
+			return nil
+		}
+
+		f, err := os.CreateTemp("", "swat-heapdump-")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+
+		// The temporary file stands in for the buffer stoppingTheWorld
+		// would use, since the dump can be too large to hold in memory.
+		release := acquireSTW()
+		debug.WriteHeapDump(f.Fd())
+		release()
+
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+
+		_, err = io.Copy(w, f)
+		return err
+	}).Name("fullheap")
+
+	b.ext = "heapdump"
+	return b
+}
+
+// Returns the file the action's output goes straight to, if it does,
+// and the writer counting the bytes written to it, if any.
+func outputFile(w io.Writer) (*os.File, *countingWriter) {
+	counter, ok := w.(*countingWriter)
+	if ok {
+		w = counter.w
+	}
+
+	f, _ := w.(*os.File)
+	return f, counter
+}
+
+// Returns an action that writes the build information embedded in
+// the binary, such as the module versions it was built with.
+func DumpBuildInfo() *BaseAction {
+	b := NewAction(func(w io.Writer) error {
+		info, ok := debug.ReadBuildInfo()
+		if !ok {
+			return fmt.Errorf("%w: build info", ErrUnavailable)
+		}
+
+		_, err := io.WriteString(w, info.String())
+		return err
+	}).Name("buildinfo")
+
+	b.ext = "txt"
+	return b
+}
+
+// Returns an action that writes every registered pprof profile,
+// including any the application created with pprof.NewProfile, into
+// one zip archive per run, so that nothing is missed in an incident
+// snapshot. Each profile is an entry named after it, such as
+// "heap.pb.gz", holding the gzipped protobuf that DumpPProfLookup
+// writes with debug 0. The profiles are all collected in the same
+// stop-the-world slot.
+func DumpAll() *BaseAction {
+	b := NewAction(stoppingTheWorld(func(w io.Writer) error {
+		archive := zip.NewWriter(w)
+		now := time.Now()
+		for _, p := range pprof.Profiles() {
+			// The profiles are already compressed.
+			entry, err := archive.CreateHeader(&zip.FileHeader{
+				Name:     p.Name() + ".pb.gz",
+				Method:   zip.Store,
+				Modified: now,
+			})
+			if err != nil {
+				return err
+			}
+
+			if err := p.WriteTo(entry, 0); err != nil {
+				return fmt.Errorf("error writing pprof %s: %w", p.Name(), err)
+			}
+		}
+
+		return archive.Close()
+	})).Name("all")
+
+	b.ext = "zip"
+	return b
+}
+
+// Shorthand for DumpHeap, for use in one-line setups such as
+// `profile.Heap().Every(5*time.Minute).For(time.Hour).ToDir(dir)`.
+func Heap() *BaseAction {
+	return DumpHeap()
+}
+
+// Shorthand for DumpGoroutine.
+func Goroutine() *BaseAction {
+	return DumpGoroutine()
+}
+
+// Shorthand for DumpBlocking.
+func Blocking() *BaseAction {
+	return DumpBlocking()
+}
+
+// Shorthand for DumpThreadCreate.
+func ThreadCreate() *BaseAction {
+	return DumpThreadCreate()
+}
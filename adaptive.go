@@ -0,0 +1,144 @@
+package profile
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"runtime/metrics"
+	"time"
+)
+
+const schedLatencyMetric = "/sched/latencies:seconds"
+
+// Adaptivity lengthens a schedule's interval while the process is
+// under load, and shortens it again once the load has passed.
+type adaptivity struct {
+	max     time.Duration
+	cpu     float64
+	latency time.Duration
+}
+
+// Returns the interval to wait before the next run, given the current
+// one, the schedule's usual interval, and the load since the last run.
+func (a adaptivity) adjust(cur, every time.Duration, cpu float64, latency time.Duration) time.Duration {
+	busy := (a.cpu > 0 && cpu > a.cpu) || (a.latency > 0 && latency > a.latency)
+	if busy {
+		cur *= 2
+		if cur > a.max {
+			cur = a.max
+		}
+	} else {
+		cur /= 2
+		if cur < every {
+			cur = every
+		}
+	}
+
+	return cur
+}
+
+func (a adaptivity) String() string {
+	conds := []string{}
+	if a.cpu > 0 {
+		conds = append(conds, fmt.Sprintf("CPU above %g", a.cpu))
+	}
+	if a.latency > 0 {
+		conds = append(conds, "scheduler latency above "+a.latency.String())
+	}
+
+	s := "backing off to " + a.max.String() + " on "
+	for i, c := range conds {
+		if i > 0 {
+			s += " or "
+		}
+		s += c
+	}
+
+	return s
+}
+
+// Returns a function which measures the load on the process since it
+// was last called: the fraction of GOMAXPROCS used by the process, and
+// the 99th percentile of the time goroutines waited to be scheduled.
+// It's a variable so that tests can fake the load.
+var newLoadMeter = func() func() (float64, time.Duration) {
+	lastCPU, lastAt := processCPUTime(), time.Now()
+	sample := []metrics.Sample{{Name: schedLatencyMetric}}
+	metrics.Read(sample)
+	var lastCounts []uint64
+	if sample[0].Value.Kind() == metrics.KindFloat64Histogram {
+		lastCounts = append(lastCounts, sample[0].Value.Float64Histogram().Counts...)
+	}
+
+	return func() (float64, time.Duration) {
+		cpu, at := processCPUTime(), time.Now()
+		wall := at.Sub(lastAt).Seconds() * float64(runtime.GOMAXPROCS(0))
+		var used float64
+		if wall > 0 {
+			used = (cpu - lastCPU).Seconds() / wall
+		}
+		lastCPU, lastAt = cpu, at
+
+		var latency time.Duration
+		metrics.Read(sample)
+		if sample[0].Value.Kind() == metrics.KindFloat64Histogram {
+			h := sample[0].Value.Float64Histogram()
+			latency = percentile(h, lastCounts, 0.99)
+			lastCounts = append(lastCounts[:0], h.Counts...)
+		}
+
+		return used, latency
+	}
+}
+
+// Returns the given percentile of the samples added to the histogram
+// since it had the previous counts, as the upper bound of the bucket
+// it falls in.
+func percentile(h *metrics.Float64Histogram, prev []uint64, p float64) time.Duration {
+	var total uint64
+	deltas := make([]uint64, len(h.Counts))
+	for i, c := range h.Counts {
+		if i < len(prev) {
+			c -= prev[i]
+		}
+		deltas[i] = c
+		total += c
+	}
+
+	if total == 0 {
+		return 0
+	}
+
+	var seen uint64
+	for i, d := range deltas {
+		seen += d
+		if float64(seen) >= p*float64(total) {
+			bound := h.Buckets[i+1]
+			if math.IsInf(bound, 1) {
+				bound = h.Buckets[i]
+			}
+
+			return time.Duration(bound * float64(time.Second))
+		}
+	}
+
+	return 0
+}
+
+// `Adaptive` keeps the overhead of scheduled runs proportional to the
+// process's headroom. Whenever the process used more than `cpu` of
+// GOMAXPROCS since the last run, such as 0.8, or the 99th percentile
+// of scheduler latency was above `latency`, the interval doubles, up
+// to `limit`. Once neither is the case, it halves again, back down to
+// `Every`. A zero threshold isn't checked, but at least one is needed.
+// Requires `Every`.
+func (b *BaseAction) Adaptive(limit time.Duration, cpu float64, latency time.Duration) *BaseAction {
+	b.scheduler.Adaptive(limit, cpu, latency)
+	return b
+}
+
+// `adaptive` lengthens the interval while the process is busy.
+func (s *scheduler) Adaptive(limit time.Duration, cpu float64, latency time.Duration) *scheduler {
+	s.adaptive = &adaptivity{max: limit, cpu: cpu, latency: latency}
+	return s
+}
@@ -0,0 +1,93 @@
+package profile
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"time"
+)
+
+// An Alert is POSTed as JSON to the webhook given to AlertWebhook when
+// one of an action's conditions fires.
+type Alert struct {
+	// The version of the alert's format; see SchemaVersion.
+	SchemaVersion int
+	// The name of the action.
+	Action string
+	// The condition which fired, such as "when condition holds
+	// (polled every 1s)".
+	Trigger string
+	// The action's description, as given by Describe.
+	Description string
+	Host        string
+	Time        time.Time
+}
+
+// Posts alerts to a webhook.
+type alertWebhook struct {
+	url    string
+	client *http.Client
+}
+
+func newAlertWebhook(url string) *alertWebhook {
+	return &alertWebhook{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Posts the alert in the background, so that the run it precedes
+// isn't held up. Errors are passed to notify.
+func (a *alertWebhook) send(alert Alert, notify func(error)) {
+	go func() {
+		data, err := json.Marshal(alert)
+		if err != nil {
+			notify(err)
+			return
+		}
+
+		req, err := http.NewRequest("POST", a.url, bytes.NewReader(data))
+		if err != nil {
+			notify(err)
+			return
+		}
+
+		req.Header.Set("Content-Type", "application/json")
+		if err := upload(a.client, req); err != nil {
+			notify(err)
+		}
+	}()
+}
+
+// `AlertWebhook` POSTs an Alert to the URL whenever one of the
+// action's conditions, such as HeapAbove, fires, so that humans can be
+// paged while the dump is still being collected. Alerts are sent in
+// the background, at the start of the run, and only for runs which go
+// ahead, so not for ones which are paused, cooling down, rate limited,
+// skipped while busy or left to another leader. Signals and schedules don't send
+// alerts. Failures to send them are reported like failed runs, to
+// OnError or the log.
+func (b *BaseAction) AlertWebhook(url string) *BaseAction {
+	b.alertURL = url
+	return b
+}
+
+// Returns the function a condition trigger runs, which sends an alert
+// before running the action, if there's a webhook for alerts.
+func (r *runner) alerting(t trigger) func() {
+	if r.alert == nil {
+		return r.runFor(SourceThreshold)
+	}
+
+	host, _ := os.Hostname()
+	alert := func() {
+		r.alert.send(Alert{
+			SchemaVersion: SchemaVersion,
+			Action:        r.name,
+			Trigger:       t.String(),
+			Description:   r.description,
+			Host:          host,
+			Time:          time.Now(),
+		}, r.notify)
+	}
+
+	return func() { r.runWith(SourceThreshold, alert) }
+}
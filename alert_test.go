@@ -0,0 +1,60 @@
+package profile
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAlertWebhookOnCondition(t *testing.T) {
+	alerts := make(chan Alert, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var a Alert
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&a))
+		alerts <- a
+	}))
+	defer srv.Close()
+
+	var fire int32 = 1
+	a, runs := newCountingAction()
+	a.Name("heap").When(func() bool { return atomic.SwapInt32(&fire, 0) == 1 }, time.Millisecond).
+		Every(time.Hour).After(time.Hour).AlertWebhook(srv.URL)
+	assert.Nil(t, a.Start())
+	defer a.End()
+
+	select {
+	case alert := <-alerts:
+		assert.Equal(t, SchemaVersion, alert.SchemaVersion)
+		assert.Equal(t, "heap", alert.Action)
+		assert.Equal(t, "when condition holds (polled every 1ms)", alert.Trigger)
+	case <-time.After(time.Second):
+		t.Fatal("no alert was sent")
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(runs))
+}
+
+func TestAlertWebhookOnlyForRunsWhichGoAhead(t *testing.T) {
+	var alerts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&alerts, 1)
+	}))
+	defer srv.Close()
+
+	a, runs := newCountingAction()
+	a.When(func() bool { return true }, time.Millisecond).AlertWebhook(srv.URL)
+	a.useLimiter(newRateLimiter(1))
+	assert.Nil(t, a.Start())
+
+	// The condition fires every poll, but the limiter lets one through.
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&alerts) == 1 }, time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	a.End()
+	assert.Equal(t, int32(1), atomic.LoadInt32(runs))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&alerts))
+}
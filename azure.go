@@ -0,0 +1,73 @@
+package profile
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+)
+
+// AzureBlobSink is a Sink which uploads each dump as a block blob in
+// an Azure Blob Storage container. It authenticates with a shared
+// access signature, so it needs no Azure SDK.
+type AzureBlobSink struct {
+	client    *http.Client
+	container *url.URL
+	names     objectNamer
+}
+
+// Creates a sink which uploads dumps to the container. The URL should
+// carry a SAS token allowing writes, as in
+// "https://account.blob.core.windows.net/dumps?sv=...&sig=...". Blobs
+// are named by the text/template, which can use the same fields as
+// ToFileTemplate; if it's empty, "{{.Name}}/{{.Time}}.{{.Ext}}" is
+// used. A nil client uses http.DefaultClient.
+func NewAzureBlobSink(client *http.Client, containerURL, pattern string) (*AzureBlobSink, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	container, err := url.Parse(containerURL)
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := newObjectNamer(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AzureBlobSink{client: client, container: container, names: names}, nil
+}
+
+func (a *AzureBlobSink) String() string {
+	// Leave out the SAS token, which is a secret.
+	return a.container.Scheme + "://" + a.container.Host + path.Join(a.container.Path, a.names.tmpl.Root.String())
+}
+
+// Implements Sink.Open. The dump is uploaded once the writer is
+// closed, and closing it returns any error from the upload.
+func (a *AzureBlobSink) Open(info RunInfo) (io.WriteCloser, error) {
+	return &bufferedWriter{info: info, flush: a.upload}, nil
+}
+
+func (a *AzureBlobSink) upload(info RunInfo, data []byte) error {
+	name, err := a.names.name(info)
+	if err != nil {
+		return err
+	}
+
+	u := *a.container
+	u.Path = path.Join(u.Path, name)
+	u.RawPath = ""
+	req, err := http.NewRequest("PUT", u.String(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", contentType(info.Ext))
+	req.Header.Set("x-ms-blob-type", "BlockBlob")
+	req.Header.Set("x-ms-version", "2020-10-02")
+	return upload(a.client, req)
+}
@@ -0,0 +1,61 @@
+package profile
+
+import (
+	"fmt"
+	"time"
+)
+
+// A blackout is a period during which scheduled runs are suppressed.
+type blackout interface {
+	fmt.Stringer
+	contains(t time.Time) bool
+	valid() bool
+}
+
+// A one-off blackout between two times.
+type windowBlackout struct {
+	start, end time.Time
+}
+
+func (w windowBlackout) contains(t time.Time) bool {
+	return !t.Before(w.start) && t.Before(w.end)
+}
+
+func (w windowBlackout) valid() bool {
+	return w.start.Before(w.end)
+}
+
+func (w windowBlackout) String() string {
+	return fmt.Sprintf("except between %s and %s",
+		w.start.Format(time.RFC3339), w.end.Format(time.RFC3339))
+}
+
+// A blackout recurring every day, between two offsets from local
+// midnight. If `to` is before `from`, the window spans midnight.
+type dailyBlackout struct {
+	from, to time.Duration
+}
+
+func (d dailyBlackout) contains(t time.Time) bool {
+	y, m, day := t.Date()
+	offset := t.Sub(time.Date(y, m, day, 0, 0, 0, 0, t.Location()))
+	if d.from <= d.to {
+		return offset >= d.from && offset < d.to
+	}
+
+	return offset >= d.from || offset < d.to
+}
+
+func (d dailyBlackout) valid() bool {
+	day := 24 * time.Hour
+	return d.from >= 0 && d.from < day && d.to >= 0 && d.to <= day && d.from != d.to
+}
+
+func (d dailyBlackout) String() string {
+	return fmt.Sprintf("except daily %s to %s", clock(d.from), clock(d.to))
+}
+
+// Formats an offset from midnight like "09:30".
+func clock(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d/time.Hour), int(d%time.Hour/time.Minute))
+}
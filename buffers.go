@@ -0,0 +1,47 @@
+package profile
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// Buffers larger than this aren't returned to the pool, so that one
+// unusually large dump doesn't stay resident for the process's life.
+const maxPooledBuffer = 16 << 20
+
+// Pool of buffers used by actions which serialize their output into
+// memory before writing it out, so that frequent dumps don't thrash
+// the allocator they're trying to observe.
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// Gets an empty buffer from the pool.
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+// Resets the buffer and returns it to the pool.
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBuffer {
+		return
+	}
+
+	buf.Reset()
+	bufferPool.Put(buf)
+}
+
+// Runs fn into a pooled buffer, and then hands the buffer to out.
+// The buffer is returned to the pool once out returns, so it must
+// not be retained. Nothing is passed to out if fn fails.
+func buffered(fn func(io.Writer) error, out func(*bytes.Buffer) error) error {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	if err := fn(buf); err != nil {
+		return err
+	}
+
+	return out(buf)
+}
@@ -0,0 +1,42 @@
+package profile
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+)
+
+func TestBufferedPassesOutput(t *testing.T) {
+	var got string
+	err := buffered(func(w io.Writer) error {
+		_, err := w.Write([]byte("dump"))
+		return err
+	}, func(buf *bytes.Buffer) error {
+		got = buf.String()
+		return nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "dump", got)
+}
+
+func TestBufferedSkipsOutputOnError(t *testing.T) {
+	called := false
+	err := buffered(func(w io.Writer) error {
+		return errors.New("nope")
+	}, func(buf *bytes.Buffer) error {
+		called = true
+		return nil
+	})
+
+	assert.NotNil(t, err)
+	assert.False(t, called)
+}
+
+func TestPutBufferDropsLargeBuffers(t *testing.T) {
+	buf := bytes.NewBuffer(make([]byte, 0, maxPooledBuffer+1))
+	putBuffer(buf)
+	assert.Equal(t, maxPooledBuffer+1, buf.Cap())
+}
@@ -0,0 +1,347 @@
+package profile
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// Builder wraps a BaseAction so that its chaining methods return a
+// derived type, rather than *BaseAction. Types which build on an
+// action embed a Builder of themselves, and keep their own fluent
+// API after calling After, Every, ToFile, and so on:
+//
+//	type HeapAction struct {
+//	    profile.Builder[*HeapAction]
+//	}
+//
+//	func DumpMyHeap() *HeapAction {
+//	    h := new(HeapAction)
+//	    h.Builder = profile.Extend(profile.DumpHeap(), h)
+//	    return h
+//	}
+//
+// The wrapped action's Start and End methods are promoted, so the
+// derived type is itself an Action.
+type Builder[T any] struct {
+	*BaseAction
+	self T
+}
+
+// Extends the action, returning a Builder whose chaining methods
+// return self.
+func Extend[T any](b *BaseAction, self T) Builder[T] {
+	return Builder[T]{BaseAction: b, self: self}
+}
+
+// See BaseAction.Name.
+func (b Builder[T]) Name(name string) T {
+	b.BaseAction.Name(name)
+	return b.self
+}
+
+// See BaseAction.After.
+func (b Builder[T]) After(after time.Duration) T {
+	b.BaseAction.After(after)
+	return b.self
+}
+
+// See BaseAction.At.
+func (b Builder[T]) At(at time.Time) T {
+	b.BaseAction.At(at)
+	return b.self
+}
+
+// See BaseAction.Every.
+func (b Builder[T]) Every(every time.Duration) T {
+	b.BaseAction.Every(every)
+	return b.self
+}
+
+// See BaseAction.Splayed.
+func (b Builder[T]) Splayed(seed string) T {
+	b.BaseAction.Splayed(seed)
+	return b.self
+}
+
+// See BaseAction.WithProbability.
+func (b Builder[T]) WithProbability(p float64) T {
+	b.BaseAction.WithProbability(p)
+	return b.self
+}
+
+// See BaseAction.ExceptBetween.
+func (b Builder[T]) ExceptBetween(start, end time.Time) T {
+	b.BaseAction.ExceptBetween(start, end)
+	return b.self
+}
+
+// See BaseAction.ExceptDaily.
+func (b Builder[T]) ExceptDaily(from, to time.Duration) T {
+	b.BaseAction.ExceptDaily(from, to)
+	return b.self
+}
+
+// See BaseAction.For.
+func (b Builder[T]) For(length time.Duration) T {
+	b.BaseAction.For(length)
+	return b.self
+}
+
+// See BaseAction.Until.
+func (b Builder[T]) Until(until time.Time) T {
+	b.BaseAction.Until(until)
+	return b.self
+}
+
+// See BaseAction.OnSignal.
+func (b Builder[T]) OnSignal(signals ...os.Signal) T {
+	b.BaseAction.OnSignal(signals...)
+	return b.self
+}
+
+// See BaseAction.ForwardSignal.
+func (b Builder[T]) ForwardSignal(children func() []*os.Process) T {
+	b.BaseAction.ForwardSignal(children)
+	return b.self
+}
+
+// See BaseAction.OnInfo.
+func (b Builder[T]) OnInfo() T {
+	b.BaseAction.OnInfo()
+	return b.self
+}
+
+// See BaseAction.When.
+func (b Builder[T]) When(pred func() bool, poll time.Duration) T {
+	b.BaseAction.When(pred, poll)
+	return b.self
+}
+
+// See BaseAction.AfterEveryNGCs.
+func (b Builder[T]) AfterEveryNGCs(n int) T {
+	b.BaseAction.AfterEveryNGCs(n)
+	return b.self
+}
+
+// See BaseAction.OnChannel.
+func (b Builder[T]) OnChannel(ch <-chan struct{}) T {
+	b.BaseAction.OnChannel(ch)
+	return b.self
+}
+
+// See BaseAction.OnUDP.
+func (b Builder[T]) OnUDP(addr string, magic []byte) T {
+	b.BaseAction.OnUDP(addr, magic)
+	return b.self
+}
+
+// See BaseAction.AtUptime.
+func (b Builder[T]) AtUptime(milestones ...time.Duration) T {
+	b.BaseAction.AtUptime(milestones...)
+	return b.self
+}
+
+// See BaseAction.OnErrors.
+func (b Builder[T]) OnErrors(e *ErrorCounter) T {
+	b.BaseAction.OnErrors(e)
+	return b.self
+}
+
+// See BaseAction.OnLatency.
+func (b Builder[T]) OnLatency(l *LatencyTrigger) T {
+	b.BaseAction.OnLatency(l)
+	return b.self
+}
+
+// See BaseAction.OnPipeCommand.
+func (b Builder[T]) OnPipeCommand(command string) T {
+	b.BaseAction.OnPipeCommand(command)
+	return b.self
+}
+
+// See BaseAction.Burst.
+func (b Builder[T]) Burst(n int, gap time.Duration) T {
+	b.BaseAction.Burst(n, gap)
+	return b.self
+}
+
+// See BaseAction.Cooldown.
+func (b Builder[T]) Cooldown(period time.Duration) T {
+	b.BaseAction.Cooldown(period)
+	return b.self
+}
+
+// See BaseAction.SkipIfBusy.
+func (b Builder[T]) SkipIfBusy() T {
+	b.BaseAction.SkipIfBusy()
+	return b.self
+}
+
+// See BaseAction.Async.
+func (b Builder[T]) Async(queue int) T {
+	b.BaseAction.Async(queue)
+	return b.self
+}
+
+// See BaseAction.OnError.
+func (b Builder[T]) OnError(fn func(error)) T {
+	b.BaseAction.OnError(fn)
+	return b.self
+}
+
+// See BaseAction.OnSuccess.
+func (b Builder[T]) OnSuccess(fn func(RunInfo)) T {
+	b.BaseAction.OnSuccess(fn)
+	return b.self
+}
+
+// See BaseAction.StopAfterFailures.
+func (b Builder[T]) StopAfterFailures(n int) T {
+	b.BaseAction.StopAfterFailures(n)
+	return b.self
+}
+
+// See BaseAction.Label.
+func (b Builder[T]) Label(key, value string) T {
+	b.BaseAction.Label(key, value)
+	return b.self
+}
+
+// See BaseAction.PublishTo.
+func (b Builder[T]) PublishTo(bus *Bus) T {
+	b.BaseAction.PublishTo(bus)
+	return b.self
+}
+
+// See BaseAction.AfterRunOf.
+func (b Builder[T]) AfterRunOf(bus *Bus, name string) T {
+	b.BaseAction.AfterRunOf(bus, name)
+	return b.self
+}
+
+// See BaseAction.EmitStatsD.
+func (b Builder[T]) EmitStatsD(s *StatsD) T {
+	b.BaseAction.EmitStatsD(s)
+	return b.self
+}
+
+// See BaseAction.EmitETW.
+func (b Builder[T]) EmitETW(e *ETW) T {
+	b.BaseAction.EmitETW(e)
+	return b.self
+}
+
+// See BaseAction.ReportErrors.
+func (b Builder[T]) ReportErrors(r ErrorReporter) T {
+	b.BaseAction.ReportErrors(r)
+	return b.self
+}
+
+// See BaseAction.AlertWebhook.
+func (b Builder[T]) AlertWebhook(url string) T {
+	b.BaseAction.AlertWebhook(url)
+	return b.self
+}
+
+// See BaseAction.Adaptive.
+func (b Builder[T]) Adaptive(limit time.Duration, cpu float64, latency time.Duration) T {
+	b.BaseAction.Adaptive(limit, cpu, latency)
+	return b.self
+}
+
+// See BaseAction.Synced.
+func (b Builder[T]) Synced() T {
+	b.BaseAction.Synced()
+	return b.self
+}
+
+// See BaseAction.Timeout.
+func (b Builder[T]) Timeout(d time.Duration) T {
+	b.BaseAction.Timeout(d)
+	return b.self
+}
+
+// See BaseAction.Use.
+func (b Builder[T]) Use(mw ...Middleware) T {
+	b.BaseAction.Use(mw...)
+	return b.self
+}
+
+// See BaseAction.Clock.
+func (b Builder[T]) Clock(c Clock) T {
+	b.BaseAction.Clock(c)
+	return b.self
+}
+
+// See BaseAction.Coordinate.
+func (b Builder[T]) Coordinate(dir string) T {
+	b.BaseAction.Coordinate(dir)
+	return b.self
+}
+
+// See BaseAction.PersistState.
+func (b Builder[T]) PersistState(path string) T {
+	b.BaseAction.PersistState(path)
+	return b.self
+}
+
+// See BaseAction.Speedscope.
+func (b Builder[T]) Speedscope() T {
+	b.BaseAction.Speedscope()
+	return b.self
+}
+
+// See BaseAction.JSONLines.
+func (b Builder[T]) JSONLines() T {
+	b.BaseAction.JSONLines()
+	return b.self
+}
+
+// See BaseAction.HashNames.
+func (b Builder[T]) HashNames() T {
+	b.BaseAction.HashNames()
+	return b.self
+}
+
+// See BaseAction.Checksums.
+func (b Builder[T]) Checksums() T {
+	b.BaseAction.Checksums()
+	return b.self
+}
+
+// See BaseAction.ToWithFallback.
+func (b Builder[T]) ToWithFallback(primary, fallback Sink) T {
+	b.BaseAction.ToWithFallback(primary, fallback)
+	return b.self
+}
+
+// See BaseAction.To.
+func (b Builder[T]) To(s Sink) T {
+	b.BaseAction.To(s)
+	return b.self
+}
+
+// See BaseAction.ToWriter.
+func (b Builder[T]) ToWriter(w io.Writer) T {
+	b.BaseAction.ToWriter(w)
+	return b.self
+}
+
+// See BaseAction.ToFile.
+func (b Builder[T]) ToFile(f string) T {
+	b.BaseAction.ToFile(f)
+	return b.self
+}
+
+// See BaseAction.ToFileTemplate.
+func (b Builder[T]) ToFileTemplate(pattern string) T {
+	b.BaseAction.ToFileTemplate(pattern)
+	return b.self
+}
+
+// See BaseAction.ToDir.
+func (b Builder[T]) ToDir(dir string) T {
+	b.BaseAction.ToDir(dir)
+	return b.self
+}
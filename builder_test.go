@@ -0,0 +1,56 @@
+package profile
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testDerived struct {
+	Builder[*testDerived]
+	extra int
+}
+
+func (t *testDerived) Extra(n int) *testDerived {
+	t.extra = n
+	return t
+}
+
+func newTestDerived() *testDerived {
+	t := new(testDerived)
+	t.Builder = Extend(NewAction(func(w io.Writer) error { return nil }), t)
+	return t
+}
+
+func TestBuilderPreservesType(t *testing.T) {
+	d := newTestDerived().Every(time.Hour).ToWriter(io.Discard).Extra(3)
+	assert.Equal(t, 3, d.extra)
+	assert.Equal(t, time.Hour, d.every)
+
+	var _ Action = d
+	assert.Nil(t, d.Start())
+	d.End()
+}
+
+// Every chaining method on BaseAction must have a counterpart on
+// Builder, or derived types would silently lose their own type.
+func TestBuilderCoversChainingMethods(t *testing.T) {
+	base := reflect.TypeOf(&BaseAction{})
+	builder := reflect.TypeOf(Builder[*testDerived]{})
+	derived := reflect.TypeOf(&testDerived{})
+
+	for i := 0; i < base.NumMethod(); i++ {
+		m := base.Method(i)
+		// Clone copies the action rather than chaining.
+		if m.Type.NumOut() != 1 || m.Type.Out(0) != base || m.Name == "Clone" {
+			continue
+		}
+
+		bm, ok := builder.MethodByName(m.Name)
+		if assert.True(t, ok, "Builder is missing %s", m.Name) {
+			assert.Equal(t, derived, bm.Type.Out(0), "Builder.%s returns the wrong type", m.Name)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package profile
+
+import (
+	"fmt"
+	"time"
+)
+
+// `Burst` makes each firing of a signal or condition trigger run the
+// action n times, `gap` apart, such as three goroutine dumps two
+// seconds apart, which shows how a transient state evolves far better
+// than a single snapshot. Scheduled and manual runs aren't affected,
+// and the burst is subject to the cooldown as a whole. A burst stops
+// early if the action ends.
+func (b *BaseAction) Burst(n int, gap time.Duration) *BaseAction {
+	if b.lastErr == nil && (n < 1 || gap < 0) {
+		b.lastErr = fmt.Errorf("%w: 'Burst' requires a positive count and a non-negative gap", ErrInvalidOption)
+	}
+
+	b.burst, b.burstGap = n, gap
+	return b
+}
+
+// Returns a function which runs first, and then rest until the burst
+// is done. Conditions alert only on the first run of a burst.
+func (r *runner) bursting(first, rest func()) func() {
+	if r.burst <= 1 {
+		return first
+	}
+
+	return func() {
+		first()
+		for i := 1; i < r.burst; i++ {
+			timer := time.NewTimer(r.burstGap)
+			select {
+			case <-r.ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			rest()
+		}
+	}
+}
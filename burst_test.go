@@ -0,0 +1,66 @@
+package profile
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBurstRunsRepeatedly(t *testing.T) {
+	ch := make(chan struct{})
+	var times []time.Time
+	a, runs := newCountingAction()
+	a.OnSuccess(func(info RunInfo) { times = append(times, info.Start) })
+	a.OnChannel(ch).Burst(3, 20*time.Millisecond).Cooldown(time.Hour)
+	assert.Contains(t, a.Describe(), "on channel receive in bursts of 3 (20ms apart) with cooldown 1h0m0s")
+	assert.Nil(t, a.Start())
+	defer a.End()
+
+	ch <- struct{}{}
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(runs) == 3 }, time.Second, time.Millisecond)
+	a.End()
+	assert.True(t, times[2].Sub(times[0]) >= 40*time.Millisecond, "runs at %v", times)
+
+	// The cooldown applies to the burst as a whole.
+	assert.Nil(t, a.Start())
+	ch <- struct{}{}
+	ch <- struct{}{}
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(runs) == 6 }, time.Second, time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.EqualValues(t, 6, atomic.LoadInt32(runs))
+}
+
+func TestBurstStopsWhenActionEnds(t *testing.T) {
+	ch := make(chan struct{})
+	a, runs := newCountingAction()
+	assert.Nil(t, a.OnChannel(ch).Burst(3, time.Hour).Start())
+
+	ch <- struct{}{}
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(runs) == 1 }, time.Second, time.Millisecond)
+
+	ended := make(chan struct{})
+	go func() {
+		a.End()
+		close(ended)
+	}()
+	select {
+	case <-ended:
+	case <-time.After(time.Second):
+		t.Fatal("End waited for the burst")
+	}
+	assert.EqualValues(t, 1, atomic.LoadInt32(runs))
+}
+
+func TestBurstSkipsSchedules(t *testing.T) {
+	a, runs := newCountingAction()
+	assert.Nil(t, a.Every(time.Hour).Burst(3, 0).Start())
+	defer a.End()
+
+	time.Sleep(20 * time.Millisecond)
+	assert.EqualValues(t, 1, atomic.LoadInt32(runs))
+
+	b, _ := newCountingAction()
+	assert.True(t, errors.Is(b.Burst(0, time.Second).Start(), ErrInvalidOption))
+}
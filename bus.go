@@ -0,0 +1,124 @@
+package profile
+
+import "sync"
+
+// A Bus carries "just ran" events between actions, so that one action
+// can follow up on another. For example, a goroutine dump can be taken
+// every time a CPU profile completes:
+//
+//	bus := profile.NewBus()
+//	cpu := profile.ProfileCPU(30 * time.Second).PublishTo(bus)
+//	goroutines := profile.DumpGoroutine().AfterRunOf(bus, "cpu")
+type Bus struct {
+	mu   sync.Mutex
+	subs map[string]map[chan RunInfo]struct{}
+}
+
+// Creates a new, empty bus.
+func NewBus() *Bus {
+	return &Bus{subs: make(map[string]map[chan RunInfo]struct{})}
+}
+
+// Publishes an event for a completed run to the subscribers of its
+// name. Publishing never blocks: a subscriber which hasn't yet handled
+// the previous event only sees one of them.
+func (b *Bus) Publish(info RunInfo) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subs[info.Name] {
+		select {
+		case ch <- info:
+		default:
+		}
+	}
+}
+
+func (b *Bus) subscribe(name string) chan RunInfo {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan RunInfo, 1)
+	if b.subs[name] == nil {
+		b.subs[name] = make(map[chan RunInfo]struct{})
+	}
+	b.subs[name][ch] = struct{}{}
+	return ch
+}
+
+func (b *Bus) unsubscribe(name string, ch chan RunInfo) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.subs[name], ch)
+	if len(b.subs[name]) == 0 {
+		delete(b.subs, name)
+	}
+}
+
+// BusTrigger is a trigger which runs an action whenever the named
+// action publishes a run on a bus.
+type busTrigger struct {
+	bus    *Bus
+	name   string
+	ch     chan RunInfo
+	closer chan struct{}
+	done   chan struct{}
+}
+
+func newBusTrigger(bus *Bus, name string) *busTrigger {
+	return &busTrigger{bus: bus, name: name}
+}
+
+func (t *busTrigger) String() string {
+	return "after " + t.name + " runs"
+}
+
+func (t *busTrigger) clone() trigger {
+	return newBusTrigger(t.bus, t.name)
+}
+
+func (t *busTrigger) end() {
+	close(t.closer)
+	<-t.done
+	t.bus.unsubscribe(t.name, t.ch)
+}
+
+func (t *busTrigger) start(fn func()) error {
+	// Subscribe synchronously, so that no runs published once the
+	// action has started are missed.
+	t.ch = t.bus.subscribe(t.name)
+	t.closer = make(chan struct{})
+	t.done = make(chan struct{})
+	supervise(t, t.closer, t.done, func() error { t.run(fn); return nil })
+
+	return nil
+}
+
+func (t *busTrigger) run(fn func()) {
+	for {
+		select {
+		case <-t.closer:
+			return
+		case <-t.ch:
+			fn()
+		}
+	}
+}
+
+// `PublishTo` publishes an event on the bus after every successful
+// run of the action, so that others can follow up on it with
+// AfterRunOf. The action should be named, since events are matched by
+// name.
+func (b *BaseAction) PublishTo(bus *Bus) *BaseAction {
+	b.bus = bus
+	return b
+}
+
+// `AfterRunOf` runs the action whenever the named action publishes a
+// successful run on the bus. Like conditions, it's subject to the
+// action's Cooldown.
+func (b *BaseAction) AfterRunOf(bus *Bus, name string) *BaseAction {
+	b.triggers = append(b.triggers, newBusTrigger(bus, name))
+	return b
+}
@@ -0,0 +1,30 @@
+package profile
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBusTriggersFollowUp(t *testing.T) {
+	bus := NewBus()
+	first := newOutputAction("first", "x").PublishTo(bus)
+	second, runs := newCountingAction()
+	second.AfterRunOf(bus, "first")
+	assert.Equal(t, "action after first runs → nowhere", second.Describe())
+
+	assert.Nil(t, second.Start())
+	assert.Nil(t, first.Trigger(context.Background()))
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(runs))
+
+	// Events for other actions, and events once ended, are ignored.
+	bus.Publish(RunInfo{Name: "other"})
+	second.End()
+	assert.Nil(t, first.Trigger(context.Background()))
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(runs))
+	assert.Empty(t, bus.subs)
+}
@@ -0,0 +1,50 @@
+package profile
+
+// ChannelTrigger is a trigger which runs an action every time a
+// value is received on a channel. It stops listening once the
+// channel is closed.
+type channelTrigger struct {
+	ch     <-chan struct{}
+	closer chan struct{}
+	done   chan struct{}
+}
+
+func newChannelTrigger(ch <-chan struct{}) *channelTrigger {
+	return &channelTrigger{ch: ch}
+}
+
+func (c *channelTrigger) String() string {
+	return "on channel receive"
+}
+
+func (c *channelTrigger) clone() trigger {
+	return newChannelTrigger(c.ch)
+}
+
+func (c *channelTrigger) end() {
+	close(c.closer)
+	<-c.done
+}
+
+func (c *channelTrigger) start(fn func()) error {
+	c.closer = make(chan struct{})
+	c.done = make(chan struct{})
+	supervise(c, c.closer, c.done, func() error { c.run(fn); return nil })
+
+	return nil
+}
+
+func (c *channelTrigger) run(fn func()) {
+	for {
+		select {
+		case <-c.closer:
+			return
+		case _, ok := <-c.ch:
+			if !ok {
+				return
+			}
+
+			fn()
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package profile
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOnChannelRunsPerReceive(t *testing.T) {
+	ch := make(chan struct{})
+	a, runs := newCountingAction()
+	a.OnChannel(ch)
+	assert.Nil(t, a.Start())
+	defer a.End()
+
+	for i := 0; i < 3; i++ {
+		ch <- struct{}{}
+	}
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(runs) == 3 }, 5*time.Second, time.Millisecond)
+
+	// Closing the channel stops the trigger without running the action.
+	close(ch)
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(t, int32(3), atomic.LoadInt32(runs))
+}
+
+func TestOnChannelStopsListeningOnEnd(t *testing.T) {
+	ch := make(chan struct{}, 1)
+	a, runs := newCountingAction()
+	a.OnChannel(ch)
+	assert.Nil(t, a.Start())
+	a.End()
+
+	ch <- struct{}{}
+	time.Sleep(5 * time.Millisecond)
+	assert.Zero(t, atomic.LoadInt32(runs))
+}
@@ -0,0 +1,51 @@
+package profile
+
+import (
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Finishes a file written from the template, given the SHA-256 of its
+// contents. With HashNames, the file is renamed after its hash, or
+// removed if an identical dump already exists. With Checksums, a
+// sidecar is written next to it. Returns the file's final path.
+func (t *targeter) checksum(path string, vars pathVars, sum []byte) (string, error) {
+	digest := hex.EncodeToString(sum)
+	if t.hashNames {
+		named := filepath.Join(filepath.Dir(path), vars.Name+"-"+digest+"."+vars.Ext)
+		if _, err := os.Stat(named); err == nil {
+			if err := os.Remove(path); err != nil {
+				return path, err
+			}
+		} else if err := os.Rename(path, named); err != nil {
+			return path, err
+		}
+
+		path = named
+	}
+
+	if t.sidecars {
+		// This is the format read by `sha256sum -c`.
+		line := digest + "  " + filepath.Base(path) + "\n"
+		if err := os.WriteFile(path+".sha256", []byte(line), 0666); err != nil {
+			return path, err
+		}
+	}
+
+	return path, nil
+}
+
+// Copies writes to a second writer, such as a hash, and closes only
+// the first.
+type teeCloser struct {
+	io.WriteCloser
+	tee io.Writer
+}
+
+func (t teeCloser) Write(p []byte) (int, error) {
+	n, err := t.WriteCloser.Write(p)
+	t.tee.Write(p[:n])
+	return n, err
+}
@@ -0,0 +1,32 @@
+package profile
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHashNamesDeduplicates(t *testing.T) {
+	dir := t.TempDir()
+	var paths []string
+	a := newOutputAction("heap", "dump").ToDir(dir).HashNames().Checksums().
+		OnSuccess(func(info RunInfo) { paths = append(paths, info.Path) })
+	assert.Nil(t, a.Trigger(context.Background()))
+	assert.Nil(t, a.Trigger(context.Background()))
+
+	sum := sha256.Sum256([]byte("dump"))
+	digest := hex.EncodeToString(sum[:])
+	expected := filepath.Join(dir, "heap-"+digest+".out")
+	assert.Equal(t, []string{expected, expected}, paths)
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	assert.Equal(t, []string{expected, expected + ".sha256"}, files)
+
+	sidecar, err := os.ReadFile(expected + ".sha256")
+	assert.Nil(t, err)
+	assert.Equal(t, digest+"  heap-"+digest+".out\n", string(sidecar))
+}
@@ -0,0 +1,66 @@
+package profile
+
+import "time"
+
+// A Clock tells the time, and makes the timers that schedules wait on.
+// Actions use the system's clock unless they're given another, such as
+// the fake clock in the swattest package, so that schedules can be
+// tested without waiting for them.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// A Timer made by a Clock, which behaves like a time.Timer.
+type Timer interface {
+	// C returns the channel on which the time is delivered when the
+	// timer fires.
+	C() <-chan time.Time
+	Reset(d time.Duration) bool
+	Stop() bool
+}
+
+// The system's clock, which is used by default.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+type systemTimer struct {
+	t *time.Timer
+}
+
+func (s systemTimer) C() <-chan time.Time {
+	return s.t.C
+}
+
+func (s systemTimer) Reset(d time.Duration) bool {
+	return s.t.Reset(d)
+}
+
+func (s systemTimer) Stop() bool {
+	return s.t.Stop()
+}
+
+// `Clock` runs the action's schedule by the clock, and stamps its runs
+// with its time, as seen in RunInfo and the names of files written by
+// ToDir, and measures its Cooldown. Timeouts and the durations of runs
+// still use the system's clock.
+func (b *BaseAction) Clock(c Clock) *BaseAction {
+	b.scheduler.clock = c
+	return b
+}
+
+// Returns the scheduler's clock, or the system's if it has none.
+func (s *scheduler) getClock() Clock {
+	if s.clock == nil {
+		return systemClock{}
+	}
+
+	return s.clock
+}
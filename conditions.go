@@ -0,0 +1,84 @@
+package profile
+
+import (
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// Returns a condition which holds only when all of the given
+// conditions hold. Conditions are evaluated in order, and
+// evaluation stops at the first one that doesn't hold.
+func And(conds ...func() bool) func() bool {
+	return func() bool {
+		for _, cond := range conds {
+			if !cond() {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+// Returns a condition which holds when any of the given conditions
+// hold. Conditions are evaluated in order, and evaluation stops at
+// the first one that holds.
+func Or(conds ...func() bool) func() bool {
+	return func() bool {
+		for _, cond := range conds {
+			if cond() {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// Returns a condition which holds when the given one doesn't.
+func Not(cond func() bool) func() bool {
+	return func() bool {
+		return !cond()
+	}
+}
+
+// Returns a condition which holds when the number of bytes
+// allocated on the heap exceeds the limit.
+func HeapAbove(limit uint64) func() bool {
+	return func() bool {
+		stats := new(runtime.MemStats)
+		runtime.ReadMemStats(stats)
+		return stats.HeapAlloc > limit
+	}
+}
+
+// Returns a condition which holds when the number of running
+// goroutines exceeds the limit.
+func GoroutinesAbove(limit int) func() bool {
+	return func() bool {
+		return runtime.NumGoroutine() > limit
+	}
+}
+
+// Returns a condition which holds when no garbage collection has
+// finished for longer than the duration. A busy service collects
+// regularly, so a long gap suggests GC starvation or a wedged runtime;
+// dumping memory statistics and the heap then helps find out which:
+//
+//	profile.DumpMemStats().When(profile.NoGCFor(5*time.Minute), time.Second).ToDir(dir)
+//
+// Before the first collection, the gap is measured from when the
+// package was initialized.
+func NoGCFor(d time.Duration) func() bool {
+	return func() bool {
+		var stats debug.GCStats
+		debug.ReadGCStats(&stats)
+		last := stats.LastGC
+		if last.IsZero() {
+			last = processStart
+		}
+
+		return time.Since(last) > d
+	}
+}
@@ -0,0 +1,96 @@
+package profile
+
+import (
+	"github.com/stretchr/testify/assert"
+	"runtime"
+	"runtime/debug"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func always(v bool) func() bool {
+	return func() bool { return v }
+}
+
+func TestAndOrNot(t *testing.T) {
+	assert.True(t, And(always(true), always(true))())
+	assert.False(t, And(always(true), always(false))())
+	assert.True(t, And()())
+
+	assert.True(t, Or(always(false), always(true))())
+	assert.False(t, Or(always(false), always(false))())
+	assert.False(t, Or()())
+
+	assert.True(t, Not(always(false))())
+	assert.False(t, Not(always(true))())
+}
+
+func TestAndShortCircuits(t *testing.T) {
+	called := false
+	And(always(false), func() bool {
+		called = true
+		return true
+	})()
+
+	assert.False(t, called)
+}
+
+func TestThresholdConditions(t *testing.T) {
+	assert.True(t, GoroutinesAbove(0)())
+	assert.False(t, GoroutinesAbove(1<<30)())
+	assert.True(t, HeapAbove(0)())
+	assert.False(t, HeapAbove(1<<62)())
+}
+
+func TestNoGCFor(t *testing.T) {
+	defer debug.SetGCPercent(debug.SetGCPercent(-1))
+
+	runtime.GC()
+	assert.False(t, NoGCFor(time.Hour)())
+	time.Sleep(20 * time.Millisecond)
+	assert.True(t, NoGCFor(10*time.Millisecond)())
+	runtime.GC()
+	assert.False(t, NoGCFor(10*time.Millisecond)())
+}
+
+func TestWhenRunsWhileConditionHolds(t *testing.T) {
+	var holds int32
+	a, runs := newCountingAction()
+	a.When(func() bool { return atomic.LoadInt32(&holds) == 1 }, time.Millisecond)
+	assert.Equal(t, "action when condition holds (polled every 1ms) → nowhere", a.Describe())
+	assert.Nil(t, a.Start())
+	defer a.End()
+
+	time.Sleep(10 * time.Millisecond)
+	assert.Zero(t, atomic.LoadInt32(runs))
+
+	atomic.StoreInt32(&holds, 1)
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(runs) >= 2 }, 5*time.Second, time.Millisecond)
+
+	// Runs stop once the condition no longer holds.
+	atomic.StoreInt32(&holds, 0)
+	time.Sleep(5 * time.Millisecond)
+	settled := atomic.LoadInt32(runs)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, settled, atomic.LoadInt32(runs))
+}
+
+func TestPanickingConditionIsRestarted(t *testing.T) {
+	defer func(d time.Duration) { restartDelay = d }(restartDelay)
+	restartDelay = time.Millisecond
+
+	var checks int32
+	a, runs := newCountingAction()
+	a.When(func() bool {
+		if atomic.AddInt32(&checks, 1) == 1 {
+			panic("condition failed")
+		}
+		return true
+	}, time.Millisecond)
+
+	assert.Nil(t, a.Start())
+	time.Sleep(20 * time.Millisecond)
+	a.End()
+	assert.NotZero(t, atomic.LoadInt32(runs))
+}
@@ -0,0 +1,161 @@
+package profile
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Actions which can be addressed by name, as BaseAction can.
+type named interface {
+	actionName() string
+}
+
+// Actions which report their status, as BaseAction does.
+type statused interface {
+	Status() ActionStatus
+}
+
+// Serves an interactive console for CLI tools and local debugging
+// sessions, reading commands from r, usually os.Stdin, and writing a
+// prompt and the replies to w. It returns once r is exhausted, and
+// should be called once the actions are booted:
+//
+//	go s.ServeConsole(os.Stdin, os.Stdout)
+//
+// The console understands the commands:
+//
+//	trigger <name>  runs the actions with the name, as Trigger does
+//	status          prints the status of each named action
+//	help            lists the commands and the names of the actions
+//
+// Only actions given a name with Name can be triggered.
+func (s *Swat) ServeConsole(r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	for {
+		if _, err := io.WriteString(w, "swat> "); err != nil {
+			return err
+		}
+		if !scanner.Scan() {
+			break
+		}
+
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		var err error
+		switch {
+		case fields[0] == "trigger" && len(fields) == 2:
+			err = s.consoleTrigger(w, fields[1])
+		case fields[0] == "status" && len(fields) == 1:
+			err = s.consoleStatus(w)
+		case fields[0] == "help" && len(fields) == 1:
+			err = s.consoleHelp(w)
+		default:
+			_, err = fmt.Fprintf(w, "unknown command %q, try \"help\"\n", strings.Join(fields, " "))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return err
+	}
+
+	return scanner.Err()
+}
+
+// Returns the actions which have names.
+func (s *Swat) namedActions() map[string][]Action {
+	actions := map[string][]Action{}
+	for _, action := range s.actions {
+		if n, ok := action.(named); ok && n.actionName() != "" {
+			actions[n.actionName()] = append(actions[n.actionName()], action)
+		}
+	}
+
+	return actions
+}
+
+func (s *Swat) consoleTrigger(w io.Writer, name string) error {
+	actions := s.namedActions()[name]
+	if len(actions) == 0 {
+		_, err := fmt.Fprintf(w, "no action is named %q\n", name)
+		return err
+	}
+
+	for _, action := range actions {
+		began := time.Now()
+		if err := action.Trigger(context.Background()); err != nil {
+			if _, err := fmt.Fprintf(w, "%s failed: %s\n", name, err); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if _, err := fmt.Fprintf(w, "%s ran in %s\n", name, time.Since(began).Round(time.Millisecond)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Swat) consoleStatus(w io.Writer) error {
+	for _, action := range s.actions {
+		n, ok := action.(named)
+		if !ok || n.actionName() == "" {
+			continue
+		}
+
+		line := n.actionName()
+		if a, ok := action.(statused); ok {
+			line += ": " + formatStatus(a.Status())
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Swat) consoleHelp(w io.Writer) error {
+	var names []string
+	for _, action := range s.actions {
+		if n, ok := action.(named); ok && n.actionName() != "" {
+			names = append(names, n.actionName())
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "commands: trigger <name>, status, help\nactions: %s\n", strings.Join(names, ", "))
+	return err
+}
+
+// Formats the status on a single line, such as "running, 3 runs,
+// 0 errors, last run 5s ago, next run in 55s".
+func formatStatus(status ActionStatus) string {
+	parts := []string{"stopped"}
+	if status.Running {
+		parts[0] = "running"
+	}
+
+	parts = append(parts, fmt.Sprintf("%d runs", status.Runs), fmt.Sprintf("%d errors", status.Errors))
+	if !status.LastRun.IsZero() {
+		parts = append(parts, "last run "+time.Since(status.LastRun).Round(time.Second).String()+" ago")
+	}
+	if status.LastError != nil {
+		parts = append(parts, "last error: "+status.LastError.Error())
+	}
+	if !status.NextRun.IsZero() {
+		parts = append(parts, "next run in "+time.Until(status.NextRun).Round(time.Second).String())
+	}
+
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,49 @@
+package profile
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServeConsole(t *testing.T) {
+	heap, runs := newCountingAction()
+	heap.Name("heap").Every(time.Hour).After(time.Hour)
+	unnamed, _ := newCountingAction()
+	unnamed.Every(time.Hour).After(time.Hour)
+	s, err := Start(heap, unnamed)
+	assert.Nil(t, err)
+	defer s.End()
+
+	in := strings.NewReader("trigger heap\n\nstatus\ntrigger action\nhelp\nfrobnicate\n")
+	out := new(bytes.Buffer)
+	assert.Nil(t, s.ServeConsole(in, out))
+	assert.Equal(t, int32(1), atomic.LoadInt32(runs))
+
+	lines := strings.Split(out.String(), "\n")
+	assert.Regexp(t, `^swat> heap ran in `, lines[0])
+	assert.Regexp(t, `^swat> swat> heap: running, 1 runs, 0 errors, last run \d+s ago, next run in `, lines[1])
+	assert.Equal(t, []string{
+		`swat> no action is named "action"`,
+		"swat> commands: trigger <name>, status, help",
+		"actions: heap",
+		`swat> unknown command "frobnicate", try "help"`,
+		"swat> ",
+		"",
+	}, lines[2:])
+}
+
+func TestFormatStatus(t *testing.T) {
+	assert.Equal(t, "stopped, 0 runs, 0 errors", formatStatus(ActionStatus{}))
+	assert.Equal(t, "running, 2 runs, 1 errors, last error: boom", formatStatus(ActionStatus{
+		Running:   true,
+		Runs:      2,
+		Errors:    1,
+		LastError: errors.New("boom"),
+	}))
+}
@@ -0,0 +1,45 @@
+package profile
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Creates an action like NewAction, whose function is passed a context
+// which is cancelled when the action ends, or when the run exceeds the
+// action's Timeout, so that long captures, such as traces or external
+// commands, can be cleanly aborted. Whatever the function writes before
+// it returns is still written out.
+func NewActionCtx(fn func(ctx context.Context, w io.Writer) error) *BaseAction {
+	b := NewAction(nil)
+	b.ctxFn = fn
+	return b
+}
+
+// `Timeout` cancels the context of each run of an action created with
+// NewActionCtx once it has taken d.
+func (b *BaseAction) Timeout(d time.Duration) *BaseAction {
+	if b.lastErr == nil && d <= 0 {
+		b.lastErr = fmt.Errorf("%w: 'Timeout' requires a positive duration", ErrInvalidOption)
+	}
+
+	b.timeout = d
+	return b
+}
+
+// Returns a function which calls the action's context-aware function
+// with a context derived from ctx, and limited by the timeout.
+func (b *BaseAction) withContext(ctx context.Context) func(io.Writer) error {
+	fn, timeout := b.ctxFn, b.timeout
+	return func(w io.Writer) error {
+		if timeout > 0 {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			return fn(ctx, w)
+		}
+
+		return fn(ctx, w)
+	}
+}
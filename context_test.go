@@ -0,0 +1,36 @@
+package profile
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestActionCtxTimesOut(t *testing.T) {
+	a := NewActionCtx(func(ctx context.Context, w io.Writer) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}).Timeout(time.Millisecond)
+
+	err := a.Trigger(context.Background())
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.True(t, errors.Is(NewActionCtx(nil).Timeout(0).Start(), ErrInvalidOption))
+}
+
+func TestActionCtxCancelledOnEnd(t *testing.T) {
+	started, errs := make(chan struct{}), make(chan error, 1)
+	a := NewActionCtx(func(ctx context.Context, w io.Writer) error {
+		close(started)
+		<-ctx.Done()
+		errs <- ctx.Err()
+		return nil
+	}).Every(time.Hour)
+	assert.Nil(t, a.Start())
+
+	<-started
+	a.End()
+	assert.Equal(t, context.Canceled, <-errs)
+}
@@ -0,0 +1,53 @@
+package profile
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Cooldown is used to limit condition- and signal-based triggers
+// so that they run an action at most once per period.
+type cooldown struct {
+	period time.Duration
+	// clock, if set, replaces the system's clock, and skipped, if set,
+	// counts the calls which were dropped.
+	clock   Clock
+	skipped *uint64
+	mu      sync.Mutex
+	last    time.Time
+}
+
+func newCooldown(period time.Duration) *cooldown {
+	return &cooldown{period: period}
+}
+
+// Returns whether enough time has passed since the last run, and
+// if so, records now as the time of the last run.
+func (c *cooldown) allow() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if c.clock != nil {
+		now = c.clock.Now()
+	}
+	if !c.last.IsZero() && now.Sub(c.last) < c.period {
+		return false
+	}
+
+	c.last = now
+	return true
+}
+
+// Wraps the function so that calls made during the cooldown
+// period are dropped.
+func (c *cooldown) wrap(fn func()) func() {
+	return func() {
+		if c.allow() {
+			fn()
+		} else if c.skipped != nil {
+			atomic.AddUint64(c.skipped, 1)
+		}
+	}
+}
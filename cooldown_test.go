@@ -0,0 +1,57 @@
+package profile
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// A clock which only moves when it's told to. Its timers are the
+// system's.
+type manualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *manualClock) NewTimer(d time.Duration) Timer {
+	return systemClock{}.NewTimer(d)
+}
+
+func (c *manualClock) advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func TestCooldownSkipsFiringsInsideWindow(t *testing.T) {
+	clock := &manualClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	ch := make(chan struct{})
+	a, runs := newCountingAction()
+	a.OnChannel(ch).Cooldown(time.Minute).Clock(clock)
+	assert.Nil(t, a.Start())
+	defer a.End()
+
+	fire := func(runsWant int32, skippedWant uint64) {
+		ch <- struct{}{}
+		assert.Eventually(t, func() bool {
+			return atomic.LoadInt32(runs) == runsWant && a.Skipped() == skippedWant
+		}, 5*time.Second, time.Millisecond)
+	}
+
+	fire(1, 0)
+	clock.advance(59 * time.Second)
+	fire(1, 1)
+
+	// The cooldown follows the action's clock, not the system's.
+	clock.advance(time.Second)
+	fire(2, 1)
+}
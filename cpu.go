@@ -0,0 +1,206 @@
+package profile
+
+import (
+	"context"
+	"io"
+	"runtime/pprof"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The longest a continuous CPU profile waits before trying again,
+// while profiling keeps failing.
+const maxCPUBackoff = 5 * time.Minute
+
+// CPUProfileAction is an action which profiles the CPU for a fixed
+// window on each run.
+type CPUProfileAction struct {
+	Builder[*CPUProfileAction]
+	window time.Duration
+	mu     sync.Mutex
+	// stop is closed when the action ends, cutting short the window
+	// in progress.
+	stop chan struct{}
+	// attempts counts the runs which tried to profile, and failures
+	// those in a row which couldn't, so that continuous profiling can
+	// back off.
+	attempts uint64
+	failures uint64
+}
+
+// Returns an action that profiles the CPU for `window` on each run,
+// and writes the profile. Only one CPU profile can be taken in a
+// process at once, so runs fail while another is in progress.
+func ProfileCPU(window time.Duration) *CPUProfileAction {
+	c := &CPUProfileAction{window: window}
+	b := NewAction(c.profile).Name("cpu")
+	b.ext = "pb.gz"
+
+	c.Builder = Extend(b, c)
+	return c
+}
+
+// `Continuous` profiles in back-to-back windows for as long as the
+// action runs, giving always-on CPU profiling. Each window is a new
+// run, so use ToDir or a Sink to keep every chunk: profiles appended
+// to one file can't be read. While profiling fails, such as when
+// another CPU profile is in progress, it backs off, waiting twice as
+// long after each failure, up to five minutes. While runs are skipped,
+// such as when the action is paused, it waits a window between tries.
+func (c *CPUProfileAction) Continuous() *CPUProfileAction {
+	c.triggers = append(c.triggers, &cpuLoop{c: c})
+	return c
+}
+
+func (c *CPUProfileAction) profile(w io.Writer) error {
+	c.mu.Lock()
+	stop := c.stop
+	c.mu.Unlock()
+
+	atomic.AddUint64(&c.attempts, 1)
+	if err := pprof.StartCPUProfile(w); err != nil {
+		atomic.AddUint64(&c.failures, 1)
+		return err
+	}
+	defer pprof.StopCPUProfile()
+	atomic.StoreUint64(&c.failures, 0)
+
+	timer := time.NewTimer(c.window)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-stop:
+	}
+
+	return nil
+}
+
+// Implements Action.Start.
+func (c *CPUProfileAction) Start() error {
+	c.mu.Lock()
+	if c.stop == nil || isClosed(c.stop) {
+		c.stop = make(chan struct{})
+	}
+	c.mu.Unlock()
+
+	return c.BaseAction.Start()
+}
+
+// Implements Action.Trigger. Once the action has ended, it returns
+// ErrNotRunning rather than a profile cut short at once; start it
+// again to profile.
+func (c *CPUProfileAction) Trigger(ctx context.Context) error {
+	c.mu.Lock()
+	ended := c.stop != nil && isClosed(c.stop)
+	c.mu.Unlock()
+
+	if ended {
+		return ErrNotRunning
+	}
+
+	return c.BaseAction.Trigger(ctx)
+}
+
+// Implements Action.End, cutting short the window in progress, whose
+// profile is still written.
+func (c *CPUProfileAction) End() {
+	c.mu.Lock()
+	if c.stop != nil && !isClosed(c.stop) {
+		close(c.stop)
+	}
+	c.mu.Unlock()
+
+	c.BaseAction.End()
+}
+
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// CpuLoop is a trigger which runs a CPU profile in back-to-back
+// windows, backing off while they fail.
+type cpuLoop struct {
+	c      *CPUProfileAction
+	closer chan struct{}
+	done   chan struct{}
+}
+
+func (l *cpuLoop) String() string {
+	return "continuously"
+}
+
+func (l *cpuLoop) source() TriggerSource {
+	return SourceSchedule
+}
+
+func (l *cpuLoop) clone() trigger {
+	return &cpuLoop{c: l.c}
+}
+
+func (l *cpuLoop) end() {
+	close(l.closer)
+	<-l.done
+}
+
+func (l *cpuLoop) start(fn func()) error {
+	l.closer = make(chan struct{})
+	l.done = make(chan struct{})
+	supervise(l, l.closer, l.done, func() error { l.run(fn); return nil })
+
+	return nil
+}
+
+func (l *cpuLoop) run(fn func()) {
+	for {
+		select {
+		case <-l.closer:
+			return
+		default:
+		}
+
+		attempts := atomic.LoadUint64(&l.c.attempts)
+		fn()
+
+		var delay time.Duration
+		if atomic.LoadUint64(&l.c.attempts) == attempts {
+			delay = l.c.window
+		} else if failures := atomic.LoadUint64(&l.c.failures); failures > 0 {
+			delay = l.backoff(failures)
+		}
+
+		if delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-l.closer:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+	}
+}
+
+// Returns how long to wait after the given number of failures in a
+// row: a window, or a second if that's longer, doubling each time.
+func (l *cpuLoop) backoff(failures uint64) time.Duration {
+	delay := l.c.window
+	if delay < time.Second {
+		delay = time.Second
+	}
+
+	for i := uint64(1); i < failures && delay < maxCPUBackoff; i++ {
+		delay *= 2
+	}
+
+	if delay > maxCPUBackoff {
+		delay = maxCPUBackoff
+	}
+
+	return delay
+}
@@ -0,0 +1,104 @@
+package profile
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"path/filepath"
+	"runtime/pprof"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestContinuousCPUProfilesInChunks(t *testing.T) {
+	dir := t.TempDir()
+	var mu sync.Mutex
+	var runs []RunInfo
+	c := ProfileCPU(50 * time.Millisecond).Continuous().ToDir(dir).
+		OnSuccess(func(info RunInfo) {
+			mu.Lock()
+			runs = append(runs, info)
+			mu.Unlock()
+		})
+	assert.Nil(t, c.Start())
+	time.Sleep(400 * time.Millisecond)
+	c.End()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.True(t, len(runs) >= 2, "only %d chunks", len(runs))
+	for _, info := range runs {
+		assert.Equal(t, dir, filepath.Dir(info.Path))
+		assert.NotZero(t, info.Bytes)
+	}
+	for i := 1; i < len(runs); i++ {
+		gap := runs[i].Start.Sub(runs[i-1].Start.Add(runs[i-1].Duration))
+		assert.True(t, gap < 10*time.Millisecond, "gap of %s between chunks", gap)
+	}
+}
+
+func TestDutyCycleProfilesOncePerPeriod(t *testing.T) {
+	var mu sync.Mutex
+	var runs []RunInfo
+	c := ProfileCPU(time.Hour).DutyCycle(20*time.Millisecond, 100*time.Millisecond).ToWriter(io.Discard).
+		OnSuccess(func(info RunInfo) {
+			mu.Lock()
+			runs = append(runs, info)
+			mu.Unlock()
+		})
+	assert.Contains(t, c.Describe(), "cpu for 20ms of every 100ms")
+	started := time.Now()
+	assert.Nil(t, c.Start())
+	time.Sleep(350 * time.Millisecond)
+	c.End()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if assert.True(t, len(runs) >= 3 && len(runs) <= 4, "%d runs", len(runs)) {
+		for i, info := range runs[:3] {
+			assert.True(t, info.Duration >= 20*time.Millisecond && info.Duration < 90*time.Millisecond, info.Duration)
+			period := started.Add(time.Duration(i) * 100 * time.Millisecond)
+			assert.False(t, info.Start.Before(period), "run %d started early", i)
+			assert.True(t, info.Start.Before(period.Add(100*time.Millisecond)), "run %d started late", i)
+		}
+	}
+
+	assert.True(t, errors.Is(ProfileCPU(0).DutyCycle(time.Minute, time.Minute).Start(), ErrInvalidOption))
+}
+
+func TestEndCutsCPUWindowShort(t *testing.T) {
+	c := ProfileCPU(time.Hour).Every(time.Hour).ToDir(t.TempDir())
+	assert.Nil(t, c.Start())
+	time.Sleep(10 * time.Millisecond)
+
+	start := time.Now()
+	c.End()
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestContinuousCPUBacksOffOnFailure(t *testing.T) {
+	// Another profile holds the CPU profiler, so every run fails.
+	assert.Nil(t, pprof.StartCPUProfile(io.Discard))
+	defer pprof.StopCPUProfile()
+
+	var errs int32
+	c := ProfileCPU(time.Millisecond).Continuous().OnError(func(error) { atomic.AddInt32(&errs, 1) })
+	assert.Nil(t, c.Start())
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&errs) >= 1 }, time.Second, time.Millisecond)
+	c.End()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&errs))
+	assert.Equal(t, time.Second, (&cpuLoop{c: c}).backoff(1))
+	assert.Equal(t, 4*time.Second, (&cpuLoop{c: c}).backoff(3))
+	assert.Equal(t, maxCPUBackoff, (&cpuLoop{c: c}).backoff(100))
+}
+
+func TestCPUTriggerAfterEnd(t *testing.T) {
+	c := ProfileCPU(time.Hour).Every(time.Hour).After(time.Hour)
+	assert.Nil(t, c.Start())
+	c.End()
+	assert.Equal(t, ErrNotRunning, c.Trigger(context.Background()))
+}
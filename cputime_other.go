@@ -0,0 +1,25 @@
+//go:build !unix && !windows
+// +build !unix,!windows
+
+package profile
+
+import (
+	"runtime/metrics"
+	"time"
+)
+
+// Returns the CPU time used by the process so far, as estimated by
+// the runtime, since there's no portable way to ask the OS.
+func processCPUTime() time.Duration {
+	sample := []metrics.Sample{
+		{Name: "/cpu/classes/total:cpu-seconds"},
+		{Name: "/cpu/classes/idle:cpu-seconds"},
+	}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindFloat64 || sample[1].Value.Kind() != metrics.KindFloat64 {
+		return 0
+	}
+
+	busy := sample[0].Value.Float64() - sample[1].Value.Float64()
+	return time.Duration(busy * float64(time.Second))
+}
@@ -0,0 +1,19 @@
+//go:build unix
+// +build unix
+
+package profile
+
+import (
+	"syscall"
+	"time"
+)
+
+// Returns the CPU time used by the process so far.
+func processCPUTime() time.Duration {
+	var usage syscall.Rusage
+	if syscall.Getrusage(syscall.RUSAGE_SELF, &usage) != nil {
+		return 0
+	}
+
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+}
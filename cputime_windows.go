@@ -0,0 +1,20 @@
+package profile
+
+import (
+	"syscall"
+	"time"
+)
+
+// Returns the CPU time used by the process so far.
+func processCPUTime() time.Duration {
+	var creation, exit, kernel, user syscall.Filetime
+	h, err := syscall.GetCurrentProcess()
+	if err != nil || syscall.GetProcessTimes(h, &creation, &exit, &kernel, &user) != nil {
+		return 0
+	}
+
+	// Filetimes count 100ns intervals.
+	ticks := (int64(kernel.HighDateTime)<<32 | int64(kernel.LowDateTime)) +
+		(int64(user.HighDateTime)<<32 | int64(user.LowDateTime))
+	return time.Duration(ticks * 100)
+}
@@ -0,0 +1,149 @@
+package profile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The profiling endpoint of a local Datadog agent.
+const DatadogAgentURL = "http://localhost:8126/profiling/v1/input"
+
+// DatadogSink is a Sink which uploads pprof profiles to Datadog's
+// profile intake, so that dumps collected by Swat appear in its
+// profiler UI. Each profile is sent as an attachment named after the
+// action, such as "cpu.pprof" or "heap.pprof", alongside an
+// event.json describing it. Only binary pprof output, such as from
+// ProfileCPU or DumpPProfLookup with a debug of zero, can be uploaded.
+type DatadogSink struct {
+	client   *http.Client
+	endpoint string
+	apiKey   string
+	tags     map[string]string
+}
+
+// Creates a sink which uploads profiles to the endpoint, which is
+// usually DatadogAgentURL. To upload without an agent, use the
+// intake for your site, such as
+// "https://intake.profile.datadoghq.com/api/v2/profile", with APIKey.
+func NewDatadogSink(endpoint string) *DatadogSink {
+	host, _ := os.Hostname()
+	return &DatadogSink{
+		client:   &http.Client{Timeout: 30 * time.Second},
+		endpoint: endpoint,
+		tags:     map[string]string{"host": host, "runtime": "go"},
+	}
+}
+
+// Sets the API key, which is needed to upload without an agent.
+func (d *DatadogSink) APIKey(key string) *DatadogSink {
+	d.apiKey = key
+	return d
+}
+
+// Sets the service the profiles belong to.
+func (d *DatadogSink) Service(service string) *DatadogSink {
+	return d.Tag("service", service)
+}
+
+// Sets the environment the service runs in, such as "prod".
+func (d *DatadogSink) Env(env string) *DatadogSink {
+	return d.Tag("env", env)
+}
+
+// Sets the version of the service.
+func (d *DatadogSink) Version(version string) *DatadogSink {
+	return d.Tag("version", version)
+}
+
+// Tags the profiles.
+func (d *DatadogSink) Tag(key, value string) *DatadogSink {
+	d.tags[key] = value
+	return d
+}
+
+func (d *DatadogSink) String() string {
+	return "datadog " + d.endpoint
+}
+
+// The event describing an uploaded profile.
+type datadogEvent struct {
+	Attachments []string `json:"attachments"`
+	Tags        string   `json:"tags_profiler"`
+	Start       string   `json:"start"`
+	End         string   `json:"end"`
+	Family      string   `json:"family"`
+	Version     string   `json:"version"`
+}
+
+// Implements Sink.Open. The profile is uploaded once the writer is
+// closed, and closing it returns any error from the intake.
+func (d *DatadogSink) Open(info RunInfo) (io.WriteCloser, error) {
+	if info.Ext != "pb.gz" {
+		return nil, fmt.Errorf("datadog needs a pprof profile, not %q output", info.Ext)
+	}
+
+	return &bufferedWriter{info: info, flush: d.upload}, nil
+}
+
+func (d *DatadogSink) upload(info RunInfo, data []byte) error {
+	tags := make([]string, 0, len(d.tags))
+	for k, v := range d.tags {
+		tags = append(tags, k+":"+v)
+	}
+	sort.Strings(tags)
+
+	attachment := info.Name + ".pprof"
+	event, err := json.Marshal(datadogEvent{
+		Attachments: []string{attachment},
+		Tags:        strings.Join(tags, ","),
+		Start:       info.Start.UTC().Format(time.RFC3339Nano),
+		End:         info.Start.Add(info.Duration).UTC().Format(time.RFC3339Nano),
+		Family:      "go",
+		Version:     "4",
+	})
+	if err != nil {
+		return err
+	}
+
+	body := new(bytes.Buffer)
+	form := multipart.NewWriter(body)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="event"; filename="event.json"`)
+	header.Set("Content-Type", "application/json")
+	part, err := form.CreatePart(header)
+	if err != nil {
+		return err
+	}
+
+	part.Write(event)
+	part, err = form.CreateFormFile(attachment, attachment)
+	if err != nil {
+		return err
+	}
+
+	part.Write(data)
+	if err := form.Close(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", d.endpoint, body)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if d.apiKey != "" {
+		req.Header.Set("DD-API-KEY", d.apiKey)
+	}
+
+	return upload(d.client, req)
+}
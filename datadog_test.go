@@ -0,0 +1,38 @@
+package profile
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDatadogSinkUploadsProfiles(t *testing.T) {
+	var event datadogEvent
+	var profile []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "key", r.Header.Get("DD-API-KEY"))
+		assert.Nil(t, r.ParseMultipartForm(1<<20))
+
+		f, _, err := r.FormFile("event")
+		if assert.Nil(t, err) {
+			assert.Nil(t, json.NewDecoder(f).Decode(&event))
+		}
+
+		f, _, err = r.FormFile("heap.pprof")
+		if assert.Nil(t, err) {
+			profile, _ = io.ReadAll(f)
+		}
+	}))
+	defer srv.Close()
+
+	sink := NewDatadogSink(srv.URL).APIKey("key").Service("checkout").Env("prod").Tag("host", "web-1")
+	assert.Nil(t, DumpPProfLookup("heap", 0).To(sink).Trigger(context.Background()))
+	assert.Equal(t, []string{"heap.pprof"}, event.Attachments)
+	assert.Equal(t, "env:prod,host:web-1,runtime:go,service:checkout", event.Tags)
+	assert.Equal(t, "go", event.Family)
+	assert.Equal(t, []byte{0x1f, 0x8b}, profile[:2])
+}
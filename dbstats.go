@@ -0,0 +1,29 @@
+package profile
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Returns an action that writes a database's connection pool
+// statistics as a line of JSON, since pool exhaustion often comes
+// with the latency incidents people profile. Pass the pool's Stats
+// method, as in `DumpDBStats(db.Stats)`. Like DumpMemStats, each
+// object has a "Time" field alongside the fields of sql.DBStats.
+func DumpDBStats(stats func() sql.DBStats) *BaseAction {
+	b := NewAction(func(w io.Writer) error {
+		var out struct {
+			Time time.Time
+			sql.DBStats
+		}
+
+		out.Time = time.Now()
+		out.DBStats = stats()
+		return json.NewEncoder(w).Encode(&out)
+	}).Name("dbstats")
+
+	b.ext = "json"
+	return b
+}
@@ -0,0 +1,22 @@
+package profile
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDumpDBStats(t *testing.T) {
+	buf := new(bytes.Buffer)
+	stats := func() sql.DBStats { return sql.DBStats{OpenConnections: 3, WaitCount: 7} }
+	assert.Nil(t, DumpDBStats(stats).ToWriter(buf).Trigger(context.Background()))
+
+	var out map[string]interface{}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &out))
+	assert.Equal(t, float64(3), out["OpenConnections"])
+	assert.Equal(t, float64(7), out["WaitCount"])
+	assert.NotEmpty(t, out["Time"])
+}
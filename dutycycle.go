@@ -0,0 +1,89 @@
+package profile
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// `DutyCycle` profiles for `active` out of every `period`, such as
+// 10s of every 5m, giving statistically useful coverage of a service
+// at a bounded overhead. It replaces the window the action was created
+// with. Each profile starts at a random point in its period, so that
+// work which recurs on a schedule, such as a job every minute, is
+// neither always nor never caught. The active time must be positive
+// and shorter than the period.
+func (c *CPUProfileAction) DutyCycle(active, period time.Duration) *CPUProfileAction {
+	if c.lastErr == nil && (active <= 0 || period <= active) {
+		c.lastErr = fmt.Errorf("%w: 'DutyCycle' requires a positive active time shorter than the period", ErrInvalidOption)
+	}
+
+	c.window = active
+	c.triggers = append(c.triggers, &dutyCycle{active: active, period: period})
+	return c
+}
+
+// DutyCycle is a trigger which runs an action once in each period,
+// at a random offset which leaves room for it to run for `active`.
+type dutyCycle struct {
+	active, period time.Duration
+	closer         chan struct{}
+	done           chan struct{}
+}
+
+func (d *dutyCycle) String() string {
+	return fmt.Sprintf("for %s of every %s", d.active, d.period)
+}
+
+func (d *dutyCycle) source() TriggerSource {
+	return SourceSchedule
+}
+
+func (d *dutyCycle) clone() trigger {
+	return &dutyCycle{active: d.active, period: d.period}
+}
+
+func (d *dutyCycle) end() {
+	close(d.closer)
+	<-d.done
+}
+
+func (d *dutyCycle) start(fn func()) error {
+	d.closer = make(chan struct{})
+	d.done = make(chan struct{})
+	supervise(d, d.closer, d.done, func() error { d.run(fn); return nil })
+
+	return nil
+}
+
+func (d *dutyCycle) run(fn func()) {
+	for start := time.Now(); ; start = start.Add(d.period) {
+		offset := time.Duration(rand.Int63n(int64(d.period-d.active) + 1))
+		if !d.sleepUntil(start.Add(offset)) {
+			return
+		}
+
+		fn()
+
+		// A run which overran its period delays the next one, rather
+		// than starting a backlog of them.
+		if end := start.Add(d.period); time.Now().After(end) {
+			start = time.Now().Add(-d.period)
+		} else if !d.sleepUntil(end) {
+			return
+		}
+	}
+}
+
+// Waits until the time, returning false if the trigger ended first.
+func (d *dutyCycle) sleepUntil(t time.Time) bool {
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+
+	select {
+	case <-d.closer:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
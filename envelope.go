@@ -0,0 +1,50 @@
+package profile
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+	"unicode/utf8"
+)
+
+// An envelope wraps the output of a run as a single line of JSON.
+type envelope struct {
+	Name string    `json:"name"`
+	Ext  string    `json:"ext"`
+	Host string    `json:"host,omitempty"`
+	Time time.Time `json:"time"`
+	// The size of the payload, before any encoding.
+	Bytes int `json:"bytes"`
+	// Encoding is "text" if the payload is inline UTF-8 text, or
+	// "base64" if it's binary.
+	Encoding string `json:"encoding"`
+	Payload  string `json:"payload"`
+}
+
+// Wraps the function so that it writes its output as an envelope,
+// followed by a newline.
+func envelop(name, ext string, fn func(io.Writer) error) func(io.Writer) error {
+	host, _ := os.Hostname()
+	return func(w io.Writer) error {
+		e := envelope{Name: name, Ext: ext, Host: host, Time: time.Now()}
+		return buffered(fn, func(buf *bytes.Buffer) error {
+			e.Bytes = buf.Len()
+			if utf8.Valid(buf.Bytes()) {
+				e.Encoding, e.Payload = "text", buf.String()
+			} else {
+				e.Encoding, e.Payload = "base64", base64.StdEncoding.EncodeToString(buf.Bytes())
+			}
+
+			data, err := json.Marshal(&e)
+			if err != nil {
+				return err
+			}
+
+			_, err = w.Write(append(data, '\n'))
+			return err
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package profile
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestJSONLinesEnvelopesOutput(t *testing.T) {
+	buf := new(bytes.Buffer)
+	a := newOutputAction("goroutine", "line one\nline two\n").JSONLines().ToWriter(buf)
+	assert.Nil(t, a.Trigger(context.Background()))
+	assert.Nil(t, a.Trigger(context.Background()))
+
+	lines := bytes.Split(bytes.TrimSuffix(buf.Bytes(), []byte("\n")), []byte("\n"))
+	assert.Len(t, lines, 2)
+
+	var e envelope
+	assert.Nil(t, json.Unmarshal(lines[0], &e))
+	assert.Equal(t, "goroutine", e.Name)
+	assert.Equal(t, "out", e.Ext)
+	assert.Equal(t, "text", e.Encoding)
+	assert.Equal(t, "line one\nline two\n", e.Payload)
+	assert.Equal(t, 18, e.Bytes)
+}
+
+func TestJSONLinesEncodesBinary(t *testing.T) {
+	buf := new(bytes.Buffer)
+	assert.Nil(t, newOutputAction("heap", "\x1f\x8b\xff").JSONLines().ToWriter(buf).Trigger(context.Background()))
+
+	var e envelope
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &e))
+	assert.Equal(t, "base64", e.Encoding)
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("\x1f\x8b\xff")), e.Payload)
+}
@@ -0,0 +1,74 @@
+package profile
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrorCounter counts notable errors, such as timeouts, so that a
+// burst of them produces evidence automatically. Applications call
+// Inc for each error, and actions given the counter with OnErrors run
+// when the number of errors in the sliding window reaches the
+// threshold: "50 timeouts in a minute" is
+//
+//	timeouts := profile.NewErrorCounter(50, time.Minute)
+//	profile.DumpGoroutine().OnErrors(timeouts).ToDir(dir)
+//
+// The actions run once when the threshold is crossed, and not again
+// until the rate has dropped below it and crossed it again.
+type ErrorCounter struct {
+	threshold int
+	window    time.Duration
+
+	mu sync.Mutex
+	// times holds the times of the latest threshold errors, oldest at
+	// next. The threshold is reached when the oldest is in the window.
+	times  []time.Time
+	next   int
+	armed  bool
+	events eventSource
+}
+
+// Creates a counter which fires when threshold errors happen within
+// the window.
+func NewErrorCounter(threshold int, window time.Duration) *ErrorCounter {
+	if threshold < 1 {
+		threshold = 1
+	}
+
+	return &ErrorCounter{
+		threshold: threshold,
+		window:    window,
+		times:     make([]time.Time, threshold),
+		armed:     true,
+	}
+}
+
+// Counts an error. It's safe to call concurrently.
+func (e *ErrorCounter) Inc() {
+	now := time.Now()
+
+	e.mu.Lock()
+	e.times[e.next] = now
+	e.next = (e.next + 1) % e.threshold
+	oldest := e.times[e.next]
+	reached := !oldest.IsZero() && now.Sub(oldest) < e.window
+	fire := reached && e.armed
+	e.armed = !reached
+	e.mu.Unlock()
+
+	if fire {
+		e.events.notify()
+	}
+}
+
+// `OnErrors` runs the action when the counter's errors reach its
+// threshold. See ErrorCounter.
+func (b *BaseAction) OnErrors(e *ErrorCounter) *BaseAction {
+	b.triggers = append(b.triggers, &eventWatcher{
+		events: &e.events,
+		desc:   fmt.Sprintf("when %d errors happen within %s", e.threshold, e.window),
+	})
+	return b
+}
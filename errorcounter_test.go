@@ -0,0 +1,48 @@
+package profile
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestErrorCounterFiresOnCrossing(t *testing.T) {
+	e := NewErrorCounter(3, time.Hour)
+	a, runs := newCountingAction()
+	assert.Nil(t, a.OnErrors(e).Start())
+	defer a.End()
+
+	e.Inc()
+	e.Inc()
+	time.Sleep(20 * time.Millisecond)
+	assert.EqualValues(t, 0, atomic.LoadInt32(runs))
+
+	e.Inc()
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(runs) == 1 }, time.Second, time.Millisecond)
+
+	// Errors continue above the threshold, which doesn't fire again.
+	e.Inc()
+	e.Inc()
+	time.Sleep(20 * time.Millisecond)
+	assert.EqualValues(t, 1, atomic.LoadInt32(runs))
+}
+
+func TestErrorCounterRearmsBelowThreshold(t *testing.T) {
+	e := NewErrorCounter(2, 50*time.Millisecond)
+	a, runs := newCountingAction()
+	assert.Nil(t, a.OnErrors(e).Start())
+	defer a.End()
+
+	e.Inc()
+	e.Inc()
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(runs) == 1 }, time.Second, time.Millisecond)
+
+	// Errors spread out further than the window don't reach it.
+	time.Sleep(60 * time.Millisecond)
+	e.Inc()
+	time.Sleep(60 * time.Millisecond)
+	e.Inc()
+	e.Inc()
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(runs) == 2 }, time.Second, time.Millisecond)
+}
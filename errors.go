@@ -0,0 +1,49 @@
+package profile
+
+import (
+	"errors"
+)
+
+// Errors returned by Swat. Callers can compare against these with
+// errors.Is to handle particular failures programmatically.
+var (
+	// Returned from Start when both `At` and `After` are used.
+	ErrConflictingStart = errors.New("Swat Error: Using both 'At' and 'After' will lead to unexepected results.")
+	// Returned from Start when both `Until` and `For` are used.
+	ErrConflictingEnd = errors.New("Swat Error: Using both 'Until' and 'For' will lead to unexepected results.")
+	// Returned from Start when `Until` or `For` is used without `Every`.
+	ErrMissingInterval = errors.New("Swat Error: 'Every' is required when using 'Until' or 'For'.")
+	// Returned from Start when an option was given an invalid value.
+	// The returned error wraps this one, describing the option.
+	ErrInvalidOption = errors.New("Swat Error: invalid option")
+	// Returned from Start when the action is already running.
+	ErrAlreadyStarted = errors.New("Swat Error: the action has already been started.")
+	// Returned from pprof actions when no profile with the given name
+	// exists. The returned error wraps this one, naming the profile.
+	ErrUnknownProfile = errors.New("Swat Error: unknown pprof")
+	// Passed to OnError when an action is disabled by
+	// StopAfterFailures. The passed error wraps this one.
+	ErrTooManyFailures = errors.New("Swat Error: action disabled after too many failures")
+	// Returned when a dump is too large for the sink it's written to.
+	// The returned error wraps this one, giving the sizes.
+	ErrTooLarge = errors.New("Swat Error: dump too large")
+	// Returned when an action can't run because it has ended.
+	ErrNotRunning = errors.New("Swat Error: the action isn't running.")
+	// Returned when the data an action or condition reads, such as a
+	// file in procfs or a profile to convert, can't be parsed. The
+	// returned error wraps this one, naming the data.
+	ErrUnexpectedFormat = errors.New("Swat Error: unexpected format")
+	// Returned when the data an action or condition reads isn't
+	// available, such as build information in binaries built without
+	// it. The returned error wraps this one, naming the data.
+	ErrUnavailable = errors.New("Swat Error: not available")
+	// Returned when a feature isn't supported on the current platform.
+	ErrUnsupported = errors.New("Swat Error: not supported on this platform")
+	// Returned by Healthy when an action isn't running, one of its
+	// triggers has stopped, or its output can't be written. The
+	// returned error wraps this one, giving the reason.
+	ErrUnhealthy = errors.New("Swat Error: unhealthy")
+	// Passed to ErrorReporters when an action panics. The passed error
+	// wraps this one, giving the panic's value.
+	ErrPanicked = errors.New("Swat Error: action panicked")
+)
@@ -0,0 +1,121 @@
+package profile
+
+import (
+	"fmt"
+	"sync"
+)
+
+// The provider ETW uses when none is given. Sessions enable it with,
+// for example:
+//
+//	logman create trace swat -p {0eb6dc76-50c8-4f5d-a7d1-0c5731aa856b} -o swat.etl
+const DefaultETWProvider = "0eb6dc76-50c8-4f5d-a7d1-0c5731aa856b"
+
+// ETW emits an Event Tracing for Windows event when each run of an
+// action starts, when it stops, and when it fails, so that Swat's
+// activity shows up in Windows Performance Analyzer alongside system
+// traces. Events are strings, such as "heap run 3 started (signal)",
+// logged at the informational level, or the error level for failures.
+// They're only delivered while a trace session has the provider
+// enabled, and are otherwise discarded cheaply.
+type ETW struct {
+	mu     sync.Mutex
+	handle uint64
+	closed bool
+}
+
+// Registers a provider with the GUID, like DefaultETWProvider, which
+// may be surrounded by braces. It's only supported on Windows;
+// elsewhere it returns ErrUnsupported.
+func NewETW(provider string) (*ETW, error) {
+	g, err := parseGUID(provider)
+	if err != nil {
+		return nil, err
+	}
+
+	handle, err := registerETW(&g)
+	if err != nil {
+		return nil, fmt.Errorf("registering ETW provider: %w", err)
+	}
+
+	return &ETW{handle: handle}, nil
+}
+
+// Unregisters the provider. Events emitted afterwards are dropped.
+func (e *ETW) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return nil
+	}
+
+	e.closed = true
+	return unregisterETW(e.handle)
+}
+
+// ETW levels, from evntrace.h.
+const (
+	etwLevelError = 2
+	etwLevelInfo  = 4
+)
+
+func (e *ETW) started(info RunInfo) {
+	e.write(etwLevelInfo, fmt.Sprintf("%s run %d started (%s)", info.Name, info.Index, info.Source))
+}
+
+func (e *ETW) stopped(info RunInfo, err error) {
+	if err != nil {
+		e.write(etwLevelError, fmt.Sprintf("%s run %d failed after %s: %s", info.Name, info.Index, info.Duration, err))
+		return
+	}
+
+	e.write(etwLevelInfo, fmt.Sprintf("%s run %d stopped after %s, writing %d bytes", info.Name, info.Index, info.Duration, info.Bytes))
+}
+
+// Events are emitted on a best-effort basis, like metrics.
+func (e *ETW) write(level uint8, msg string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.closed {
+		writeETW(e.handle, level, msg)
+	}
+}
+
+// `EmitETW` emits ETW events for every run of the action, whatever
+// triggered it. See ETW for the events.
+func (b *BaseAction) EmitETW(e *ETW) *BaseAction {
+	b.etw = e
+	return b
+}
+
+// A Windows GUID, laid out as it is in memory.
+type guid struct {
+	data1 uint32
+	data2 uint16
+	data3 uint16
+	data4 [8]byte
+}
+
+// Parses a GUID in its canonical form, like
+// "0eb6dc76-50c8-4f5d-a7d1-0c5731aa856b", with or without braces.
+func parseGUID(s string) (guid, error) {
+	var g guid
+	if len(s) == 38 && s[0] == '{' && s[37] == '}' {
+		s = s[1:37]
+	}
+
+	var d4 [2]uint8
+	var d5 [6]uint8
+	n, err := fmt.Sscanf(s, "%08x-%04x-%04x-%02x%02x-%02x%02x%02x%02x%02x%02x",
+		&g.data1, &g.data2, &g.data3, &d4[0], &d4[1],
+		&d5[0], &d5[1], &d5[2], &d5[3], &d5[4], &d5[5])
+	if err != nil || n != 11 || len(s) != 36 {
+		return guid{}, fmt.Errorf("%w: %q isn't a GUID", ErrInvalidOption, s)
+	}
+
+	copy(g.data4[:2], d4[:])
+	copy(g.data4[2:], d5[:])
+	return g, nil
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package profile
+
+// ETW only exists on Windows.
+func registerETW(provider *guid) (uint64, error) {
+	return 0, ErrUnsupported
+}
+
+func unregisterETW(handle uint64) error {
+	return nil
+}
+
+func writeETW(handle uint64, level uint8, msg string) {}
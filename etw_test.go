@@ -0,0 +1,31 @@
+package profile
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"runtime"
+	"testing"
+)
+
+func TestParseGUID(t *testing.T) {
+	want := guid{0x0eb6dc76, 0x50c8, 0x4f5d, [8]byte{0xa7, 0xd1, 0x0c, 0x57, 0x31, 0xaa, 0x85, 0x6b}}
+	for _, s := range []string{DefaultETWProvider, "{" + DefaultETWProvider + "}", "0EB6DC76-50C8-4F5D-A7D1-0C5731AA856B"} {
+		g, err := parseGUID(s)
+		assert.Nil(t, err, s)
+		assert.Equal(t, want, g, s)
+	}
+
+	for _, s := range []string{"", "0eb6dc76-50c8-4f5d-a7d1-0c5731aa856", "0eb6dc76-50c8-4f5d-a7d1-0c5731aa856b0", "0eb6dc7650c84f5da7d10c5731aa856b"} {
+		_, err := parseGUID(s)
+		assert.True(t, errors.Is(err, ErrInvalidOption), s)
+	}
+}
+
+func TestETWUnsupportedOffWindows(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("ETW is supported")
+	}
+
+	_, err := NewETW(DefaultETWProvider)
+	assert.True(t, errors.Is(err, ErrUnsupported))
+}
@@ -0,0 +1,59 @@
+package profile
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// The syscall package doesn't wrap the event provider calls.
+var (
+	advapi32             = syscall.NewLazyDLL("advapi32.dll")
+	procEventRegister    = advapi32.NewProc("EventRegister")
+	procEventUnregister  = advapi32.NewProc("EventUnregister")
+	procEventWriteString = advapi32.NewProc("EventWriteString")
+)
+
+// Registration handles are 64 bits, so on 32-bit platforms they're
+// passed as two words, low first.
+func handleArgs(handle uint64) []uintptr {
+	if unsafe.Sizeof(uintptr(0)) == 8 {
+		return []uintptr{uintptr(handle)}
+	}
+
+	return []uintptr{uintptr(uint32(handle)), uintptr(handle >> 32)}
+}
+
+func registerETW(provider *guid) (uint64, error) {
+	if err := procEventRegister.Find(); err != nil {
+		return 0, err
+	}
+
+	var handle uint64
+	r, _, _ := procEventRegister.Call(uintptr(unsafe.Pointer(provider)), 0, 0, uintptr(unsafe.Pointer(&handle)))
+	if r != 0 {
+		return 0, syscall.Errno(r)
+	}
+
+	return handle, nil
+}
+
+func unregisterETW(handle uint64) error {
+	if r, _, _ := procEventUnregister.Call(handleArgs(handle)...); r != 0 {
+		return syscall.Errno(r)
+	}
+
+	return nil
+}
+
+func writeETW(handle uint64, level uint8, msg string) {
+	p, err := syscall.UTF16PtrFromString(msg)
+	if err != nil {
+		return
+	}
+
+	// The keyword is zero, which matches every session's filter.
+	args := append(handleArgs(handle), uintptr(level))
+	args = append(args, handleArgs(0)...)
+	args = append(args, uintptr(unsafe.Pointer(p)))
+	procEventWriteString.Call(args...)
+}
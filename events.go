@@ -0,0 +1,97 @@
+package profile
+
+import (
+	"sync"
+	"time"
+)
+
+// EventSource wakes the actions watching it, for triggers which are
+// fed by application code, like LatencyTrigger. The zero value has
+// no watchers.
+type eventSource struct {
+	mu       sync.Mutex
+	watchers map[*eventWatcher]chan struct{}
+}
+
+// Wakes each watcher, without blocking. Wakes which arrive while a
+// watcher is busy are coalesced.
+func (e *eventSource) notify() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, ch := range e.watchers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (e *eventSource) watch(w *eventWatcher) <-chan struct{} {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.watchers == nil {
+		e.watchers = map[*eventWatcher]chan struct{}{}
+	}
+
+	ch := make(chan struct{}, 1)
+	e.watchers[w] = ch
+	return ch
+}
+
+func (e *eventSource) unwatch(w *eventWatcher) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	delete(e.watchers, w)
+}
+
+// EventWatcher is the trigger an action watching an eventSource is
+// given. When woken, it runs the action if check, if set, holds, and
+// the action isn't cooling down.
+type eventWatcher struct {
+	events   *eventSource
+	desc     string
+	check    func() bool
+	cooldown time.Duration
+	closer   chan struct{}
+	done     chan struct{}
+}
+
+func (w *eventWatcher) String() string {
+	return w.desc
+}
+
+func (w *eventWatcher) clone() trigger {
+	return &eventWatcher{events: w.events, desc: w.desc, check: w.check, cooldown: w.cooldown}
+}
+
+func (w *eventWatcher) end() {
+	w.events.unwatch(w)
+	close(w.closer)
+	<-w.done
+}
+
+func (w *eventWatcher) start(fn func()) error {
+	ch := w.events.watch(w)
+	w.closer = make(chan struct{})
+	w.done = make(chan struct{})
+	c := newCooldown(w.cooldown)
+	supervise(w, w.closer, w.done, func() error { w.run(ch, c.wrap(fn)); return nil })
+
+	return nil
+}
+
+func (w *eventWatcher) run(ch <-chan struct{}, fn func()) {
+	for {
+		select {
+		case <-w.closer:
+			return
+		case <-ch:
+			if w.check == nil || w.check() {
+				fn()
+			}
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package profile
+
+import (
+	"expvar"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The "swat" map published with expvar, created when a Swat first
+// publishes to it, so that importing the package publishes nothing.
+// Keys in it are owned by the Swat which published them, so that
+// Swats sharing the process don't replace or remove each other's.
+var (
+	expvarOnce   sync.Once
+	expvarMap    *expvar.Map
+	expvarMu     sync.Mutex
+	expvarOwners = map[string]*Swat{}
+)
+
+func swatVars() *expvar.Map {
+	expvarOnce.Do(func() {
+		if m, ok := expvar.Get("swat").(*expvar.Map); ok {
+			expvarMap = m
+		} else {
+			expvarMap = expvar.NewMap("swat")
+		}
+	})
+
+	return expvarMap
+}
+
+// The status of an action, as published with expvar.
+type expvarStatus struct {
+	// The version of the status's format; see SchemaVersion.
+	SchemaVersion int
+	Runs          uint64
+	Errors        uint64
+	LastRun       time.Time
+	LastError     string `json:",omitempty"`
+}
+
+// Publishes the status of each action, its run count, error count, and
+// the time and error of its latest run, as expvar variables in a map
+// named "swat", keyed by the actions' names, so that scrapers of
+// /debug/vars pick up Swat's health with no extra wiring. Actions
+// which share a name, whether in this Swat or another one published
+// in the process, have "#2", "#3" and so on appended. The status is
+// read whenever the variables are, and the actions are removed from
+// the map when the Swat ends. It must be called before the actions are
+// booted.
+func (s *Swat) PublishExpvar() *Swat {
+	s.expvar = true
+	return s
+}
+
+// Adds the action to the "swat" map, under a name no other action
+// has.
+func (s *Swat) publish(action Action) {
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+
+	name := "action"
+	if n, ok := action.(named); ok && n.actionName() != "" {
+		name = n.actionName()
+	}
+
+	key := name
+	for i := 2; expvarOwners[key] != nil; i++ {
+		key = name + "#" + strconv.Itoa(i)
+	}
+	expvarOwners[key] = s
+	s.expvarKeys = append(s.expvarKeys, key)
+
+	swatVars().Set(key, expvar.Func(func() interface{} {
+		a, ok := action.(statused)
+		if !ok {
+			return expvarStatus{SchemaVersion: SchemaVersion}
+		}
+
+		status := a.Status()
+		v := expvarStatus{
+			SchemaVersion: SchemaVersion,
+			Runs:          status.Runs,
+			Errors:        status.Errors,
+			LastRun:       status.LastRun,
+		}
+		if status.LastError != nil {
+			v.LastError = status.LastError.Error()
+		}
+		return v
+	}))
+}
+
+// Removes the Swat's actions from the "swat" map.
+func (s *Swat) unpublish() {
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+
+	for _, key := range s.expvarKeys {
+		if expvarOwners[key] == s {
+			delete(expvarOwners, key)
+			swatVars().Delete(key)
+		}
+	}
+	s.expvarKeys = nil
+}
@@ -0,0 +1,48 @@
+package profile
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishExpvar(t *testing.T) {
+	heap, _ := newCountingAction()
+	heap.Name("expvar-heap").Every(time.Hour).After(time.Hour)
+	other, _ := newCountingAction()
+	other.Name("expvar-heap").Every(time.Hour).After(time.Hour)
+	s := new(Swat).PublishExpvar()
+	assert.Nil(t, s.Boot([]Action{heap, other}))
+	assert.Nil(t, heap.Trigger(context.Background()))
+
+	vars, ok := expvar.Get("swat").(*expvar.Map)
+	assert.True(t, ok)
+
+	var status expvarStatus
+	assert.Nil(t, json.Unmarshal([]byte(vars.Get("expvar-heap").String()), &status))
+	assert.Equal(t, SchemaVersion, status.SchemaVersion)
+	assert.Equal(t, uint64(1), status.Runs)
+	assert.False(t, status.LastRun.IsZero())
+	var unrun expvarStatus
+	assert.Nil(t, json.Unmarshal([]byte(vars.Get("expvar-heap#2").String()), &unrun))
+	assert.Equal(t, expvarStatus{SchemaVersion: SchemaVersion}, unrun)
+
+	// Another Swat's action with the same name gets its own key, and
+	// ending either Swat leaves the other's alone.
+	again, _ := newCountingAction()
+	again.Name("expvar-heap").Every(time.Hour).After(time.Hour)
+	s2 := new(Swat).PublishExpvar()
+	assert.Nil(t, s2.Boot([]Action{again}))
+	assert.NotNil(t, vars.Get("expvar-heap#3"))
+	s2.End()
+	assert.Nil(t, vars.Get("expvar-heap#3"))
+	assert.NotNil(t, vars.Get("expvar-heap"))
+
+	s.End()
+	assert.Nil(t, vars.Get("expvar-heap"))
+	assert.Nil(t, vars.Get("expvar-heap#2"))
+}
@@ -0,0 +1,70 @@
+package profile
+
+import (
+	"io"
+	"time"
+)
+
+// The runtime's flight recorder, which is unsupported before Go 1.25.
+type flightRecorder interface {
+	Start() error
+	Stop()
+	Enabled() bool
+	WriteTo(w io.Writer) (int64, error)
+}
+
+// FlightRecorderAction is an action which traces execution
+// continuously into a bounded in-memory window while it's running,
+// and dumps the window whenever it's triggered. This captures the
+// moments before an anomaly, rather than after it.
+type FlightRecorderAction struct {
+	Builder[*FlightRecorderAction]
+	rec flightRecorder
+}
+
+// Returns an action that dumps the last `window` of the execution
+// trace, keeping at most about `maxBytes` of it in memory; either may
+// be zero to use the runtime's defaults. The trace can be viewed with
+// `go tool trace`. Combine it with a signal or condition trigger, as
+// in `DumpFlightRecorder(5*time.Second, 0).When(slow, time.Second)`.
+// Only one flight recorder may run in a process at once, and Start
+// returns ErrUnsupported before Go 1.25.
+func DumpFlightRecorder(window time.Duration, maxBytes uint64) *FlightRecorderAction {
+	f := &FlightRecorderAction{rec: newFlightRecorder(window, maxBytes)}
+	b := NewAction(func(w io.Writer) error {
+		_, err := f.rec.WriteTo(w)
+		return err
+	}).Name("flightrecorder")
+	b.ext = "trace"
+
+	f.Builder = Extend(b, f)
+	return f
+}
+
+// Implements Action.Start, starting the recorder before the action's
+// triggers, so that it's recording by the time they fire.
+func (f *FlightRecorderAction) Start() error {
+	if f.rec.Enabled() {
+		return ErrAlreadyStarted
+	}
+
+	if err := f.rec.Start(); err != nil {
+		return err
+	}
+
+	if err := f.BaseAction.Start(); err != nil {
+		f.rec.Stop()
+		return err
+	}
+
+	return nil
+}
+
+// Implements Action.End, stopping the recorder once the action's
+// triggers have ended.
+func (f *FlightRecorderAction) End() {
+	f.BaseAction.End()
+	if f.rec.Enabled() {
+		f.rec.Stop()
+	}
+}
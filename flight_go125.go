@@ -0,0 +1,13 @@
+//go:build go1.25
+// +build go1.25
+
+package profile
+
+import (
+	"runtime/trace"
+	"time"
+)
+
+func newFlightRecorder(window time.Duration, maxBytes uint64) flightRecorder {
+	return trace.NewFlightRecorder(trace.FlightRecorderConfig{MinAge: window, MaxBytes: maxBytes})
+}
@@ -0,0 +1,21 @@
+//go:build !go1.25
+// +build !go1.25
+
+package profile
+
+import (
+	"io"
+	"time"
+)
+
+func newFlightRecorder(window time.Duration, maxBytes uint64) flightRecorder {
+	return unsupportedRecorder{}
+}
+
+// Stands in for the flight recorder before Go 1.25.
+type unsupportedRecorder struct{}
+
+func (unsupportedRecorder) Start() error                     { return ErrUnsupported }
+func (unsupportedRecorder) Stop()                            {}
+func (unsupportedRecorder) Enabled() bool                    { return false }
+func (unsupportedRecorder) WriteTo(io.Writer) (int64, error) { return 0, ErrUnsupported }
@@ -0,0 +1,25 @@
+package profile
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestFlightRecorderDumpsWindow(t *testing.T) {
+	buf := new(bytes.Buffer)
+	f := DumpFlightRecorder(time.Second, 0).ToWriter(buf)
+	if err := f.Start(); err == ErrUnsupported {
+		t.Skip("the flight recorder needs Go 1.25")
+	} else {
+		assert.Nil(t, err)
+	}
+	defer f.End()
+	assert.Equal(t, ErrAlreadyStarted, f.Start())
+
+	time.Sleep(10 * time.Millisecond)
+	assert.Nil(t, f.Trigger(context.Background()))
+	assert.True(t, bytes.HasPrefix(buf.Bytes(), []byte("go 1.")))
+}
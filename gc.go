@@ -0,0 +1,100 @@
+package profile
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/metrics"
+	"sync/atomic"
+)
+
+const gcCyclesMetric = "/gc/cycles/total:gc-cycles"
+
+// A sentinel is allocated and abandoned so that its finalizer tells
+// us when a garbage collection has happened. It's large enough not
+// to be batched by the tiny allocator, which would delay finalizers.
+type gcSentinel struct {
+	_ [32]byte
+}
+
+// GcWatcher is a trigger which runs an action after every nth
+// garbage collection.
+type gcWatcher struct {
+	every uint64
+	// generation is bumped every time the watcher starts or ends, so
+	// that sentinels armed by a previous run stop re-arming.
+	generation uint64
+	gcs        chan struct{}
+	closer     chan struct{}
+	done       chan struct{}
+}
+
+func newGCWatcher(every int) *gcWatcher {
+	return &gcWatcher{every: uint64(every)}
+}
+
+// Registers a sentinel which notifies the watcher once it's been
+// collected, and then re-registers itself.
+func (g *gcWatcher) arm(generation uint64, gcs chan struct{}) {
+	runtime.SetFinalizer(new(gcSentinel), func(*gcSentinel) {
+		if atomic.LoadUint64(&g.generation) != generation {
+			return
+		}
+
+		select {
+		case gcs <- struct{}{}:
+		default:
+		}
+
+		g.arm(generation, gcs)
+	})
+}
+
+// Returns the total number of completed GC cycles.
+func gcCycles() uint64 {
+	sample := []metrics.Sample{{Name: gcCyclesMetric}}
+	metrics.Read(sample)
+	return sample[0].Value.Uint64()
+}
+
+func (g *gcWatcher) String() string {
+	if g.every == 1 {
+		return "after every GC"
+	}
+
+	return fmt.Sprintf("after every %d GCs", g.every)
+}
+
+func (g *gcWatcher) clone() trigger {
+	return newGCWatcher(int(g.every))
+}
+
+func (g *gcWatcher) end() {
+	atomic.AddUint64(&g.generation, 1)
+	close(g.closer)
+	<-g.done
+}
+
+func (g *gcWatcher) start(fn func()) error {
+	g.gcs = make(chan struct{}, 1)
+	g.closer = make(chan struct{})
+	g.done = make(chan struct{})
+	g.arm(atomic.AddUint64(&g.generation, 1), g.gcs)
+	next := (gcCycles()/g.every + 1) * g.every
+	supervise(g, g.closer, g.done, func() error { g.run(fn, next); return nil })
+
+	return nil
+}
+
+func (g *gcWatcher) run(fn func(), next uint64) {
+	for {
+		select {
+		case <-g.closer:
+			return
+		case <-g.gcs:
+			if cycles := gcCycles(); cycles >= next {
+				next = (cycles/g.every + 1) * g.every
+				fn()
+			}
+		}
+	}
+}
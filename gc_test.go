@@ -0,0 +1,28 @@
+package profile
+
+import (
+	"github.com/stretchr/testify/assert"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGCWatcherRunsEveryNth(t *testing.T) {
+	var runs int32
+	start := gcCycles()
+	g := newGCWatcher(2)
+	g.start(func() { atomic.AddInt32(&runs, 1) })
+	defer g.end()
+
+	assert.Eventually(t, func() bool {
+		runtime.GC()
+		return atomic.LoadInt32(&runs) >= 3
+	}, 10*time.Second, time.Millisecond)
+
+	// Other collections may happen alongside ours, but never fewer than
+	// every other one runs the action.
+	n := uint64(atomic.LoadInt32(&runs))
+	cycles := gcCycles() - start
+	assert.True(t, cycles >= 2*n-1, "%d runs in %d cycles", n, cycles)
+}
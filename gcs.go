@@ -0,0 +1,244 @@
+package profile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GCSSink is a Sink which uploads each dump as an object in a Google
+// Cloud Storage bucket, using the JSON API's simple upload. Swat
+// doesn't handle credentials itself: the client must authorize its
+// requests, as one from golang.org/x/oauth2/google does.
+type GCSSink struct {
+	client   *http.Client
+	bucket   string
+	names    objectNamer
+	endpoint string
+	// chunk, if set, is the size of the chunks of resumable uploads,
+	// which are given attempts tries to make progress.
+	chunk    int64
+	attempts int
+	sleep    func(time.Duration)
+	err      error
+}
+
+// Resumable uploads are sent in multiples of this size, except for
+// their last chunk.
+const gcsChunkQuantum = 256 << 10
+
+// Creates a sink which uploads dumps to the bucket. Objects are named
+// by the text/template, which can use the same fields as
+// ToFileTemplate; if it's empty, "{{.Name}}/{{.Time}}.{{.Ext}}" is
+// used. A nil client uses http.DefaultClient, which only suits buckets
+// which don't need credentials.
+func NewGCSSink(client *http.Client, bucket, pattern string) (*GCSSink, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	names, err := newObjectNamer(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GCSSink{
+		client:   client,
+		bucket:   bucket,
+		names:    names,
+		endpoint: "https://storage.googleapis.com",
+		sleep:    time.Sleep,
+	}, nil
+}
+
+// Uploads dumps larger than the chunk size with GCS's resumable
+// upload protocol, so that multi-hundred-megabyte traces can be
+// shipped reliably over flaky networks. Dumps are sent a chunk at a
+// time, and when a chunk fails with a network error or a 5xx or 429
+// response, the sink waits, asks GCS how much it has received, and
+// resumes from there. It waits a second after the first failure, and
+// twice as long after each one after that, and gives up once
+// `attempts` tries in a row fail to make progress. The chunk size is
+// rounded up to a multiple of 256 KiB, as GCS requires; 8 MiB or more
+// is a good choice. Smaller dumps are uploaded in a single request.
+func (g *GCSSink) Resumable(chunk int64, attempts int) *GCSSink {
+	if chunk < 1 || attempts < 1 {
+		g.err = fmt.Errorf("%w: 'Resumable' requires a positive chunk size and attempts", ErrInvalidOption)
+	}
+
+	g.chunk = (chunk + gcsChunkQuantum - 1) / gcsChunkQuantum * gcsChunkQuantum
+	g.attempts = attempts
+	return g
+}
+
+// Implements invalidSink.
+func (g *GCSSink) invalid() error {
+	return g.err
+}
+
+func (g *GCSSink) String() string {
+	return "gs://" + g.bucket + "/" + g.names.tmpl.Root.String()
+}
+
+// Implements Sink.Open. The dump is uploaded once the writer is
+// closed, and closing it returns any error from the upload.
+func (g *GCSSink) Open(info RunInfo) (io.WriteCloser, error) {
+	if g.err != nil {
+		return nil, g.err
+	}
+
+	return &bufferedWriter{info: info, flush: g.upload}, nil
+}
+
+func (g *GCSSink) upload(info RunInfo, data []byte) error {
+	name, err := g.names.name(info)
+	if err != nil {
+		return err
+	}
+
+	if g.chunk > 0 && int64(len(data)) > g.chunk {
+		return g.uploadResumable(info, name, data)
+	}
+
+	req, err := http.NewRequest("POST", g.uploadURL("media", name), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", contentType(info.Ext))
+	return upload(g.client, req)
+}
+
+func (g *GCSSink) uploadURL(kind, name string) string {
+	return g.endpoint + "/upload/storage/v1/b/" + url.PathEscape(g.bucket) +
+		"/o?uploadType=" + kind + "&name=" + url.QueryEscape(name)
+}
+
+func (g *GCSSink) uploadResumable(info RunInfo, name string, data []byte) error {
+	req, err := http.NewRequest("POST", g.uploadURL("resumable", name), nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("X-Upload-Content-Type", contentType(info.Ext))
+	req.Header.Set("X-Upload-Content-Length", strconv.Itoa(len(data)))
+	res, err := send(g.client, req)
+	if err != nil {
+		return err
+	}
+
+	session := res.Header.Get("Location")
+	if res.StatusCode/100 != 2 || session == "" {
+		defer res.Body.Close()
+		return uploadError(req, res)
+	}
+	res.Body.Close()
+
+	// After a failure, GCS is asked how much it received, rather than
+	// sent the next chunk. A chunk which GCS acknowledges without
+	// taking any of it counts as a failure, so that a stuck upload
+	// gives up rather than spinning.
+	var sent int64
+	query, wait := false, time.Second
+	for failures := 0; ; {
+		chunk := data[sent:]
+		if query {
+			chunk = nil
+		} else if int64(len(chunk)) > g.chunk {
+			chunk = chunk[:g.chunk]
+		}
+
+		received, done, err := g.putChunk(session, chunk, sent, int64(len(data)), query)
+		if done {
+			return nil
+		}
+
+		if err == nil {
+			progressed, queried := received > sent, query
+			sent, query = received, false
+			if progressed {
+				failures = 0
+			}
+			if progressed || queried {
+				continue
+			}
+
+			err = &transientError{fmt.Errorf("uploading to GCS: no bytes from offset %d were received", sent)}
+		}
+
+		var transient *transientError
+		if failures++; !errors.As(err, &transient) || failures >= g.attempts {
+			return err
+		}
+
+		g.sleep(wait)
+		wait *= 2
+		query = true
+	}
+}
+
+// Sends a chunk of a resumable upload starting at the offset, or with
+// query, asks how much has been received. It returns how much of the
+// dump GCS has, and whether the upload is done.
+func (g *GCSSink) putChunk(session string, chunk []byte, offset, total int64, query bool) (int64, bool, error) {
+	req, err := http.NewRequest("PUT", session, bytes.NewReader(chunk))
+	if err != nil {
+		return 0, false, err
+	}
+
+	if query {
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes */%d", total))
+	} else {
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+int64(len(chunk))-1, total))
+	}
+
+	res, err := send(g.client, req)
+	if err != nil {
+		return 0, false, &transientError{err}
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode/100 == 2:
+		io.Copy(io.Discard, res.Body)
+		return total, true, nil
+	case res.StatusCode == 308:
+		// Range holds the bytes received, as "bytes=0-1023", and is
+		// missing if there are none.
+		io.Copy(io.Discard, res.Body)
+		r := res.Header.Get("Range")
+		if r == "" {
+			return 0, false, nil
+		}
+
+		last, err := strconv.ParseInt(r[strings.LastIndex(r, "-")+1:], 10, 64)
+		if err != nil {
+			return 0, false, fmt.Errorf("%w: Range %q from GCS", ErrUnexpectedFormat, r)
+		}
+
+		return last + 1, false, nil
+	case res.StatusCode/100 == 5 || res.StatusCode == http.StatusTooManyRequests:
+		return 0, false, &transientError{uploadError(req, res)}
+	default:
+		return 0, false, uploadError(req, res)
+	}
+}
+
+// An error which may go away if the request is tried again.
+type transientError struct {
+	err error
+}
+
+func (t *transientError) Error() string {
+	return t.err.Error()
+}
+
+func (t *transientError) Unwrap() error {
+	return t.err
+}
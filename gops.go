@@ -0,0 +1,353 @@
+package profile
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"runtime/pprof"
+	"runtime/trace"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The commands of the gops agent protocol. Each connection sends one
+// of them as a single byte, and reads the reply until the agent closes
+// the connection.
+const (
+	gopsStackTrace   byte = 0x1
+	gopsGC           byte = 0x2
+	gopsMemStats     byte = 0x3
+	gopsVersion      byte = 0x4
+	gopsHeapProfile  byte = 0x5
+	gopsCPUProfile   byte = 0x6
+	gopsStats        byte = 0x7
+	gopsTrace        byte = 0x8
+	gopsSetGCPercent byte = 0x10
+)
+
+// How long the CPU profiles and traces asked for by gops last, as in
+// the gops agent. They're variables so that tests can shorten them.
+var (
+	gopsCPUWindow   = 30 * time.Second
+	gopsTraceWindow = 5 * time.Second
+)
+
+// A GopsAgent listens for the gops CLI, speaking the wire protocol of
+// its agent, so that `gops stack`, `gops memstats`, `gops pprof-heap`,
+// `gops pprof-cpu`, `gops trace` and the rest work against the process
+// without embedding the gops agent itself:
+//
+//	agent := profile.NewGopsAgent("").To(profile.DirSink("/var/dumps"))
+//	if err := agent.Start(); err != nil {
+//		log.Fatal(err)
+//	}
+//	defer agent.End()
+//
+// Like the gops agent, it writes the port it listens on to a file
+// named after the process ID in the gops config directory, which is
+// $GOPS_CONFIG_DIR, or "gops" in the user's config directory, so that
+// gops finds the process by its ID.
+type GopsAgent struct {
+	addr string
+	sink Sink
+	runs uint64
+
+	mu       sync.Mutex
+	ln       net.Listener
+	portFile string
+	closer   chan struct{}
+	wg       sync.WaitGroup
+}
+
+// Creates an agent listening on the address, or on an ephemeral port
+// on the loopback interface if it's empty.
+func NewGopsAgent(addr string) *GopsAgent {
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+
+	return &GopsAgent{addr: addr}
+}
+
+// `To` also writes the stacks, memory stats, profiles and traces sent
+// to gops to the sink, so that whatever an operator looks at by hand
+// is archived alongside the other dumps. The dumps are named after
+// their contents, as the actions which take them are, such as "heap"
+// or "cpu", and have the source SourceManual.
+func (g *GopsAgent) To(sink Sink) *GopsAgent {
+	g.sink = sink
+	return g
+}
+
+// Returns the address the agent is listening on, once it's started.
+func (g *GopsAgent) Addr() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.ln == nil {
+		return g.addr
+	}
+
+	return g.ln.Addr().String()
+}
+
+// Starts listening, and writes the port file.
+func (g *GopsAgent) Start() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.ln != nil {
+		return ErrAlreadyStarted
+	}
+
+	if s, ok := g.sink.(invalidSink); ok {
+		if err := s.invalid(); err != nil {
+			return err
+		}
+	}
+
+	dir, err := gopsConfigDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	ln, err := net.Listen("tcp", g.addr)
+	if err != nil {
+		return err
+	}
+
+	portFile := filepath.Join(dir, strconv.Itoa(os.Getpid()))
+	port := strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+	if err := os.WriteFile(portFile, []byte(port), 0644); err != nil {
+		ln.Close()
+		return err
+	}
+
+	g.ln = ln
+	g.portFile = portFile
+	g.closer = make(chan struct{})
+	g.wg.Add(1)
+	go g.serve(ln, g.closer)
+
+	return nil
+}
+
+// Stops listening, removes the port file, and waits for the replies
+// in progress, cutting short any CPU profile or trace.
+func (g *GopsAgent) End() {
+	g.mu.Lock()
+	if g.ln == nil {
+		g.mu.Unlock()
+		return
+	}
+
+	close(g.closer)
+	g.ln.Close()
+	os.Remove(g.portFile)
+	g.ln = nil
+	g.mu.Unlock()
+
+	g.wg.Wait()
+}
+
+// Returns the directory gops looks for port files in.
+func gopsConfigDir() (string, error) {
+	if dir := os.Getenv("GOPS_CONFIG_DIR"); dir != "" {
+		return dir, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "gops"), nil
+}
+
+func (g *GopsAgent) serve(ln net.Listener, closer chan struct{}) {
+	defer g.wg.Done()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+
+		g.wg.Add(1)
+		go func() {
+			defer g.wg.Done()
+			defer conn.Close()
+
+			if err := g.handle(conn, closer); err != nil {
+				log.Printf("Swat Error: gops: %s", err)
+				fmt.Fprintf(conn, "swat: %s\n", err)
+			}
+		}()
+	}
+}
+
+// Replies to the command read from the connection.
+func (g *GopsAgent) handle(conn net.Conn, closer chan struct{}) error {
+	r := bufio.NewReader(conn)
+	command, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case gopsStackTrace:
+		return g.dump(conn, "goroutine", "txt", func(w io.Writer) error {
+			return pprof.Lookup("goroutine").WriteTo(w, 2)
+		})
+	case gopsGC:
+		runtime.GC()
+		_, err := io.WriteString(conn, "ok")
+		return err
+	case gopsMemStats:
+		return g.dump(conn, "memstats", "txt", writeMemStats)
+	case gopsVersion:
+		_, err := fmt.Fprintln(conn, runtime.Version())
+		return err
+	case gopsHeapProfile:
+		return g.dump(conn, "heap", "pb.gz", pprof.WriteHeapProfile)
+	case gopsCPUProfile:
+		return g.dump(conn, "cpu", "pb.gz", func(w io.Writer) error {
+			if err := pprof.StartCPUProfile(w); err != nil {
+				return err
+			}
+			defer pprof.StopCPUProfile()
+
+			return waitFor(gopsCPUWindow, closer)
+		})
+	case gopsStats:
+		_, err := fmt.Fprintf(conn, "goroutines: %d\nOS threads: %d\nGOMAXPROCS: %d\nnum CPU: %d\n",
+			runtime.NumGoroutine(), pprof.Lookup("threadcreate").Count(), runtime.GOMAXPROCS(0), runtime.NumCPU())
+		return err
+	case gopsTrace:
+		return g.dump(conn, "trace", "trace", func(w io.Writer) error {
+			if err := trace.Start(w); err != nil {
+				return err
+			}
+			defer trace.Stop()
+
+			return waitFor(gopsTraceWindow, closer)
+		})
+	case gopsSetGCPercent:
+		percent, err := binary.ReadVarint(r)
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintf(conn, "New GC percent set to %d. Previous value was %d.\n",
+			percent, debug.SetGCPercent(int(percent)))
+		return err
+	default:
+		return fmt.Errorf("%w: gops command %#x", ErrUnsupported, command)
+	}
+}
+
+// Writes the output of fn to the connection, and to the sink if there
+// is one. Failing to write to the sink doesn't fail the reply.
+func (g *GopsAgent) dump(conn net.Conn, name, ext string, fn func(io.Writer) error) error {
+	if g.sink == nil {
+		return fn(conn)
+	}
+
+	info := RunInfo{
+		Name:   name,
+		Ext:    ext,
+		Index:  atomic.AddUint64(&g.runs, 1),
+		Source: SourceManual,
+		Start:  time.Now(),
+	}
+	wc, err := g.sink.Open(info)
+	if err != nil {
+		log.Printf("Swat Error: gops: opening sink for %s: %s", name, err)
+		return fn(conn)
+	}
+
+	sw := &stickyWriter{w: wc}
+	err = fn(io.MultiWriter(conn, sw))
+	if cerr := wc.Close(); sw.err == nil {
+		sw.err = cerr
+	}
+	if sw.err != nil {
+		log.Printf("Swat Error: gops: writing %s to sink: %s", name, sw.err)
+	}
+
+	return err
+}
+
+// Wraps a writer, recording the first error writing to it and
+// discarding everything written after it, so that a failing sink
+// doesn't cut short the writers it's teed with.
+type stickyWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (s *stickyWriter) Write(p []byte) (int, error) {
+	if s.err == nil {
+		_, s.err = s.w.Write(p)
+	}
+
+	return len(p), nil
+}
+
+// Waits for the duration, or until the closer is closed.
+func waitFor(d time.Duration, closer chan struct{}) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-closer:
+	}
+
+	return nil
+}
+
+func writeMemStats(w io.Writer) error {
+	var s runtime.MemStats
+	runtime.ReadMemStats(&s)
+
+	_, err := fmt.Fprintf(w, "alloc: %d bytes\n"+
+		"total-alloc: %d bytes\n"+
+		"sys: %d bytes\n"+
+		"mallocs: %d\n"+
+		"frees: %d\n"+
+		"heap-alloc: %d bytes\n"+
+		"heap-sys: %d bytes\n"+
+		"heap-idle: %d bytes\n"+
+		"heap-in-use: %d bytes\n"+
+		"heap-released: %d bytes\n"+
+		"heap-objects: %d\n"+
+		"stack-in-use: %d bytes\n"+
+		"stack-sys: %d bytes\n"+
+		"gc-sys: %d bytes\n"+
+		"other-sys: %d bytes\n"+
+		"next-gc: when heap-alloc >= %d bytes\n"+
+		"last-gc: %s\n"+
+		"gc-pause-total: %s\n"+
+		"num-gc: %d\n"+
+		"num-forced-gc: %d\n"+
+		"gc-cpu-fraction: %v\n",
+		s.Alloc, s.TotalAlloc, s.Sys, s.Mallocs, s.Frees,
+		s.HeapAlloc, s.HeapSys, s.HeapIdle, s.HeapInuse, s.HeapReleased, s.HeapObjects,
+		s.StackInuse, s.StackSys, s.GCSys, s.OtherSys, s.NextGC,
+		time.Unix(0, int64(s.LastGC)), time.Duration(s.PauseTotalNs),
+		s.NumGC, s.NumForcedGC, s.GCCPUFraction)
+	return err
+}
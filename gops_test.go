@@ -0,0 +1,112 @@
+package profile
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Sends the command to the agent, as the gops CLI does, and returns
+// the reply.
+func gopsCommand(t *testing.T, addr string, command ...byte) []byte {
+	conn, err := net.Dial("tcp", addr)
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write(command)
+	assert.Nil(t, err)
+	reply, err := io.ReadAll(conn)
+	assert.Nil(t, err)
+	return reply
+}
+
+func TestGopsAgent(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GOPS_CONFIG_DIR", dir)
+	defer func(orig time.Duration) { gopsCPUWindow = orig }(gopsCPUWindow)
+	gopsCPUWindow = 10 * time.Millisecond
+
+	ring := NewRing(10, 1<<30)
+	agent := NewGopsAgent("").To(ring)
+	assert.Nil(t, agent.Start())
+	assert.Equal(t, ErrAlreadyStarted, agent.Start())
+
+	// The gops CLI finds the agent through the port file.
+	portFile := filepath.Join(dir, strconv.Itoa(os.Getpid()))
+	port, err := os.ReadFile(portFile)
+	assert.Nil(t, err)
+	addr := net.JoinHostPort("127.0.0.1", string(port))
+	assert.Equal(t, addr, agent.Addr())
+
+	assert.Equal(t, runtime.Version()+"\n", string(gopsCommand(t, addr, gopsVersion)))
+	assert.Equal(t, "ok", string(gopsCommand(t, addr, gopsGC)))
+	assert.Contains(t, string(gopsCommand(t, addr, gopsStats)), "goroutines: ")
+	assert.Contains(t, string(gopsCommand(t, addr, gopsMemStats)), "heap-alloc: ")
+	assert.Contains(t, string(gopsCommand(t, addr, 0x42)), "swat: ")
+
+	stack := gopsCommand(t, addr, gopsStackTrace)
+	assert.Contains(t, string(stack), "goroutine ")
+	heap := gopsCommand(t, addr, gopsHeapProfile)
+	assert.NotEmpty(t, heap)
+	cpu := gopsCommand(t, addr, gopsCPUProfile)
+	assert.NotEmpty(t, cpu)
+
+	setGC := append([]byte{gopsSetGCPercent}, binary.AppendVarint(nil, 150)...)
+	previous := debug.SetGCPercent(100)
+	defer debug.SetGCPercent(previous)
+	assert.Equal(t, "New GC percent set to 150. Previous value was 100.\n", string(gopsCommand(t, addr, setGC...)))
+
+	// What gops was sent is archived in the sink too.
+	dumps := ring.Dumps()
+	var names []string
+	for _, d := range dumps {
+		names = append(names, d.Name)
+	}
+	assert.Equal(t, []string{"memstats", "goroutine", "heap", "cpu"}, names)
+	latest, _ := ring.Latest("heap")
+	assert.Equal(t, heap, latest.Data)
+	assert.Equal(t, SourceManual, latest.Source)
+
+	agent.End()
+	agent.End()
+	_, err = os.Stat(portFile)
+	assert.True(t, os.IsNotExist(err))
+}
+
+// A sink which opens writers that fail every write.
+type brokenSink struct{ writes *int }
+
+func (s brokenSink) Open(info RunInfo) (io.WriteCloser, error) {
+	return nopCloser{s}, nil
+}
+
+func (s brokenSink) Write(p []byte) (int, error) {
+	*s.writes++
+	return 0, errors.New("disk full")
+}
+
+func TestGopsAgentRepliesWhenSinkFails(t *testing.T) {
+	t.Setenv("GOPS_CONFIG_DIR", t.TempDir())
+
+	var writes int
+	agent := NewGopsAgent("").To(brokenSink{&writes})
+	assert.Nil(t, agent.Start())
+	defer agent.End()
+
+	// gops still gets the whole reply, though the sink failed.
+	reply := string(gopsCommand(t, agent.Addr(), gopsMemStats))
+	assert.Contains(t, reply, "heap-alloc: ")
+	assert.Contains(t, reply, "gc-cpu-fraction: ")
+	assert.NotContains(t, reply, "swat: ")
+	assert.Equal(t, 1, writes)
+}
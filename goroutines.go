@@ -0,0 +1,235 @@
+package profile
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A goroutineSet counts goroutines by their stack.
+type goroutineSet map[string]*goroutineStack
+
+type goroutineStack struct {
+	pcs   []uintptr
+	count int
+}
+
+// Captures the stacks of all goroutines.
+func captureGoroutines() goroutineSet {
+	release := acquireSTW()
+	records := make([]runtime.StackRecord, runtime.NumGoroutine()+10)
+	for {
+		n, ok := runtime.GoroutineProfile(records)
+		if ok {
+			records = records[:n]
+			break
+		}
+
+		records = make([]runtime.StackRecord, n+10)
+	}
+	release()
+
+	set := make(goroutineSet)
+	for i := range records {
+		pcs := records[i].Stack()
+		if isOwn(pcs) {
+			continue
+		}
+
+		key := fmt.Sprint(pcs)
+		if s, ok := set[key]; ok {
+			s.count++
+		} else {
+			set[key] = &goroutineStack{pcs: pcs, count: 1}
+		}
+	}
+
+	return set
+}
+
+// Returns whether the stack is of the goroutine capturing the set,
+// whose stack differs with each caller, or of one of Swat's own,
+// such as a scheduler's. These are left out of the set.
+func isOwn(pcs []uintptr) bool {
+	capture := reflect.ValueOf(captureGoroutines).Pointer()
+	for i := 0; i < len(pcs) && i < 8; i++ {
+		if fn := runtime.FuncForPC(pcs[i] - 1); fn != nil && fn.Entry() == capture {
+			return true
+		}
+	}
+
+	// Goroutines are started by the frame before runtime.goexit.
+	if len(pcs) < 2 {
+		return false
+	}
+
+	fn := runtime.FuncForPC(pcs[len(pcs)-2] - 1)
+	return fn != nil && strings.HasPrefix(fn.Name(), ownMethods)
+}
+
+// The prefix of the names of methods on Swat's own types, such as
+// "github.com/WatchBeam/swat.(*scheduler).run".
+var ownMethods = reflect.TypeOf(BaseAction{}).PkgPath() + ".(*"
+
+// Returns the total number of goroutines in the set.
+func (g goroutineSet) total() int {
+	n := 0
+	for _, s := range g {
+		n += s.count
+	}
+
+	return n
+}
+
+// A change in the number of goroutines with a stack.
+type stackDelta struct {
+	pcs    []uintptr
+	change int
+	now    int
+}
+
+// Returns the stacks whose counts differ between the sets, largest
+// changes first.
+func diffGoroutines(before, after goroutineSet) []stackDelta {
+	var deltas []stackDelta
+	for key, s := range after {
+		if old := before[key]; old == nil {
+			deltas = append(deltas, stackDelta{pcs: s.pcs, change: s.count, now: s.count})
+		} else if s.count != old.count {
+			deltas = append(deltas, stackDelta{pcs: s.pcs, change: s.count - old.count, now: s.count})
+		}
+	}
+	for key, s := range before {
+		if after[key] == nil {
+			deltas = append(deltas, stackDelta{pcs: s.pcs, change: -s.count})
+		}
+	}
+
+	sort.SliceStable(deltas, func(i, j int) bool {
+		a, b := deltas[i].change, deltas[j].change
+		if a < 0 {
+			a = -a
+		}
+		if b < 0 {
+			b = -b
+		}
+		return a > b
+	})
+
+	return deltas
+}
+
+// Writes the changes in text, with each stack's change and new count
+// followed by its frames, as in:
+//
+//	goroutines: 12 (+3)
+//
+//	+3 (now 5)
+//		main.worker
+//			/src/main/worker.go:42
+func writeGoroutineDiff(w io.Writer, before, after goroutineSet) error {
+	return writeStackDeltas(w, after.total(), after.total()-before.total(), diffGoroutines(before, after))
+}
+
+func writeStackDeltas(w io.Writer, total, change int, deltas []stackDelta) error {
+	if _, err := fmt.Fprintf(w, "goroutines: %d (%+d)\n", total, change); err != nil {
+		return err
+	}
+
+	for _, d := range deltas {
+		if _, err := fmt.Fprintf(w, "\n%+d (now %d)\n", d.change, d.now); err != nil {
+			return err
+		}
+
+		frames := runtime.CallersFrames(d.pcs)
+		for {
+			f, more := frames.Next()
+			if _, err := fmt.Fprintf(w, "\t%s\n\t\t%s:%d\n", f.Function, f.File, f.Line); err != nil {
+				return err
+			}
+			if !more {
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// Returns an action that writes how the set of goroutines has changed
+// since its previous run: the stacks of goroutines which have started
+// or exited, with how many more or fewer there are and how many
+// remain. Leaks jump out of interval dumps as stacks whose count only
+// ever grows. The first run compares against no goroutines at all.
+// Swat's own goroutines are left out.
+func DumpGoroutineDelta() *BaseAction {
+	var mu sync.Mutex
+	previous := make(goroutineSet)
+	b := NewAction(func(w io.Writer) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		current := captureGoroutines()
+		err := writeGoroutineDiff(w, previous, current)
+		previous = current
+		return err
+	}).Name("goroutinedelta")
+
+	b.ext = "txt"
+	return b
+}
+
+// BaselineAction is an action which reports goroutines which have
+// appeared since a baseline taken when it started.
+type BaselineAction struct {
+	Builder[*BaselineAction]
+	mu       sync.Mutex
+	baseline goroutineSet
+}
+
+// Returns an action which captures the goroutines running when it
+// starts as a baseline, and on each run writes the stacks of those
+// running now which weren't then, in the format of
+// DumpGoroutineDelta. Triggered by signals before and after a load
+// test, it shows what the test leaked. Until it's started, every
+// goroutine is new.
+func BaselineGoroutines() *BaselineAction {
+	a := &BaselineAction{baseline: make(goroutineSet)}
+	b := NewAction(func(w io.Writer) error {
+		a.mu.Lock()
+		baseline := a.baseline
+		a.mu.Unlock()
+
+		current := captureGoroutines()
+		var added []stackDelta
+		for _, d := range diffGoroutines(baseline, current) {
+			if d.change > 0 {
+				added = append(added, d)
+			}
+		}
+
+		return writeStackDeltas(w, current.total(), current.total()-baseline.total(), added)
+	}).Name("goroutinebaseline")
+	b.ext = "txt"
+
+	a.Builder = Extend(b, a)
+	return a
+}
+
+// Captures the running goroutines as the new baseline.
+func (a *BaselineAction) Rebaseline() {
+	baseline := captureGoroutines()
+	a.mu.Lock()
+	a.baseline = baseline
+	a.mu.Unlock()
+}
+
+// Implements Action.Start, capturing the baseline first.
+func (a *BaselineAction) Start() error {
+	a.Rebaseline()
+	return a.BaseAction.Start()
+}
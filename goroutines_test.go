@@ -0,0 +1,88 @@
+package profile
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func blockForDelta(ch chan struct{}) {
+	<-ch
+}
+
+func TestDumpGoroutineDeltaShowsNewStacks(t *testing.T) {
+	buf := new(bytes.Buffer)
+	a := DumpGoroutineDelta().ToWriter(buf)
+	assert.Nil(t, a.Trigger(context.Background()))
+	assert.Contains(t, buf.String(), "testing.tRunner")
+
+	ch := make(chan struct{})
+	for i := 0; i < 3; i++ {
+		go blockForDelta(ch)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	buf.Reset()
+	assert.Nil(t, a.Trigger(context.Background()))
+	out := buf.String()
+	assert.True(t, strings.HasPrefix(out, "goroutines: "))
+	assert.Contains(t, out, "+3 (now 3)\n")
+	assert.Contains(t, out, ".blockForDelta\n")
+	assert.NotContains(t, out, "testing.tRunner")
+
+	close(ch)
+	time.Sleep(10 * time.Millisecond)
+	buf.Reset()
+	assert.Nil(t, a.Trigger(context.Background()))
+	assert.Contains(t, buf.String(), "-3 (now 0)\n")
+	assert.Contains(t, buf.String(), ".blockForDelta\n")
+}
+
+func TestBaselineGoroutinesReportsOnlyNewStacks(t *testing.T) {
+	buf := new(bytes.Buffer)
+	a := BaselineGoroutines().ToWriter(buf)
+	assert.Nil(t, a.Start())
+	defer a.End()
+
+	ch := make(chan struct{})
+	defer close(ch)
+	for i := 0; i < 2; i++ {
+		go blockForDelta(ch)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	assert.Nil(t, a.Trigger(context.Background()))
+	out := buf.String()
+	assert.Contains(t, out, "+2 (now 2)\n")
+	assert.Contains(t, out, ".blockForDelta\n")
+	assert.NotContains(t, out, "testing.tRunner")
+	assert.NotContains(t, out, "\n-")
+
+	a.Rebaseline()
+	buf.Reset()
+	assert.Nil(t, a.Trigger(context.Background()))
+	assert.Equal(t, "goroutines: ", buf.String()[:12])
+	assert.NotContains(t, buf.String(), "blockForDelta")
+}
+
+func TestCaptureGoroutinesLeavesOutSwat(t *testing.T) {
+	a := NewAction(func(w io.Writer) error { return nil }).Every(time.Hour).After(time.Hour)
+	assert.Nil(t, a.Start())
+	defer a.End()
+
+	for _, s := range captureGoroutines() {
+		frames := runtime.CallersFrames(s.pcs)
+		for {
+			f, more := frames.Next()
+			assert.NotContains(t, f.Function, ".(*scheduler)")
+			if !more {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Returns nil if the action is healthy: it's running, it hasn't been
+// disabled by StopAfterFailures, none of its triggers have stopped,
+// and its output can be written, such as to the directory given to
+// ToDir or a DirSink. Otherwise it returns an error wrapping
+// ErrUnhealthy, giving the reason. Sinks which send dumps over the
+// network aren't checked, since that would send something.
+func (b *BaseAction) Healthy() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	name := b.displayName()
+	if !b.running {
+		return fmt.Errorf("%w: %s isn't running", ErrUnhealthy, name)
+	}
+
+	if atomic.LoadInt32(&b.runner.disabled) == 1 {
+		return fmt.Errorf("%w: %s was disabled after too many failures", ErrUnhealthy, name)
+	}
+
+	for _, t := range b.active {
+		if isStalled(t) {
+			return fmt.Errorf("%w: trigger %q of %s has stopped", ErrUnhealthy, t, name)
+		}
+	}
+
+	if err := b.targeter.check(newPathVars(name, b.outputExt(), time.Now())); err != nil {
+		return fmt.Errorf("%w: %s can't write its output: %s", ErrUnhealthy, name, err)
+	}
+
+	return nil
+}
+
+// Actions which can check their health, as BaseAction does.
+type checkedAction interface {
+	Healthy() error
+}
+
+// Returns nil if all the Swat's actions are healthy, as reported by
+// their Healthy methods, or an error joining the errors of those which
+// aren't, so that services can include Swat in their readiness and
+// liveness checks:
+//
+//	http.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+//		if err := s.Healthy(); err != nil {
+//			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+//		}
+//	})
+//
+// Actions without a Healthy method are assumed to be healthy.
+func (s *Swat) Healthy() error {
+	var errs []error
+	for _, action := range s.actions {
+		if a, ok := action.(checkedAction); ok {
+			errs = append(errs, a.Healthy())
+		}
+	}
+
+	return errors.Join(errs...)
+}
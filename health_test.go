@@ -0,0 +1,83 @@
+package profile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthy(t *testing.T) {
+	dir := t.TempDir()
+	a, _ := newCountingAction()
+	a.Name("heap").ToDir(filepath.Join(dir, "dumps"))
+	assert.True(t, errors.Is(a.Healthy(), ErrUnhealthy))
+
+	s, err := Start(a)
+	assert.Nil(t, err)
+	assert.Nil(t, s.Healthy())
+
+	// Checking doesn't write to the directory.
+	assert.Nil(t, os.Mkdir(filepath.Join(dir, "dumps"), 0777))
+	assert.Nil(t, s.Healthy())
+	files, err := os.ReadDir(filepath.Join(dir, "dumps"))
+	assert.Nil(t, err)
+	assert.Empty(t, files)
+
+	// Dumps can't be written under a file.
+	file := filepath.Join(dir, "file")
+	assert.Nil(t, os.WriteFile(file, nil, 0666))
+	b, _ := newCountingAction()
+	b.To(Retry(DirSink(filepath.Join(file, "dumps")), 1, 0))
+	assert.Nil(t, b.Start())
+	defer b.End()
+	err = b.Healthy()
+	assert.True(t, errors.Is(err, ErrUnhealthy))
+	assert.Contains(t, err.Error(), "action can't write its output")
+
+	// Either sink will do for a fallback.
+	c, _ := newCountingAction()
+	c.ToWithFallback(DirSink(file), DirSink(dir))
+	assert.Nil(t, c.Start())
+	defer c.End()
+	assert.Nil(t, c.Healthy())
+
+	s.End()
+	err = s.Healthy()
+	assert.True(t, errors.Is(err, ErrUnhealthy))
+	assert.Contains(t, err.Error(), "heap isn't running")
+}
+
+func TestHealthyReportsStoppedTriggers(t *testing.T) {
+	defer func(d time.Duration) { restartDelay = d }(restartDelay)
+	restartDelay = time.Hour
+
+	a, _ := newCountingAction()
+	a.When(func() bool { panic("condition failed") }, time.Millisecond)
+	assert.Nil(t, a.Start())
+	assert.Eventually(t, func() bool { return a.Healthy() != nil }, 5*time.Second, time.Millisecond)
+	assert.Contains(t, a.Healthy().Error(), "has stopped")
+
+	// Ending the action forgets the trigger.
+	a.End()
+	assert.False(t, isStalled(a.triggers[0]))
+}
+
+func TestHealthyAfterChannelIsClosed(t *testing.T) {
+	ch := make(chan struct{})
+	a, runs := newCountingAction()
+	a.OnChannel(ch)
+	assert.Nil(t, a.Start())
+	defer a.End()
+
+	// Closing the channel is the normal way to stop the trigger.
+	ch <- struct{}{}
+	close(ch)
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(runs) == 1 }, 5*time.Second, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	assert.Nil(t, a.Healthy())
+}
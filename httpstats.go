@@ -0,0 +1,134 @@
+package profile
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync/atomic"
+	"time"
+)
+
+// HTTPStats counts the activity of HTTP clients, so that their health
+// lands in the same bundle as runtime profiles. Wire it into a client
+// with Wrap, and dump it with DumpHTTPStats. The zero value is ready
+// to use, and one HTTPStats may be shared by several clients.
+type HTTPStats struct {
+	requests, inFlight, failures       int64
+	dials, dialFailures, tlsHandshakes int64
+	reused, idleTaken, idleReturned    int64
+}
+
+// An HTTPSnapshot is the state of an HTTPStats at one moment.
+type HTTPSnapshot struct {
+	// Requests started, in flight, and which failed without a
+	// response.
+	Requests, InFlight, Failures int64
+	// New connections dialed, dials which failed, and TLS handshakes.
+	Dials, DialFailures, TLSHandshakes int64
+	// Requests which reused a connection, and of those, how many took
+	// it from the idle pool.
+	Reused, IdleTaken int64
+	// Connections returned to the idle pool. Less IdleTaken, this
+	// approximates the size of the pool, ignoring idle connections
+	// closed by timeouts.
+	IdleReturned int64
+}
+
+// Wraps the transport, which may be nil for http.DefaultTransport,
+// counting its requests and connections, as in
+// `client.Transport = stats.Wrap(client.Transport)`.
+func (h *HTTPStats) Wrap(rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+
+	return &statsTransport{rt, h}
+}
+
+// Returns a ClientTrace which counts connections, for callers which
+// want to attach it to requests themselves, rather than use Wrap.
+func (h *HTTPStats) Trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				atomic.AddInt64(&h.reused, 1)
+			}
+			if info.WasIdle {
+				atomic.AddInt64(&h.idleTaken, 1)
+			}
+		},
+		PutIdleConn: func(err error) {
+			if err == nil {
+				atomic.AddInt64(&h.idleReturned, 1)
+			}
+		},
+		ConnectStart: func(network, addr string) {
+			atomic.AddInt64(&h.dials, 1)
+		},
+		ConnectDone: func(network, addr string, err error) {
+			if err != nil {
+				atomic.AddInt64(&h.dialFailures, 1)
+			}
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			atomic.AddInt64(&h.tlsHandshakes, 1)
+		},
+	}
+}
+
+// Returns the current counts.
+func (h *HTTPStats) Snapshot() HTTPSnapshot {
+	return HTTPSnapshot{
+		Requests:      atomic.LoadInt64(&h.requests),
+		InFlight:      atomic.LoadInt64(&h.inFlight),
+		Failures:      atomic.LoadInt64(&h.failures),
+		Dials:         atomic.LoadInt64(&h.dials),
+		DialFailures:  atomic.LoadInt64(&h.dialFailures),
+		TLSHandshakes: atomic.LoadInt64(&h.tlsHandshakes),
+		Reused:        atomic.LoadInt64(&h.reused),
+		IdleTaken:     atomic.LoadInt64(&h.idleTaken),
+		IdleReturned:  atomic.LoadInt64(&h.idleReturned),
+	}
+}
+
+// Counts requests through a transport. A request is in flight until
+// its response is returned; reading the body isn't tracked.
+type statsTransport struct {
+	rt    http.RoundTripper
+	stats *HTTPStats
+}
+
+func (s *statsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt64(&s.stats.requests, 1)
+	atomic.AddInt64(&s.stats.inFlight, 1)
+	defer atomic.AddInt64(&s.stats.inFlight, -1)
+
+	ctx := httptrace.WithClientTrace(req.Context(), s.stats.Trace())
+	res, err := s.rt.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		atomic.AddInt64(&s.stats.failures, 1)
+	}
+
+	return res, err
+}
+
+// Returns an action that writes the HTTP client statistics as a line
+// of JSON. Like DumpMemStats, each object has a "Time" field
+// alongside the fields of HTTPSnapshot.
+func DumpHTTPStats(stats *HTTPStats) *BaseAction {
+	b := NewAction(func(w io.Writer) error {
+		var out struct {
+			Time time.Time
+			HTTPSnapshot
+		}
+
+		out.Time = time.Now()
+		out.HTTPSnapshot = stats.Snapshot()
+		return json.NewEncoder(w).Encode(&out)
+	}).Name("httpstats")
+
+	b.ext = "json"
+	return b
+}
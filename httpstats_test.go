@@ -0,0 +1,44 @@
+package profile
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPStatsCountsRequests(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	stats := new(HTTPStats)
+	client := &http.Client{Transport: stats.Wrap(nil)}
+	for i := 0; i < 3; i++ {
+		res, err := client.Get(srv.URL)
+		if assert.Nil(t, err) {
+			io.ReadAll(res.Body)
+			res.Body.Close()
+		}
+	}
+
+	_, err := client.Get("http://127.0.0.1:1")
+	assert.NotNil(t, err)
+
+	buf := new(bytes.Buffer)
+	assert.Nil(t, DumpHTTPStats(stats).ToWriter(buf).Trigger(context.Background()))
+
+	var out HTTPSnapshot
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &out))
+	assert.Equal(t, int64(4), out.Requests)
+	assert.Equal(t, int64(0), out.InFlight)
+	assert.Equal(t, int64(1), out.Failures)
+	assert.Equal(t, int64(2), out.Dials)
+	assert.Equal(t, int64(1), out.DialFailures)
+	assert.Equal(t, int64(2), out.Reused)
+}
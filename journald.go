@@ -0,0 +1,112 @@
+package profile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// The socket journald listens on for its native protocol.
+const journalSocket = "/run/systemd/journal/socket"
+
+// The default size of the chunks sent by JournaldSink, which keeps
+// each entry well within the socket's datagram limit.
+const defaultJournalChunk = 64 * 1024
+
+// JournaldSink is a Sink which writes each dump to the systemd journal
+// as a structured entry, so that dumps on Linux hosts are captured and
+// rotated along with the rest of the service's logs. The dump is the
+// entry's MESSAGE, and it carries these fields:
+//
+//	SWAT_ACTION  the name of the action
+//	SWAT_RUN     the start of the run, in RFC 3339 format
+//	SWAT_EXT     the extension of the output, such as "txt"
+//	SWAT_CHUNK   the index of the chunk and the total, such as "1/3"
+//
+// Dumps larger than the chunk size are split over several entries.
+// They can be read back with, for example:
+//
+//	journalctl SWAT_ACTION=goroutine -o cat
+type JournaldSink struct {
+	socket     string
+	identifier string
+	size       int
+}
+
+// Creates a sink which writes dumps to the local journal.
+func NewJournaldSink() *JournaldSink {
+	return &JournaldSink{socket: journalSocket, identifier: "swat", size: defaultJournalChunk}
+}
+
+// Sets the SYSLOG_IDENTIFIER of the entries, which is "swat" by
+// default.
+func (j *JournaldSink) Identifier(id string) *JournaldSink {
+	j.identifier = id
+	return j
+}
+
+// Sets the maximum size of each entry's message. A size of zero
+// disables chunking, but entries larger than the socket's datagram
+// limit will then fail.
+func (j *JournaldSink) ChunkSize(size int) *JournaldSink {
+	j.size = size
+	return j
+}
+
+func (j *JournaldSink) String() string {
+	return "journald"
+}
+
+// Implements Sink.Open. The dump is sent once the writer is closed,
+// and closing it returns any error from the journal's socket.
+func (j *JournaldSink) Open(info RunInfo) (io.WriteCloser, error) {
+	return &bufferedWriter{info: info, flush: j.send}, nil
+}
+
+func (j *JournaldSink) send(info RunInfo, data []byte) error {
+	conn, err := net.Dial("unixgram", j.socket)
+	if err != nil {
+		return fmt.Errorf("connecting to journald: %w", err)
+	}
+	defer conn.Close()
+
+	start := info.Start.UTC().Format(time.RFC3339Nano)
+	chunks := chunk(data, j.size)
+	for i, c := range chunks {
+		buf := new(bytes.Buffer)
+		writeJournalField(buf, "MESSAGE", c)
+		writeJournalField(buf, "PRIORITY", []byte("6"))
+		writeJournalField(buf, "SYSLOG_IDENTIFIER", []byte(j.identifier))
+		writeJournalField(buf, "SWAT_ACTION", []byte(info.Name))
+		writeJournalField(buf, "SWAT_RUN", []byte(start))
+		writeJournalField(buf, "SWAT_EXT", []byte(info.Ext))
+		writeJournalField(buf, "SWAT_CHUNK", []byte(strconv.Itoa(i+1)+"/"+strconv.Itoa(len(chunks))))
+
+		if _, err := conn.Write(buf.Bytes()); err != nil {
+			return fmt.Errorf("writing to journald: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Writes a field in journald's native format. Values containing
+// newlines, like most dumps, are written with an explicit length.
+func writeJournalField(buf *bytes.Buffer, key string, value []byte) {
+	buf.WriteString(key)
+	if bytes.IndexByte(value, '\n') < 0 {
+		buf.WriteByte('=')
+		buf.Write(value)
+		buf.WriteByte('\n')
+		return
+	}
+
+	buf.WriteByte('\n')
+	binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.Write(value)
+	buf.WriteByte('\n')
+}
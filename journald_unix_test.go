@@ -0,0 +1,40 @@
+//go:build unix
+// +build unix
+
+package profile
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJournaldSinkSendsEntries(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "journal.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Skip("cannot listen on a datagram socket:", err)
+	}
+	defer conn.Close()
+
+	sink := NewJournaldSink().ChunkSize(9)
+	sink.socket = socket
+	assert.Nil(t, newOutputAction("goroutine", "line one\nline two").To(sink).Trigger(context.Background()))
+
+	buf := make([]byte, 1024)
+	n, err := conn.Read(buf)
+	assert.Nil(t, err)
+	entry := string(buf[:n])
+	assert.True(t, strings.HasPrefix(entry, "MESSAGE\n\x09\x00\x00\x00\x00\x00\x00\x00line one\n\n"))
+	assert.Contains(t, entry, "\nSWAT_ACTION=goroutine\n")
+	assert.Contains(t, entry, "\nSWAT_CHUNK=1/2\n")
+
+	n, err = conn.Read(buf)
+	assert.Nil(t, err)
+	entry = string(buf[:n])
+	assert.True(t, strings.HasPrefix(entry, "MESSAGE=line two\n"))
+	assert.Contains(t, entry, "\nSWAT_CHUNK=2/2\n")
+}
@@ -0,0 +1,103 @@
+package profile
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// The default size of chunks published by KafkaSink, which leaves
+// room for headers under Kafka's default 1MB message limit.
+const defaultKafkaChunk = 900 * 1024
+
+// A KafkaHeader is a header on a Kafka message.
+type KafkaHeader struct {
+	Key   string
+	Value []byte
+}
+
+// A KafkaMessage is a message for a KafkaProducer to publish.
+type KafkaMessage struct {
+	Topic   string
+	Key     []byte
+	Value   []byte
+	Headers []KafkaHeader
+}
+
+// KafkaProducer publishes messages to Kafka. Swat doesn't depend on
+// a Kafka client; implement this with a small adapter around the one
+// your program already uses, such as sarama's SyncProducer.
+type KafkaProducer interface {
+	Produce(msgs ...KafkaMessage) error
+}
+
+// KafkaSink is a Sink which publishes each dump to a Kafka topic, so
+// that Swat's output can join existing streaming ingestion pipelines.
+// Dumps are split into chunks if they're too large for one message.
+// Every chunk of a dump shares its key, so they land on the same
+// partition in order, and carries these headers:
+//
+//	swat-action  the name of the action
+//	swat-host    the host name
+//	swat-time    the start of the run, in RFC 3339 format
+//	swat-ext     the extension of the output, such as "pb.gz"
+//	swat-chunk   the index of the chunk and the total, such as "1/3"
+type KafkaSink struct {
+	producer KafkaProducer
+	topic    string
+	host     string
+	size     int
+}
+
+// Creates a sink which publishes dumps to the topic.
+func NewKafkaSink(producer KafkaProducer, topic string) *KafkaSink {
+	host, _ := os.Hostname()
+	return &KafkaSink{producer: producer, topic: topic, host: host, size: defaultKafkaChunk}
+}
+
+// Sets the maximum size of each message's value. It should be below
+// the broker's message.max.bytes, less room for headers. A size of
+// zero disables chunking.
+func (k *KafkaSink) ChunkSize(size int) *KafkaSink {
+	k.size = size
+	return k
+}
+
+func (k *KafkaSink) String() string {
+	return "kafka topic " + k.topic
+}
+
+// Implements Sink.Open. The dump is published once the writer is
+// closed, and closing it returns any error from the producer.
+func (k *KafkaSink) Open(info RunInfo) (io.WriteCloser, error) {
+	return &bufferedWriter{info: info, flush: k.publish}, nil
+}
+
+func (k *KafkaSink) publish(info RunInfo, data []byte) error {
+	start := info.Start.UTC().Format(time.RFC3339Nano)
+	key := []byte(info.Name + "/" + k.host + "/" + start)
+	chunks := chunk(data, k.size)
+	msgs := make([]KafkaMessage, len(chunks))
+	for i, c := range chunks {
+		msgs[i] = KafkaMessage{
+			Topic: k.topic,
+			Key:   key,
+			Value: c,
+			Headers: []KafkaHeader{
+				{"swat-action", []byte(info.Name)},
+				{"swat-host", []byte(k.host)},
+				{"swat-time", []byte(start)},
+				{"swat-ext", []byte(info.Ext)},
+				{"swat-chunk", []byte(strconv.Itoa(i+1) + "/" + strconv.Itoa(len(chunks)))},
+			},
+		}
+	}
+
+	if err := k.producer.Produce(msgs...); err != nil {
+		return fmt.Errorf("publishing to kafka: %w", err)
+	}
+
+	return nil
+}
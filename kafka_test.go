@@ -0,0 +1,48 @@
+package profile
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type fakeProducer struct {
+	msgs []KafkaMessage
+	err  error
+}
+
+func (f *fakeProducer) Produce(msgs ...KafkaMessage) error {
+	f.msgs = append(f.msgs, msgs...)
+	return f.err
+}
+
+func header(m KafkaMessage, key string) string {
+	for _, h := range m.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+
+	return ""
+}
+
+func TestKafkaSinkChunksDumps(t *testing.T) {
+	p := new(fakeProducer)
+	sink := NewKafkaSink(p, "dumps").ChunkSize(4)
+	assert.Nil(t, newOutputAction("heap", "0123456789").To(sink).Trigger(context.Background()))
+
+	if assert.Len(t, p.msgs, 3) {
+		assert.Equal(t, "0123", string(p.msgs[0].Value))
+		assert.Equal(t, "89", string(p.msgs[2].Value))
+		assert.Equal(t, p.msgs[0].Key, p.msgs[2].Key)
+		assert.Equal(t, "dumps", p.msgs[1].Topic)
+		assert.Equal(t, "heap", header(p.msgs[1], "swat-action"))
+		assert.Equal(t, "2/3", header(p.msgs[1], "swat-chunk"))
+		assert.NotEmpty(t, header(p.msgs[1], "swat-time"))
+	}
+
+	p.err = errors.New("broker down")
+	err := newOutputAction("heap", "x").To(sink).Trigger(context.Background())
+	assert.True(t, errors.Is(err, p.err))
+}
@@ -0,0 +1,126 @@
+package profile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// `Label` attaches a label to the action, such as "team=payments", so
+// that it can be selected along with others from a Swat. Setting a key
+// again replaces its value.
+func (b *BaseAction) Label(key, value string) *BaseAction {
+	if b.lastErr == nil && key == "" {
+		b.lastErr = fmt.Errorf("%w: 'Label' requires a key", ErrInvalidOption)
+	}
+
+	if b.labels == nil {
+		b.labels = make(map[string]string)
+	}
+
+	b.labels[key] = value
+	return b
+}
+
+// Returns whether the action has every label in the selector.
+func (b *BaseAction) matches(selector map[string]string) bool {
+	for k, v := range selector {
+		if value, ok := b.labels[k]; !ok || value != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Pauses the action, so that its schedule and conditions no longer
+// trigger runs until Resume is called. See Swat.Pause.
+func (b *BaseAction) Pause() {
+	atomic.StoreInt32(&b.paused.paused, 1)
+}
+
+// Resumes the action after Pause.
+func (b *BaseAction) Resume() {
+	atomic.StoreInt32(&b.paused.paused, 0)
+}
+
+// Actions which can be selected by label implement this.
+type labeled interface {
+	matches(selector map[string]string) bool
+}
+
+// Actions which can be paused on their own implement this.
+type pausableAction interface {
+	Pause()
+	Resume()
+}
+
+// A Selection is a group of a Swat's actions, chosen by their labels,
+// which can be managed together.
+type Selection struct {
+	actions []Action
+}
+
+// Selects the actions which have every label in the selector, so
+// that large applications can manage their actions in groups:
+//
+//	s.Select(map[string]string{"team": "payments"}).Pause()
+//
+// An empty selector selects every action which can be labeled, such
+// as BaseActions, whether or not it has any labels.
+func (s *Swat) Select(selector map[string]string) *Selection {
+	sel := new(Selection)
+	for _, action := range s.actions {
+		if l, ok := action.(labeled); ok && l.matches(selector) {
+			sel.actions = append(sel.actions, action)
+		}
+	}
+
+	return sel
+}
+
+// Returns the selected actions.
+func (s *Selection) Actions() []Action {
+	return s.actions
+}
+
+// Triggers each selected action in turn, returning all of their
+// errors joined together.
+func (s *Selection) Trigger(ctx context.Context) error {
+	var errs []error
+	for _, action := range s.actions {
+		if err := action.Trigger(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Pauses the selected actions. Signals are still honored if the Swat
+// was told to with HonorSignalsWhilePaused.
+func (s *Selection) Pause() {
+	for _, action := range s.actions {
+		if p, ok := action.(pausableAction); ok {
+			p.Pause()
+		}
+	}
+}
+
+// Resumes the selected actions.
+func (s *Selection) Resume() {
+	for _, action := range s.actions {
+		if p, ok := action.(pausableAction); ok {
+			p.Resume()
+		}
+	}
+}
+
+// Ends the selected actions. They can be started again individually,
+// and ending the Swat ends them again harmlessly.
+func (s *Selection) End() {
+	for _, action := range s.actions {
+		action.End()
+	}
+}
@@ -0,0 +1,101 @@
+package profile
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// How many of the latest observations a LatencyTrigger's p99 covers,
+// and how many it needs before the p99 is meaningful.
+const (
+	latencyWindow     = 1000
+	minLatencySamples = 100
+)
+
+// LatencyTrigger watches request latencies against an SLO, so that
+// the evidence for a latency breach, such as a trace or CPU profile,
+// is collected while it's happening. Applications call Observe with
+// the latency of each request, and actions given the trigger with
+// OnLatency run when the p99 of the latest 1000 observations exceeds
+// the bound. Since a breach usually lasts a while, each action runs
+// at most once a minute, or as set by Cooldown.
+type LatencyTrigger struct {
+	bound    time.Duration
+	cooldown time.Duration
+
+	mu     sync.Mutex
+	window []time.Duration
+	next   int
+	events eventSource
+}
+
+// Creates a trigger which fires when the p99 latency exceeds the
+// bound.
+func NewLatencyTrigger(bound time.Duration) *LatencyTrigger {
+	return &LatencyTrigger{
+		bound:    bound,
+		cooldown: time.Minute,
+		window:   make([]time.Duration, 0, latencyWindow),
+	}
+}
+
+// Sets how long each action waits after running before it may run
+// again. Set it before giving the trigger to actions.
+func (l *LatencyTrigger) Cooldown(period time.Duration) *LatencyTrigger {
+	l.cooldown = period
+	return l
+}
+
+// Records the latency of a request. It's safe to call concurrently,
+// and cheap enough to call for every request.
+func (l *LatencyTrigger) Observe(d time.Duration) {
+	l.mu.Lock()
+	if len(l.window) < latencyWindow {
+		l.window = append(l.window, d)
+	} else {
+		l.window[l.next] = d
+		l.next = (l.next + 1) % latencyWindow
+	}
+	l.mu.Unlock()
+
+	// The p99 can only exceed the bound if some latency does, so
+	// watchers are only woken then.
+	if d > l.bound {
+		l.events.notify()
+	}
+}
+
+// Returns the p99 of the latest observations, and false if there
+// aren't enough of them yet.
+func (l *LatencyTrigger) P99() (time.Duration, bool) {
+	l.mu.Lock()
+	sorted := append([]time.Duration(nil), l.window...)
+	l.mu.Unlock()
+
+	if len(sorted) < minLatencySamples {
+		return 0, false
+	}
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)*99/100], true
+}
+
+// Returns whether the p99 is over the bound.
+func (l *LatencyTrigger) breached() bool {
+	p99, ok := l.P99()
+	return ok && p99 > l.bound
+}
+
+// `OnLatency` runs the action when the trigger's p99 latency exceeds
+// its bound. See LatencyTrigger.
+func (b *BaseAction) OnLatency(l *LatencyTrigger) *BaseAction {
+	b.triggers = append(b.triggers, &eventWatcher{
+		events:   &l.events,
+		desc:     fmt.Sprintf("when p99 latency exceeds %s", l.bound),
+		check:    l.breached,
+		cooldown: l.cooldown,
+	})
+	return b
+}
@@ -0,0 +1,58 @@
+package profile
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLatencyTriggerFiresOnP99Breach(t *testing.T) {
+	l := NewLatencyTrigger(100 * time.Millisecond)
+	a, runs := newCountingAction()
+	assert.Nil(t, a.OnLatency(l).Start())
+	defer a.End()
+
+	// Too few observations to trust the p99.
+	for i := 0; i < minLatencySamples-1; i++ {
+		l.Observe(time.Second)
+	}
+	time.Sleep(20 * time.Millisecond)
+	assert.EqualValues(t, 0, atomic.LoadInt32(runs))
+
+	for i := 0; i < latencyWindow; i++ {
+		l.Observe(time.Millisecond)
+	}
+	p99, ok := l.P99()
+	assert.True(t, ok)
+	assert.Equal(t, time.Millisecond, p99)
+
+	for i := 0; i < latencyWindow/100+1; i++ {
+		l.Observe(time.Second)
+	}
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(runs) == 1 }, time.Second, time.Millisecond)
+
+	// The breach continues, but the action is cooling down.
+	l.Observe(time.Second)
+	time.Sleep(20 * time.Millisecond)
+	assert.EqualValues(t, 1, atomic.LoadInt32(runs))
+}
+
+func TestLatencyTriggerWakesEveryAction(t *testing.T) {
+	l := NewLatencyTrigger(time.Millisecond).Cooldown(0)
+	a, aRuns := newCountingAction()
+	b, bRuns := newCountingAction()
+	assert.Nil(t, a.OnLatency(l).Start())
+	defer a.End()
+	assert.Nil(t, b.OnLatency(l).Start())
+
+	for i := 0; i < minLatencySamples; i++ {
+		l.Observe(time.Second)
+	}
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(aRuns) > 0 && atomic.LoadInt32(bRuns) > 0
+	}, time.Second, time.Millisecond)
+
+	b.End()
+	assert.Len(t, l.events.watchers, 1)
+}
@@ -0,0 +1,56 @@
+package profile
+
+import (
+	"os"
+	"sync"
+)
+
+// LeaderLock elects one process on the host to run an action, by
+// holding an exclusive flock on a shared lockfile. The first process
+// to take the lock keeps it until its action ends, or it exits, at
+// which point another process takes over on its next run.
+type leaderLock struct {
+	path string
+	mu   sync.Mutex
+	file *os.File
+}
+
+func newLeaderLock(path string) *leaderLock {
+	return &leaderLock{path: path}
+}
+
+// Attempts to take the lock without blocking, returning true if this
+// process holds it.
+func (l *leaderLock) acquire() (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file != nil {
+		return true, nil
+	}
+
+	f, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return false, err
+	}
+
+	held, err := tryLock(f)
+	if !held {
+		f.Close()
+		return false, err
+	}
+
+	l.file = f
+	return true, nil
+}
+
+// Releases the lock if it's held. Closing the file drops the flock.
+func (l *leaderLock) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
+	}
+}
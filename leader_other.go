@@ -0,0 +1,12 @@
+//go:build !unix || aix || (solaris && !illumos)
+// +build !unix aix solaris,!illumos
+
+package profile
+
+import "os"
+
+const flockSupported = false
+
+func tryLock(f *os.File) (bool, error) {
+	return false, ErrUnsupported
+}
@@ -0,0 +1,24 @@
+//go:build unix && !aix && (!solaris || illumos)
+// +build unix
+// +build !aix
+// +build !solaris illumos
+
+package profile
+
+import (
+	"os"
+	"syscall"
+)
+
+const flockSupported = true
+
+// Takes an exclusive flock on the file without blocking. Returns
+// false with no error if another process holds it.
+func tryLock(f *os.File) (bool, error) {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return false, nil
+	}
+
+	return err == nil, err
+}
@@ -0,0 +1,64 @@
+package profile
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Returns the stacks of goroutines running methods of this package, or
+// supervised trigger loops, waiting a short while for any which are
+// still exiting.
+func swatGoroutines() []string {
+	var found []string
+	for i := 0; i < 50; i++ {
+		buf := make([]byte, 1<<20)
+		buf = buf[:runtime.Stack(buf, true)]
+
+		found = nil
+		for _, g := range strings.Split(string(buf), "\n\n") {
+			if strings.Contains(g, "swat.(*") || strings.Contains(g, "swat.supervise") {
+				found = append(found, g)
+			}
+		}
+
+		if len(found) == 0 {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return found
+}
+
+func TestInertActionStartsNoGoroutines(t *testing.T) {
+	a := NewAction(func(w io.Writer) error { return nil })
+	assert.Nil(t, a.Start())
+	assert.Empty(t, swatGoroutines())
+
+	a.End()
+	assert.Empty(t, swatGoroutines())
+}
+
+func TestEndLeavesNoGoroutines(t *testing.T) {
+	ch := make(chan struct{})
+	a := NewAction(func(w io.Writer) error { return nil }).
+		Every(time.Hour).
+		OnSignal(os.Interrupt).
+		When(func() bool { return false }, time.Hour).
+		OnChannel(ch).
+		AfterEveryNGCs(1).
+		OnUDP("127.0.0.1:0", []byte("swat")).
+		Async(1)
+
+	assert.Nil(t, a.Start())
+	assert.NotEmpty(t, swatGoroutines())
+
+	a.End()
+	assert.Empty(t, swatGoroutines())
+}
@@ -0,0 +1,44 @@
+package profile
+
+import (
+	"context"
+	"sync"
+)
+
+// Runs the actions until the context is done, then ends them, in the
+// shape errgroup expects:
+//
+//	g.Go(func() error { return profile.Run(ctx, actions...) })
+//
+// It returns an error only if the actions fail to start; a cancelled
+// context is a clean shutdown.
+func Run(ctx context.Context, actions ...Action) error {
+	s, err := Start(actions...)
+	if err != nil {
+		return err
+	}
+
+	<-ctx.Done()
+	s.End()
+	return nil
+}
+
+// Returns an execute and interrupt pair which run the actions, in the
+// shape oklog/run expects:
+//
+//	g.Add(profile.Actor(actions...))
+//
+// Execute blocks until interrupt is called, then ends the actions. It
+// returns an error only if the actions fail to start.
+func Actor(actions ...Action) (execute func() error, interrupt func(error)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var once sync.Once
+	execute = func() error {
+		return Run(ctx, actions...)
+	}
+	interrupt = func(error) {
+		once.Do(cancel)
+	}
+
+	return execute, interrupt
+}
@@ -0,0 +1,52 @@
+package profile
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket shared between actions to limit the
+// total number of runs across a Swat.
+type rateLimiter struct {
+	mu       sync.Mutex
+	capacity float64
+	tokens   float64
+	rate     float64 // tokens per nanosecond
+	last     time.Time
+}
+
+// Creates a limiter allowing n runs per minute, with bursts of up
+// to n runs.
+func newRateLimiter(perMinute int) *rateLimiter {
+	return &rateLimiter{
+		capacity: float64(perMinute),
+		tokens:   float64(perMinute),
+		rate:     float64(perMinute) / float64(time.Minute),
+		last:     time.Now(),
+	}
+}
+
+// Takes a token from the bucket, returning false if none are left.
+func (r *rateLimiter) allow() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.tokens += float64(now.Sub(r.last)) * r.rate
+	if r.tokens > r.capacity {
+		r.tokens = r.capacity
+	}
+	r.last = now
+
+	if r.tokens < 1 {
+		return false
+	}
+
+	r.tokens--
+	return true
+}
+
+// Actions which can share a Swat's rate limiter implement this.
+type rateLimited interface {
+	useLimiter(r *rateLimiter)
+}
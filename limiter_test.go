@@ -0,0 +1,29 @@
+package profile
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterAllowsBurstThenRefills(t *testing.T) {
+	r := newRateLimiter(3)
+	assert.True(t, r.allow())
+	assert.True(t, r.allow())
+	assert.True(t, r.allow())
+	assert.False(t, r.allow())
+
+	r.last = r.last.Add(-20 * time.Second)
+	assert.True(t, r.allow())
+	assert.False(t, r.allow())
+}
+
+func TestLimitRateRequiresPositiveLimit(t *testing.T) {
+	for _, n := range []int{0, -1} {
+		a, _ := newCountingAction()
+		err := new(Swat).LimitRate(n).Boot([]Action{a})
+		assert.True(t, errors.Is(err, ErrInvalidOption), n)
+		assert.False(t, a.Status().Running)
+	}
+}
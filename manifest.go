@@ -0,0 +1,99 @@
+package profile
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// A Manifest lists the artifacts the actions of a Swat have produced
+// since it booted, as written by ManifestTo.
+type Manifest struct {
+	// The version of the manifest's format; see SchemaVersion.
+	SchemaVersion int
+	// When the Swat booted.
+	Started time.Time
+	// Every successful run, in the order they finished, with the
+	// path of the file written, if any.
+	Artifacts []RunInfo
+}
+
+// Maintains a manifest file, rewriting it after each run.
+type manifestFile struct {
+	path string
+	mu   sync.Mutex
+	data Manifest
+}
+
+func newManifestFile(path string) *manifestFile {
+	return &manifestFile{path: path, data: Manifest{
+		SchemaVersion: SchemaVersion,
+		Started:       time.Now(),
+		Artifacts:     []RunInfo{},
+	}}
+}
+
+// Adds a run to the manifest, and writes it out.
+func (m *manifestFile) add(info RunInfo) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.data.Artifacts = append(m.data.Artifacts, info)
+	if err := m.write(); err != nil {
+		log.Printf("Swat Error: writing manifest: %s", err)
+	}
+}
+
+// Writes the manifest to a temporary file and renames it into place,
+// so that readers never see it half written.
+func (m *manifestFile) write() error {
+	data, err := json.MarshalIndent(&m.data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(m.path), filepath.Base(m.path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	// Temporary files are private, but the manifest is for tooling.
+	if err = tmp.Chmod(0644); err == nil {
+		_, err = tmp.Write(append(data, '\n'))
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), m.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+
+	return err
+}
+
+// Maintains a manifest of every artifact the Swat's actions produce,
+// as a Manifest in JSON, at the path. It's written when the actions
+// are booted, and rewritten atomically after every successful run, so
+// that downstream tooling can discover dumps, and what triggered them,
+// without globbing for files. It must be called before the actions
+// are booted.
+func (s *Swat) ManifestTo(path string) *Swat {
+	s.manifest = newManifestFile(path)
+	return s
+}
+
+// Actions which can add their runs to a Swat's manifest implement
+// this.
+type manifested interface {
+	useManifest(m *manifestFile)
+}
+
+func (b *BaseAction) useManifest(m *manifestFile) {
+	b.manifest = m
+}
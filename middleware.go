@@ -0,0 +1,27 @@
+package profile
+
+import "io"
+
+// A Middleware wraps an action's function, returning a function which
+// calls it, so that cross-cutting behavior, such as timing, compression
+// or injecting metadata, can be written once and applied to any action.
+type Middleware func(next func(io.Writer) error) func(io.Writer) error
+
+// `Use` wraps the function of each run with the middleware. The first
+// middleware given is outermost, and sees the action's output after
+// any formatting, such as JSONLines or Speedscope, has been applied.
+// Calling Use again adds to the chain, inside the middleware already
+// given.
+func (b *BaseAction) Use(mw ...Middleware) *BaseAction {
+	b.middleware = append(b.middleware, mw...)
+	return b
+}
+
+// Wraps the function in the action's middleware.
+func (b *BaseAction) wrapMiddleware(fn func(io.Writer) error) func(io.Writer) error {
+	for i := len(b.middleware) - 1; i >= 0; i-- {
+		fn = b.middleware[i](fn)
+	}
+
+	return fn
+}
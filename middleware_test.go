@@ -0,0 +1,56 @@
+package profile
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+)
+
+// Returns middleware which writes the tag around the output.
+func tagging(tag string) Middleware {
+	return func(next func(io.Writer) error) func(io.Writer) error {
+		return func(w io.Writer) error {
+			io.WriteString(w, "<"+tag+">")
+			err := next(w)
+			io.WriteString(w, "</"+tag+">")
+			return err
+		}
+	}
+}
+
+func TestUseWrapsInOrder(t *testing.T) {
+	buf := new(bytes.Buffer)
+	a := newOutputAction("heap", "dump").Use(tagging("a"), tagging("b")).Use(tagging("c")).ToWriter(buf)
+	assert.Nil(t, a.Trigger(context.Background()))
+	assert.Equal(t, "<a><b><c>dump</c></b></a>", buf.String())
+
+	// Clones keep their own chain.
+	buf.Reset()
+	c := a.Clone().Use(tagging("d")).ToWriter(buf)
+	assert.Nil(t, c.Trigger(context.Background()))
+	assert.Equal(t, "<a><b><c><d>dump</d></c></b></a>", buf.String())
+
+	buf.Reset()
+	a.ToWriter(buf)
+	assert.Nil(t, a.Trigger(context.Background()))
+	assert.Equal(t, "<a><b><c>dump</c></b></a>", buf.String())
+}
+
+func TestUseSeesFormattedOutput(t *testing.T) {
+	buf := new(bytes.Buffer)
+	upper := func(next func(io.Writer) error) func(io.Writer) error {
+		return func(w io.Writer) error {
+			out := new(strings.Builder)
+			err := next(out)
+			io.WriteString(w, strings.ToUpper(out.String()))
+			return err
+		}
+	}
+
+	a := newOutputAction("heap", "dump").JSONLines().Use(upper).ToWriter(buf)
+	assert.Nil(t, a.Trigger(context.Background()))
+	assert.Contains(t, buf.String(), `"PAYLOAD":"DUMP"`)
+}
@@ -0,0 +1,71 @@
+package profile
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// NATSPublisher publishes messages to NATS. Swat doesn't depend on a
+// NATS client; *nats.Conn implements this already, and a JetStream
+// context needs only a small adapter which drops the ack.
+type NATSPublisher interface {
+	Publish(subject string, data []byte) error
+}
+
+// The values available to NATS subject templates.
+type natsSubject struct {
+	RunInfo
+	// The host name, with dots replaced by underscores, since dots
+	// separate the tokens of a subject.
+	Host string
+}
+
+// NATSSink is a Sink which publishes each dump as a NATS message, for
+// deployments standardized on NATS or JetStream.
+type NATSSink struct {
+	pub     NATSPublisher
+	subject *template.Template
+	host    string
+}
+
+// Creates a sink which publishes dumps to the subject given by the
+// text/template, which is executed for each run. It can use {{.Name}},
+// the name of the action, {{.Ext}}, the extension of its output,
+// {{.Host}}, the host name, and any other field of RunInfo, as in
+// "dumps.{{.Host}}.{{.Name}}". Dumps must fit within the server's
+// max_payload, which is 1MB by default.
+func NewNATSSink(pub NATSPublisher, subject string) (*NATSSink, error) {
+	tmpl, err := template.New("subject").Parse(subject)
+	if err != nil {
+		return nil, err
+	}
+
+	host, _ := os.Hostname()
+	return &NATSSink{pub: pub, subject: tmpl, host: strings.ReplaceAll(host, ".", "_")}, nil
+}
+
+func (n *NATSSink) String() string {
+	return "nats subject " + n.subject.Root.String()
+}
+
+// Implements Sink.Open. The dump is published once the writer is
+// closed, and closing it returns any error from the publisher.
+func (n *NATSSink) Open(info RunInfo) (io.WriteCloser, error) {
+	return &bufferedWriter{info: info, flush: n.publish}, nil
+}
+
+func (n *NATSSink) publish(info RunInfo, data []byte) error {
+	subject := new(strings.Builder)
+	if err := n.subject.Execute(subject, natsSubject{info, n.host}); err != nil {
+		return err
+	}
+
+	if err := n.pub.Publish(subject.String(), data); err != nil {
+		return fmt.Errorf("publishing to nats: %w", err)
+	}
+
+	return nil
+}
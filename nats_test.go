@@ -0,0 +1,31 @@
+package profile
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type fakePublisher struct {
+	subjects []string
+	data     []string
+}
+
+func (f *fakePublisher) Publish(subject string, data []byte) error {
+	f.subjects = append(f.subjects, subject)
+	f.data = append(f.data, string(data))
+	return nil
+}
+
+func TestNATSSinkTemplatesSubject(t *testing.T) {
+	pub := new(fakePublisher)
+	sink, err := NewNATSSink(pub, "dumps.{{.Name}}.{{.Ext}}")
+	assert.Nil(t, err)
+
+	assert.Nil(t, newOutputAction("heap", "dump").To(sink).Trigger(context.Background()))
+	assert.Equal(t, []string{"dumps.heap.out"}, pub.subjects)
+	assert.Equal(t, []string{"dump"}, pub.data)
+
+	_, err = NewNATSSink(pub, "dumps.{{.Name")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,78 @@
+package profile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+)
+
+// The default template for object names, which groups dumps by
+// action and orders them by time.
+const objectTemplate = "{{.Name}}/{{.Time}}.{{.Ext}}"
+
+// Names the objects uploaded by object storage sinks from a
+// text/template, using the same fields as ToFileTemplate.
+type objectNamer struct {
+	tmpl *template.Template
+}
+
+func newObjectNamer(pattern string) (objectNamer, error) {
+	if pattern == "" {
+		pattern = objectTemplate
+	}
+
+	tmpl, err := template.New("object").Parse(pattern)
+	return objectNamer{tmpl}, err
+}
+
+func (o objectNamer) name(info RunInfo) (string, error) {
+	name := new(strings.Builder)
+	err := o.tmpl.Execute(name, runPathVars(info))
+	return name.String(), err
+}
+
+// Sends the request, failing if it doesn't succeed. The error holds
+// the start of the response body, which usually explains why.
+func upload(client *http.Client, req *http.Request) error {
+	res, err := send(client, req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		return uploadError(req, res)
+	}
+
+	io.Copy(io.Discard, res.Body)
+	return nil
+}
+
+// Sends the request. The URL's query and credentials, which may hold
+// secrets such as SAS tokens, are left out of errors.
+func send(client *http.Client, req *http.Request) (*http.Response, error) {
+	res, err := client.Do(req)
+	if err != nil {
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			u := *req.URL
+			u.User, u.RawQuery, u.ForceQuery = nil, "", false
+			return nil, &url.Error{Op: uerr.Op, URL: u.String(), Err: uerr.Err}
+		}
+
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// Returns the error for an upload which got an unexpected response.
+func uploadError(req *http.Request, res *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+	return fmt.Errorf("uploading to %s: %s: %s", req.URL.Host, res.Status, bytes.TrimSpace(msg))
+}
@@ -0,0 +1,205 @@
+package profile
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type recordedUpload struct {
+	method, path, query, body string
+	header                    http.Header
+}
+
+func newUploadServer(t *testing.T, status int) (*httptest.Server, *[]recordedUpload) {
+	var uploads []recordedUpload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		uploads = append(uploads, recordedUpload{r.Method, r.URL.Path, r.URL.RawQuery, string(body), r.Header})
+		w.WriteHeader(status)
+		w.Write([]byte("denied"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &uploads
+}
+
+func TestGCSSinkUploadsObjects(t *testing.T) {
+	srv, uploads := newUploadServer(t, 200)
+	sink, err := NewGCSSink(srv.Client(), "dumps", "")
+	assert.Nil(t, err)
+	sink.endpoint = srv.URL
+
+	assert.Nil(t, newOutputAction("heap", "dump").To(sink).Trigger(context.Background()))
+	if assert.Len(t, *uploads, 1) {
+		u := (*uploads)[0]
+		assert.Equal(t, "POST", u.method)
+		assert.Equal(t, "/upload/storage/v1/b/dumps/o", u.path)
+		assert.True(t, strings.HasPrefix(u.query, "uploadType=media&name=heap%2F"), u.query)
+		assert.Equal(t, "dump", u.body)
+	}
+}
+
+func TestGCSSinkResumesUploads(t *testing.T) {
+	var received []byte
+	var ranges []string
+	failed := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			assert.Equal(t, "uploadType=resumable&name=heap.out", r.URL.RawQuery)
+			w.Header().Set("Location", "http://"+r.Host+"/session?upload_id=secret")
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		rng := r.Header.Get("Content-Range")
+		ranges = append(ranges, rng)
+		if !strings.HasPrefix(rng, "bytes */") {
+			received = append(received, body...)
+		}
+
+		// The second chunk arrives, but its response is lost.
+		if len(ranges) == 2 && !failed {
+			failed = true
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		if len(received) < 3*gcsChunkQuantum {
+			w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(received)-1))
+			w.WriteHeader(308)
+		}
+	}))
+	defer srv.Close()
+
+	sink, err := NewGCSSink(srv.Client(), "dumps", "{{.Name}}.{{.Ext}}")
+	assert.Nil(t, err)
+	sink.endpoint = srv.URL
+	var waits []time.Duration
+	sink.sleep = func(d time.Duration) { waits = append(waits, d) }
+	sink.Resumable(1, 3)
+
+	data := bytes.Repeat([]byte("x"), 3*gcsChunkQuantum)
+	a := NewAction(func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	}).Name("heap").To(sink)
+	assert.Nil(t, a.Trigger(context.Background()))
+	assert.Equal(t, data, received)
+	assert.Equal(t, []time.Duration{time.Second}, waits)
+	assert.Equal(t, []string{
+		"bytes 0-262143/786432",
+		"bytes 262144-524287/786432",
+		"bytes */786432",
+		"bytes 524288-786431/786432",
+	}, ranges)
+}
+
+func TestGCSSinkGivesUpWithoutProgress(t *testing.T) {
+	var puts int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			w.Header().Set("Location", "http://"+r.Host+"/session")
+			return
+		}
+
+		// Only the first chunk is ever taken.
+		puts++
+		w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", gcsChunkQuantum-1))
+		w.WriteHeader(308)
+	}))
+	defer srv.Close()
+
+	sink, err := NewGCSSink(srv.Client(), "dumps", "")
+	assert.Nil(t, err)
+	sink.endpoint = srv.URL
+	var waits []time.Duration
+	sink.sleep = func(d time.Duration) { waits = append(waits, d) }
+	sink.Resumable(1, 3)
+
+	a := NewAction(func(w io.Writer) error {
+		_, err := w.Write(make([]byte, 2*gcsChunkQuantum))
+		return err
+	}).Name("heap").To(sink)
+	err = a.Trigger(context.Background())
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "no bytes from offset 262144")
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, waits)
+	assert.Equal(t, 6, puts)
+}
+
+func TestGCSSinkGivesUpResuming(t *testing.T) {
+	srv, uploads := newUploadServer(t, http.StatusServiceUnavailable)
+	sink, err := NewGCSSink(srv.Client(), "dumps", "")
+	assert.Nil(t, err)
+	sink.endpoint = srv.URL
+	sink.sleep = func(time.Duration) {}
+
+	// Without a session, there's nothing to resume.
+	sink.Resumable(1, 3)
+	a := NewAction(func(w io.Writer) error {
+		_, err := w.Write(make([]byte, gcsChunkQuantum+1))
+		return err
+	}).Name("heap").To(sink)
+	assert.Contains(t, a.Trigger(context.Background()).Error(), "503")
+	assert.Len(t, *uploads, 1)
+
+	assert.True(t, errors.Is(newOutputAction("heap", "").To(sink.Resumable(0, 1)).Start(), ErrInvalidOption))
+}
+
+func TestAzureBlobSinkUploadsBlobs(t *testing.T) {
+	srv, uploads := newUploadServer(t, 201)
+	sink, err := NewAzureBlobSink(srv.Client(), srv.URL+"/dumps?sig=secret", "{{.Name}}.{{.Ext}}")
+	assert.Nil(t, err)
+	assert.NotContains(t, sink.String(), "secret")
+
+	assert.Nil(t, newOutputAction("heap", "dump").To(sink).Trigger(context.Background()))
+	if assert.Len(t, *uploads, 1) {
+		u := (*uploads)[0]
+		assert.Equal(t, "PUT", u.method)
+		assert.Equal(t, "/dumps/heap.out", u.path)
+		assert.Equal(t, "sig=secret", u.query)
+		assert.Equal(t, "BlockBlob", u.header.Get("x-ms-blob-type"))
+	}
+}
+
+func TestObjectStoreSinksDefaultClient(t *testing.T) {
+	srv, uploads := newUploadServer(t, 200)
+	azure, err := NewAzureBlobSink(nil, srv.URL+"/dumps", "")
+	assert.Nil(t, err)
+	gcs, err := NewGCSSink(nil, "dumps", "")
+	assert.Nil(t, err)
+	gcs.endpoint = srv.URL
+
+	assert.Nil(t, newOutputAction("heap", "dump").To(azure).Trigger(context.Background()))
+	assert.Nil(t, newOutputAction("heap", "dump").To(gcs).Trigger(context.Background()))
+	assert.Len(t, *uploads, 2)
+}
+
+func TestUploadReportsFailures(t *testing.T) {
+	srv, _ := newUploadServer(t, 403)
+	sink, _ := NewAzureBlobSink(srv.Client(), srv.URL+"/dumps", "")
+	err := newOutputAction("heap", "dump").To(sink).Trigger(context.Background())
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "403 Forbidden: denied")
+	}
+}
+
+func TestUploadHidesSecretsFromErrors(t *testing.T) {
+	srv, _ := newUploadServer(t, 201)
+	srv.Close()
+
+	sink, _ := NewAzureBlobSink(srv.Client(), srv.URL+"/dumps?sv=2020&sig=secret", "")
+	err := newOutputAction("heap", "dump").To(sink).Trigger(context.Background())
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), srv.URL+"/dumps/heap/")
+		assert.NotContains(t, err.Error(), "secret")
+	}
+}
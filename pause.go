@@ -0,0 +1,32 @@
+package profile
+
+import "sync/atomic"
+
+// A pauser is shared by the actions of a Swat, so that they can all be
+// paused and resumed at once.
+type pauser struct {
+	paused int32
+	// signals is set if signals should still trigger runs while paused.
+	signals bool
+}
+
+func (p *pauser) isPaused() bool {
+	return atomic.LoadInt32(&p.paused) == 1
+}
+
+// Wraps the function so that calls made while paused are dropped,
+// unless they come from a signal and signals are still honored.
+func (p *pauser) wrap(fn func(), signal bool) func() {
+	return func() {
+		if p.isPaused() && !(signal && p.signals) {
+			return
+		}
+
+		fn()
+	}
+}
+
+// Actions which can be paused along with a Swat implement this.
+type pausable interface {
+	usePauser(p *pauser)
+}
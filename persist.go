@@ -0,0 +1,96 @@
+package profile
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// The state of an action which is saved across restarts.
+type persistedState struct {
+	// The number of times the action has run.
+	Runs uint64 `json:"runs"`
+	// When the action last ran.
+	LastRun time.Time `json:"last_run,omitempty"`
+	// The deadline of a schedule using `For`.
+	Until time.Time `json:"until,omitempty"`
+}
+
+// StateFile keeps an action's persisted state in a small JSON file.
+type stateFile struct {
+	path  string
+	mu    sync.Mutex
+	state persistedState
+}
+
+// Loads the state from the file, which may not exist yet.
+func loadStateFile(path string) (*stateFile, error) {
+	s := &stateFile{path: path}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &s.state); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Returns a copy of the current state.
+func (s *stateFile) snapshot() persistedState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// Records a run which started at the given time.
+func (s *stateFile) recordRun(start time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.state.Runs++
+	s.state.LastRun = start
+	return s.save()
+}
+
+// Records the deadline of the schedule.
+func (s *stateFile) setUntil(until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.state.Until = until
+	return s.save()
+}
+
+// Writes the state to a temporary file and renames it into place,
+// so that a crash never leaves a half-written file behind.
+func (s *stateFile) save() error {
+	data, err := json.Marshal(&s.state)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,118 @@
+package profile
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The process's trigger pipe is shared by all the actions listening
+// on it: it's opened when the first starts and closed when the last
+// ends. Each line written to it is a command, and runs the actions
+// listening for that command. Opening and closing the pipe is
+// serialized by lifecycle, while handlers are guarded by the RWMutex,
+// which commands hold while they run.
+var pipes = struct {
+	sync.RWMutex
+	lifecycle sync.Mutex
+	handlers  map[*pipeTrigger]func()
+	stop      func()
+}{handlers: map[*pipeTrigger]func(){}}
+
+// `PipeName` returns the name of the named pipe which OnPipeCommand
+// listens on, like `\\.\pipe\swat-1234`.
+func PipeName() string {
+	return `\\.\pipe\swat-` + strconv.Itoa(os.Getpid())
+}
+
+// PipeTrigger is a trigger which runs an action when its command is
+// written to the process's named pipe.
+type pipeTrigger struct {
+	command string
+}
+
+func newPipeTrigger(command string) *pipeTrigger {
+	return &pipeTrigger{command: command}
+}
+
+func (p *pipeTrigger) String() string {
+	return "on pipe command " + strconv.Quote(p.command)
+}
+
+func (p *pipeTrigger) source() TriggerSource {
+	return SourceSignal
+}
+
+func (p *pipeTrigger) clone() trigger {
+	return newPipeTrigger(p.command)
+}
+
+func (p *pipeTrigger) start(fn func()) error {
+	pipes.lifecycle.Lock()
+	defer pipes.lifecycle.Unlock()
+
+	if len(pipes.handlers) == 0 {
+		stop, err := listenPipe(PipeName(), runPipeCommands)
+		if err != nil {
+			return err
+		}
+
+		pipes.stop = stop
+	}
+
+	pipes.Lock()
+	pipes.handlers[p] = fn
+	pipes.Unlock()
+	return nil
+}
+
+// Removing the handler waits for commands being run, so that the
+// action isn't run once the trigger has ended.
+func (p *pipeTrigger) end() {
+	pipes.lifecycle.Lock()
+	defer pipes.lifecycle.Unlock()
+
+	pipes.Lock()
+	_, ok := pipes.handlers[p]
+	delete(pipes.handlers, p)
+	last := ok && len(pipes.handlers) == 0
+	pipes.Unlock()
+
+	if last {
+		pipes.stop()
+		pipes.stop = nil
+	}
+}
+
+// Runs the command on each line read from r, until it's exhausted.
+// Unknown commands are logged, so that typos don't go unnoticed.
+func runPipeCommands(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		command := strings.TrimSpace(scanner.Text())
+		if command != "" && !runPipeCommand(command) {
+			log.Printf("Swat Error: no action listens for pipe command %q", command)
+		}
+	}
+}
+
+// Runs the actions listening for the command, returning false if
+// there are none.
+func runPipeCommand(command string) bool {
+	pipes.RLock()
+	defer pipes.RUnlock()
+
+	ran := false
+	for p, fn := range pipes.handlers {
+		if p.command == command {
+			fn()
+			ran = true
+		}
+	}
+
+	return ran
+}
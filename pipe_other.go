@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package profile
+
+import "io"
+
+// Named pipes are only used on Windows, where services can't be sent
+// signals.
+func listenPipe(name string, handle func(io.Reader)) (func(), error) {
+	return nil, ErrUnsupported
+}
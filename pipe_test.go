@@ -0,0 +1,46 @@
+package profile
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestPipeCommandsRunListeningActions(t *testing.T) {
+	heap, goroutines := newPipeTrigger("heap"), newPipeTrigger("goroutines")
+
+	var ran []string
+	pipes.Lock()
+	pipes.handlers[heap] = func() { ran = append(ran, "heap") }
+	pipes.handlers[goroutines] = func() { ran = append(ran, "goroutines") }
+	pipes.Unlock()
+	defer func() {
+		pipes.Lock()
+		delete(pipes.handlers, heap)
+		delete(pipes.handlers, goroutines)
+		pipes.Unlock()
+	}()
+
+	runPipeCommands(strings.NewReader("heap\r\n\n  goroutines \nunknown\nheap\n"))
+	assert.Equal(t, []string{"heap", "goroutines", "heap"}, ran)
+}
+
+func TestPipeCommandIsValidated(t *testing.T) {
+	a, _ := newCountingAction()
+	assert.True(t, errors.Is(a.OnPipeCommand(" heap").Start(), ErrInvalidOption))
+	a, _ = newCountingAction()
+	assert.True(t, errors.Is(a.OnPipeCommand("").Start(), ErrInvalidOption))
+}
+
+func TestPipeCommandUnsupportedOffWindows(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("named pipes are supported")
+	}
+
+	a, _ := newCountingAction()
+	a.OnPipeCommand("heap")
+	assert.True(t, errors.Is(a.Start(), ErrUnsupported))
+	assert.Len(t, pipes.handlers, 0)
+}
@@ -0,0 +1,107 @@
+package profile
+
+import (
+	"io"
+	"log"
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// The syscall package doesn't wrap the named pipe server calls.
+var (
+	kernel32            = syscall.NewLazyDLL("kernel32.dll")
+	procCreateNamedPipe = kernel32.NewProc("CreateNamedPipeW")
+	procConnectPipe     = kernel32.NewProc("ConnectNamedPipe")
+)
+
+const (
+	pipeAccessInbound         = 0x1
+	fileFlagFirstPipeInstance = 0x80000
+	pipeUnlimitedInstances    = 255
+	errorPipeConnected        = syscall.Errno(535)
+)
+
+// Creates an instance of the pipe which clients may write to. The
+// default security descriptor only lets the process's user, and
+// administrators, do so.
+func createPipe(name string, first bool) (syscall.Handle, error) {
+	p, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return 0, err
+	}
+
+	mode := uintptr(pipeAccessInbound)
+	if first {
+		// Fail, rather than share, if someone else has the name.
+		mode |= fileFlagFirstPipeInstance
+	}
+
+	h, _, err := procCreateNamedPipe.Call(uintptr(unsafe.Pointer(p)), mode, 0,
+		pipeUnlimitedInstances, 0, 4096, 0, 0)
+	if syscall.Handle(h) == syscall.InvalidHandle {
+		return 0, err
+	}
+
+	return syscall.Handle(h), nil
+}
+
+// Listens on the named pipe, passing each client's connection to
+// handle, until the returned function is called. Clients are served
+// one at a time.
+func listenPipe(name string, handle func(io.Reader)) (func(), error) {
+	h, err := createPipe(name, true)
+	if err != nil {
+		return nil, err
+	}
+
+	closer := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		for {
+			ok, _, err := procConnectPipe.Call(uintptr(h), 0)
+			if ok == 0 && err != errorPipeConnected {
+				syscall.CloseHandle(h)
+				log.Printf("Swat Error: listening on %s: %s", name, err)
+				return
+			}
+
+			select {
+			case <-closer:
+				syscall.CloseHandle(h)
+				return
+			default:
+			}
+
+			f := os.NewFile(uintptr(h), name)
+			handle(f)
+			f.Close()
+
+			if h, err = createPipe(name, false); err != nil {
+				log.Printf("Swat Error: listening on %s: %s", name, err)
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(closer)
+		// ConnectNamedPipe blocks until a client connects, so connect
+		// to wake it up. A client may be being served, in which case
+		// there's no instance to connect to until it's done.
+		for {
+			if f, err := os.OpenFile(name, os.O_WRONLY, 0); err == nil {
+				f.Close()
+			}
+
+			select {
+			case <-done:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}, nil
+}
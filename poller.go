@@ -0,0 +1,70 @@
+package profile
+
+import (
+	"fmt"
+	"time"
+)
+
+// A trigger is an event source which runs an action's function until
+// it's ended. Start should return an error if the trigger can't be
+// set up, and otherwise begin listening asynchronously. Triggers may
+// be started again after they've been ended. String describes the
+// trigger for humans, as in "when condition holds (polled every 1s)".
+// Clone returns a trigger with the same configuration, which isn't
+// running.
+type trigger interface {
+	fmt.Stringer
+	start(fn func()) error
+	end()
+	clone() trigger
+}
+
+// Poller is a trigger which checks a predicate on an interval, and
+// runs the action whenever the predicate holds.
+type poller struct {
+	pred   func() bool
+	poll   time.Duration
+	closer chan struct{}
+	done   chan struct{}
+}
+
+func newPoller(pred func() bool, poll time.Duration) *poller {
+	return &poller{pred: pred, poll: poll}
+}
+
+func (p *poller) String() string {
+	return fmt.Sprintf("when condition holds (polled every %s)", p.poll)
+}
+
+func (p *poller) clone() trigger {
+	return newPoller(p.pred, p.poll)
+}
+
+func (p *poller) end() {
+	close(p.closer)
+	<-p.done
+}
+
+func (p *poller) start(fn func()) error {
+	p.closer = make(chan struct{})
+	p.done = make(chan struct{})
+	supervise(p, p.closer, p.done, func() error { p.run(fn); return nil })
+
+	return nil
+}
+
+func (p *poller) run(fn func()) {
+	ticker := time.NewTicker(p.poll)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.closer:
+			return
+		case <-ticker.C:
+			if p.pred() {
+				fn()
+			}
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package profile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// The parts of a pprof profile needed to convert it to other
+// formats. See github.com/google/pprof/proto/profile.proto.
+type pprofProfile struct {
+	sampleTypes []pprofValueType
+	samples     []pprofSample
+	locations   map[uint64][]pprofLine
+	functions   map[uint64]pprofFunction
+	strings     []string
+}
+
+type pprofValueType struct {
+	typ, unit int64
+}
+
+type pprofSample struct {
+	// Locations are leaf first.
+	locations []uint64
+	values    []int64
+}
+
+type pprofLine struct {
+	function uint64
+	line     int64
+}
+
+type pprofFunction struct {
+	name, file int64
+}
+
+// Returned when the data to convert isn't a pprof profile, such as
+// a text goroutine dump.
+var errNotPProf = fmt.Errorf("%w of pprof profile", ErrUnexpectedFormat)
+
+// Returns the string at the index in the profile's string table.
+func (p *pprofProfile) str(i int64) string {
+	if i < 0 || i >= int64(len(p.strings)) {
+		return ""
+	}
+
+	return p.strings[i]
+}
+
+// Parses a pprof profile, which may be gzipped.
+func parsePProf(data []byte) (*pprofProfile, error) {
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+
+		if data, err = io.ReadAll(gz); err != nil {
+			return nil, err
+		}
+	}
+
+	p := &pprofProfile{
+		locations: make(map[uint64][]pprofLine),
+		functions: make(map[uint64]pprofFunction),
+	}
+
+	err := eachField(data, func(field int, v uint64, msg []byte) error {
+		switch field {
+		case 1:
+			var vt pprofValueType
+			err := eachField(msg, func(field int, v uint64, _ []byte) error {
+				switch field {
+				case 1:
+					vt.typ = int64(v)
+				case 2:
+					vt.unit = int64(v)
+				}
+				return nil
+			})
+			p.sampleTypes = append(p.sampleTypes, vt)
+			return err
+		case 2:
+			var s pprofSample
+			err := eachField(msg, func(field int, v uint64, packed []byte) error {
+				switch field {
+				case 1:
+					return eachVarint(v, packed, func(v uint64) { s.locations = append(s.locations, v) })
+				case 2:
+					return eachVarint(v, packed, func(v uint64) { s.values = append(s.values, int64(v)) })
+				}
+				return nil
+			})
+			p.samples = append(p.samples, s)
+			return err
+		case 4:
+			var id uint64
+			var lines []pprofLine
+			err := eachField(msg, func(field int, v uint64, msg []byte) error {
+				switch field {
+				case 1:
+					id = v
+				case 4:
+					var l pprofLine
+					err := eachField(msg, func(field int, v uint64, _ []byte) error {
+						switch field {
+						case 1:
+							l.function = v
+						case 2:
+							l.line = int64(v)
+						}
+						return nil
+					})
+					lines = append(lines, l)
+					return err
+				}
+				return nil
+			})
+			p.locations[id] = lines
+			return err
+		case 5:
+			var id uint64
+			var fn pprofFunction
+			err := eachField(msg, func(field int, v uint64, _ []byte) error {
+				switch field {
+				case 1:
+					id = v
+				case 2:
+					fn.name = int64(v)
+				case 4:
+					fn.file = int64(v)
+				}
+				return nil
+			})
+			p.functions[id] = fn
+			return err
+		case 6:
+			p.strings = append(p.strings, string(msg))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Every profile's string table starts with the empty string.
+	if len(p.strings) == 0 || p.strings[0] != "" {
+		return nil, errNotPProf
+	}
+
+	return p, nil
+}
+
+// Calls fn with each field of a protobuf message: varints and fixed
+// width values are passed as v, and length-delimited values as msg.
+func eachField(data []byte, fn func(field int, v uint64, msg []byte) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errNotPProf
+		}
+		data = data[n:]
+
+		var v uint64
+		var msg []byte
+		switch key & 7 {
+		case 0:
+			if v, n = binary.Uvarint(data); n <= 0 {
+				return errNotPProf
+			}
+			data = data[n:]
+		case 1:
+			if len(data) < 8 {
+				return errNotPProf
+			}
+			v, data = binary.LittleEndian.Uint64(data), data[8:]
+		case 2:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < size {
+				return errNotPProf
+			}
+			msg, data = data[n:n+int(size)], data[n+int(size):]
+		case 5:
+			if len(data) < 4 {
+				return errNotPProf
+			}
+			v, data = uint64(binary.LittleEndian.Uint32(data)), data[4:]
+		default:
+			return errNotPProf
+		}
+
+		if err := fn(int(key>>3), v, msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Calls fn with each value of a repeated varint field, which may be
+// packed or not.
+func eachVarint(v uint64, packed []byte, fn func(uint64)) error {
+	if packed == nil {
+		fn(v)
+		return nil
+	}
+
+	for len(packed) > 0 {
+		v, n := binary.Uvarint(packed)
+		if n <= 0 {
+			return errNotPProf
+		}
+
+		fn(v)
+		packed = packed[n:]
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package profile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+)
+
+// Returns a set of actions giving sensible production diagnostics,
+// writing into the directory:
+//
+//   - goroutine, heap and memory statistics dumps to new files on
+//     SIGQUIT, as by DiagnosticsOnSIGQUIT,
+//   - memory statistics every minute, appended to memstats.json,
+//   - the binary's build information once, on start.
+//
+// Use it like `profile.Start(profile.StandardDiagnostics(dir)...)`.
+// SIGQUIT keeps Go's default behavior of dumping goroutines and
+// exiting once the dumps are written.
+func StandardDiagnostics(dir string) []Action {
+	return []Action{
+		DiagnosticsOnSIGQUIT(dir),
+		DumpMemStats().Every(time.Minute).ToFile(filepath.Join(dir, "memstats.json")),
+		DumpBuildInfo().At(time.Now()).ToDir(dir),
+	}
+}
+
+// Returns an action which, on SIGQUIT, writes a goroutine dump with
+// full stacks, a heap profile and the memory statistics to new files
+// in the directory, named as by ToDir, and then lets the signal have
+// its default effect, so that the process still dumps its goroutines
+// to stderr and exits. This gives the JVM-style "kill -QUIT gives you
+// everything" in one call:
+//
+//	profile.Start(profile.DiagnosticsOnSIGQUIT(dir))
+//
+// The process exits even if some of the dumps fail, or are skipped,
+// such as while the Swat is paused. On platforms
+// without SIGQUIT, starting the action fails with ErrUnsupported.
+func DiagnosticsOnSIGQUIT(dir string) *BaseAction {
+	dumps := []*BaseAction{
+		DumpGoroutine().ToDir(dir),
+		DumpHeap().ToDir(dir),
+		DumpMemStats().ToDir(dir),
+	}
+
+	a := onQuit(NewActionCtx(func(ctx context.Context, w io.Writer) error {
+		var errs []error
+		for _, d := range dumps {
+			errs = append(errs, d.Trigger(ctx))
+		}
+
+		return errors.Join(errs...)
+	}).Name("sigquit"))
+
+	// The signal is raised again by the signaler rather than by the
+	// run, so that it still takes effect when the run is dropped, such
+	// as while the action is paused or rate limited.
+	a.signaler.reraise = true
+	return a
+}
+
+// Runs the action on SIGQUIT. On platforms without it, such as
+// Plan 9 and WebAssembly, starting the action fails with
+// ErrUnsupported instead.
+func onQuit(a *BaseAction) *BaseAction {
+	if sigquit == nil {
+		if a.lastErr == nil {
+			a.lastErr = fmt.Errorf("%w: there's no SIGQUIT", ErrUnsupported)
+		}
+
+		return a
+	}
+
+	return a.OnSignal(sigquit)
+}
+
+// Stops handling the signal, and sends it to the process again, so
+// that it has its default effect. It's a variable so that tests can
+// stop the process from exiting.
+var reraise = func(sig os.Signal) {
+	signal.Reset(sig)
+	if p, err := os.FindProcess(os.Getpid()); err == nil {
+		p.Signal(sig)
+	}
+}
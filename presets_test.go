@@ -0,0 +1,109 @@
+package profile
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStandardDiagnostics(t *testing.T) {
+	dir := "dumps"
+	var descriptions []string
+	for _, a := range StandardDiagnostics(dir) {
+		descriptions = append(descriptions, a.(*BaseAction).Describe())
+	}
+
+	if assert.Len(t, descriptions, 3) {
+		assert.Equal(t, "sigquit on signal quit → nowhere", descriptions[0])
+		assert.Equal(t, "memstats every 1m0s → "+filepath.Join(dir, "memstats.json"), descriptions[1])
+		assert.True(t, strings.HasPrefix(descriptions[2], "buildinfo at "), descriptions[2])
+		assert.True(t, strings.HasSuffix(descriptions[2], " once → "+filepath.Join(dir, "buildinfo-*.txt")), descriptions[2])
+	}
+
+	// Like DiagnosticsOnSIGQUIT, it lets SIGQUIT exit the process.
+	assert.True(t, StandardDiagnostics(dir)[0].(*BaseAction).signaler.reraise)
+}
+
+func TestDumpMemStats(t *testing.T) {
+	buf := new(bytes.Buffer)
+	assert.Nil(t, DumpMemStats().ToWriter(buf).Trigger(context.Background()))
+	assert.True(t, strings.HasPrefix(buf.String(), `{"Time":`), buf.String())
+	assert.True(t, strings.HasSuffix(buf.String(), "}\n"))
+
+	var out struct {
+		Time time.Time
+		runtime.MemStats
+	}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &out))
+	assert.False(t, out.Time.IsZero())
+	assert.NotZero(t, out.HeapAlloc)
+}
+
+func TestDumpBuildInfo(t *testing.T) {
+	buf := new(bytes.Buffer)
+	assert.Nil(t, DumpBuildInfo().ToWriter(buf).Trigger(context.Background()))
+	assert.True(t, strings.HasPrefix(buf.String(), "go\t"+runtime.Version()+"\n"), buf.String())
+	assert.Contains(t, buf.String(), "\npath\t")
+}
+
+func TestDiagnosticsOnSIGQUIT(t *testing.T) {
+	defer func(orig func(os.Signal)) { reraise = orig }(reraise)
+	var reraised []os.Signal
+	reraise = func(sig os.Signal) { reraised = append(reraised, sig) }
+
+	dir := t.TempDir()
+	a := DiagnosticsOnSIGQUIT(dir)
+	if sigquit == nil {
+		assert.True(t, errors.Is(a.Start(), ErrUnsupported))
+		return
+	}
+
+	assert.Nil(t, a.Start())
+	defer a.End()
+
+	assert.True(t, a.Signal(sigquit))
+	assert.Equal(t, []os.Signal{sigquit}, reraised)
+
+	files, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	var names []string
+	for _, f := range files {
+		info, err := f.Info()
+		assert.Nil(t, err)
+		assert.NotZero(t, info.Size(), f.Name())
+		names = append(names, strings.SplitN(f.Name(), "-", 2)[0])
+	}
+	assert.ElementsMatch(t, []string{"goroutine", "heap", "memstats"}, names)
+}
+
+func TestDiagnosticsOnSIGQUITReraisesWhenRunsAreDropped(t *testing.T) {
+	if sigquit == nil {
+		t.Skip("no SIGQUIT on this platform")
+	}
+
+	defer func(orig func(os.Signal)) { reraise = orig }(reraise)
+	var reraised []os.Signal
+	reraise = func(sig os.Signal) { reraised = append(reraised, sig) }
+
+	dir := t.TempDir()
+	a := DiagnosticsOnSIGQUIT(dir)
+	s := new(Swat)
+	s.Pause()
+	assert.Nil(t, s.Boot([]Action{a}))
+	defer s.End()
+
+	assert.True(t, a.Signal(sigquit))
+	assert.Equal(t, []os.Signal{sigquit}, reraised)
+
+	files, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Empty(t, files)
+}
@@ -0,0 +1,159 @@
+package profile
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io"
+	"net"
+	"time"
+)
+
+// How long probes wait before giving up.
+const probeTimeout = 10 * time.Second
+
+// A certificate in a TLS probe's chain.
+type probedCert struct {
+	Subject  string
+	Issuer   string
+	NotAfter time.Time
+	// The time left until the certificate expires, which is negative
+	// once it has.
+	ExpiresIn time.Duration
+}
+
+// The result of a TLS probe.
+type tlsProbe struct {
+	Time time.Time
+	Addr string
+	// How long the handshake took, including connecting.
+	Handshake time.Duration `json:",omitempty"`
+	Chain     []probedCert  `json:",omitempty"`
+	// Why the chain isn't trusted, if it isn't.
+	VerifyError string `json:",omitempty"`
+	// Why the probe failed, if it did.
+	Error string `json:",omitempty"`
+}
+
+// Returns when the first certificate in the chain expires.
+func (p *tlsProbe) firstExpiry() (time.Time, bool) {
+	var first time.Time
+	for _, c := range p.Chain {
+		if first.IsZero() || c.NotAfter.Before(first) {
+			first = c.NotAfter
+		}
+	}
+
+	return first, !first.IsZero()
+}
+
+// Connects to the address over TLS, and records the handshake time
+// and the certificates the server presents. The chain is recorded
+// even if it isn't trusted, so that expired certificates show up.
+func probeTLS(addr string) tlsProbe {
+	p := tlsProbe{Time: time.Now(), Addr: addr}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		p.Error = err.Error()
+		return p
+	}
+
+	dialer := &net.Dialer{Timeout: probeTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{
+		ServerName: host,
+		// The chain is verified below, so that it's recorded anyway.
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		p.Error = err.Error()
+		return p
+	}
+	defer conn.Close()
+
+	p.Handshake = time.Since(p.Time)
+	certs := conn.ConnectionState().PeerCertificates
+	for _, c := range certs {
+		p.Chain = append(p.Chain, probedCert{
+			Subject:   c.Subject.String(),
+			Issuer:    c.Issuer.String(),
+			NotAfter:  c.NotAfter,
+			ExpiresIn: time.Until(c.NotAfter),
+		})
+	}
+
+	if len(certs) > 0 {
+		opts := x509.VerifyOptions{DNSName: host, Intermediates: x509.NewCertPool()}
+		for _, c := range certs[1:] {
+			opts.Intermediates.AddCert(c)
+		}
+		if _, err := certs[0].Verify(opts); err != nil {
+			p.VerifyError = err.Error()
+		}
+	}
+
+	return p
+}
+
+// Returns an action that connects to the address, such as
+// "example.com:443", over TLS, and writes a line of JSON with the
+// handshake latency and the expiry of each certificate in the chain.
+// Failures to connect are recorded in an "Error" field rather than
+// failing the run, so that they appear on the same timeline. Combine
+// it with TLSExpiresWithin to dump diagnostics as expiry approaches.
+func ProbeTLS(addr string) *BaseAction {
+	b := NewAction(func(w io.Writer) error {
+		p := probeTLS(addr)
+		return json.NewEncoder(w).Encode(&p)
+	}).Name("tls")
+
+	b.ext = "json"
+	return b
+}
+
+// Returns a condition which holds when a certificate presented by the
+// server at the address expires within the duration. It connects each
+// time it's checked, so poll it sparingly. It doesn't hold if the
+// server can't be reached.
+func TLSExpiresWithin(addr string, d time.Duration) func() bool {
+	return func() bool {
+		p := probeTLS(addr)
+		first, ok := p.firstExpiry()
+		return ok && time.Until(first) < d
+	}
+}
+
+// The result of a DNS probe.
+type dnsProbe struct {
+	Time    time.Time
+	Host    string
+	Latency time.Duration
+	Addrs   []string `json:",omitempty"`
+	// Why the lookup failed, if it did.
+	Error string `json:",omitempty"`
+}
+
+// Returns an action that resolves the host, and writes a line of JSON
+// with how long resolution took and the addresses it returned. Resolver
+// trouble is a common hidden cause of latency spikes; this puts it on
+// the same timeline as profiles. Failed lookups are recorded in an
+// "Error" field rather than failing the run.
+func ProbeDNS(host string) *BaseAction {
+	b := NewAction(func(w io.Writer) error {
+		ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+		defer cancel()
+
+		p := dnsProbe{Time: time.Now(), Host: host}
+		addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+		p.Latency = time.Since(p.Time)
+		p.Addrs = addrs
+		if err != nil {
+			p.Error = err.Error()
+		}
+
+		return json.NewEncoder(w).Encode(&p)
+	}).Name("dns")
+
+	b.ext = "json"
+	return b
+}
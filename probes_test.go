@@ -0,0 +1,63 @@
+package profile
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProbeTLSRecordsChain(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+	addr := strings.TrimPrefix(srv.URL, "https://")
+
+	buf := new(bytes.Buffer)
+	assert.Nil(t, ProbeTLS(addr).ToWriter(buf).Trigger(context.Background()))
+
+	var p tlsProbe
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &p))
+	assert.Empty(t, p.Error)
+	assert.NotZero(t, p.Handshake)
+	if assert.Len(t, p.Chain, 1) {
+		assert.Equal(t, srv.Certificate().NotAfter.Unix(), p.Chain[0].NotAfter.Unix())
+	}
+	// The test server's certificate isn't signed by a trusted root.
+	assert.NotEmpty(t, p.VerifyError)
+
+	notAfter := srv.Certificate().NotAfter
+	assert.True(t, TLSExpiresWithin(addr, time.Until(notAfter)+time.Hour)())
+	assert.False(t, TLSExpiresWithin(addr, time.Until(notAfter)-time.Hour)())
+}
+
+func TestProbeTLSRecordsFailures(t *testing.T) {
+	buf := new(bytes.Buffer)
+	assert.Nil(t, ProbeTLS("127.0.0.1:1").ToWriter(buf).Trigger(context.Background()))
+
+	var p tlsProbe
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &p))
+	assert.NotEmpty(t, p.Error)
+	assert.False(t, TLSExpiresWithin("127.0.0.1:1", time.Hour)())
+}
+
+func TestProbeDNS(t *testing.T) {
+	buf := new(bytes.Buffer)
+	assert.Nil(t, ProbeDNS("localhost").ToWriter(buf).Trigger(context.Background()))
+
+	var p dnsProbe
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &p))
+	assert.Equal(t, "localhost", p.Host)
+	assert.Empty(t, p.Error)
+	assert.NotEmpty(t, p.Addrs)
+	assert.NotZero(t, p.Latency)
+
+	buf.Reset()
+	assert.Nil(t, ProbeDNS("").ToWriter(buf).Trigger(context.Background()))
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &p))
+	assert.NotEmpty(t, p.Error)
+}
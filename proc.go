@@ -0,0 +1,119 @@
+package profile
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Returns a condition which holds when the resident set size of
+// the process exceeds the limit, in bytes. Unlike HeapAbove, this
+// includes memory held by the runtime but not yet returned to the
+// OS, which is what the kernel's OOM killer acts on. It never holds
+// on platforms where RSS can't be read.
+func RSSAbove(limit uint64) func() bool {
+	return func() bool {
+		rss, err := readRSS()
+		return err == nil && rss > limit
+	}
+}
+
+// Returns a condition which holds when the memory usage of the
+// process's cgroup exceeds the limit, in bytes. It never holds on
+// platforms without cgroups.
+func CgroupMemoryAbove(limit uint64) func() bool {
+	return func() bool {
+		usage, err := readCgroupMemory()
+		return err == nil && usage > limit
+	}
+}
+
+// Returns a condition which holds when the process's cgroup was
+// throttled for exceeding its CPU quota in more than the given number
+// of scheduling periods, usually 100ms each, since the condition was
+// last checked. Used with ProfileCPU, this captures profiles exactly
+// while Kubernetes is throttling the service. It doesn't hold the
+// first time it's checked, which sets the baseline, and never holds on
+// platforms without cgroups. It keeps its own baseline, so should
+// only be used by one action.
+func CgroupThrottled(periods uint64) func() bool {
+	var last uint64
+	var baseline bool
+	return func() bool {
+		throttled, err := readCgroupThrottled()
+		if err != nil {
+			return false
+		}
+
+		delta := throttled - last
+		held := baseline && throttled >= last && delta > periods
+		last, baseline = throttled, true
+		return held
+	}
+}
+
+// Returns a condition which holds when memory pressure exceeds either
+// threshold, so that profiles are captured while the process is
+// genuinely short of memory, rather than at an arbitrary heap size.
+// The thresholds are the percentage of the last ten seconds in which
+// some tasks, or all tasks, were stalled waiting for memory, as
+// reported by Linux's pressure stall information; pass 100 to ignore
+// one. The cgroup's pressure is used if it's available, and the
+// system's otherwise. It never holds on platforms without PSI.
+func MemoryPressureAbove(some, full float64) func() bool {
+	return func() bool {
+		s, f, err := readMemoryPressure()
+		return err == nil && (s > some || f > full)
+	}
+}
+
+// Returns a condition which holds when the one-minute system load
+// average exceeds the threshold, indicating host-wide contention.
+// It never holds on platforms where the load average can't be read.
+func LoadAverageAbove(threshold float64) func() bool {
+	return func() bool {
+		load, err := readLoadAverage()
+		return err == nil && load > threshold
+	}
+}
+
+// A process in the tree written by DumpProcessTree.
+type processInfo struct {
+	PID     int
+	PPID    int
+	Command string
+	// The state of the process, such as "R" for running or "S" for
+	// sleeping.
+	State string
+	// The resident set size, in bytes.
+	RSS uint64
+	// The CPU time used, in seconds.
+	CPU float64
+}
+
+// Returns an action that writes the process's descendants, such as
+// forked workers, as a line of JSON, so that resource use across the
+// tree is captured alongside the process's own. Each object has a
+// "Time" field, and a "Processes" field listing each descendant's
+// PID, parent, command, state, RSS in bytes, and CPU time in seconds.
+// Runs fail with ErrUnsupported on platforms other than Linux.
+func DumpProcessTree() *BaseAction {
+	b := NewAction(func(w io.Writer) error {
+		var out struct {
+			Time      time.Time
+			Processes []processInfo
+		}
+
+		out.Time = time.Now()
+		procs, err := readProcessTree()
+		if err != nil {
+			return err
+		}
+
+		out.Processes = procs
+		return json.NewEncoder(w).Encode(&out)
+	}).Name("proctree")
+
+	b.ext = "json"
+	return b
+}
@@ -0,0 +1,222 @@
+package profile
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// The path to the process's memory usage, in pages.
+var statmFile = "/proc/self/statm"
+
+// Paths to the memory usage of the current cgroup, for cgroups v2
+// and v1 respectively.
+var cgroupMemoryFiles = []string{
+	"/sys/fs/cgroup/memory.current",
+	"/sys/fs/cgroup/memory/memory.usage_in_bytes",
+}
+
+// Reads the resident set size of the process from procfs.
+func readRSS() (uint64, error) {
+	data, err := os.ReadFile(statmFile)
+	if err != nil {
+		return 0, err
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("%w of %s", ErrUnexpectedFormat, statmFile)
+	}
+
+	pages, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return pages * uint64(os.Getpagesize()), nil
+}
+
+// Reads the memory usage of the process's cgroup.
+func readCgroupMemory() (uint64, error) {
+	for _, file := range cgroupMemoryFiles {
+		data, err := os.ReadFile(file)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+
+		return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	}
+
+	return 0, fmt.Errorf("%w: cgroup memory usage", ErrUnavailable)
+}
+
+// Paths to the CPU statistics of the current cgroup, for cgroups v2
+// and v1 respectively.
+var cgroupCPUStatFiles = []string{
+	"/sys/fs/cgroup/cpu.stat",
+	"/sys/fs/cgroup/cpu/cpu.stat",
+}
+
+// Reads the number of periods in which the process's cgroup has been
+// throttled for exceeding its CPU quota.
+func readCgroupThrottled() (uint64, error) {
+	for _, file := range cgroupCPUStatFiles {
+		data, err := os.ReadFile(file)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 2 && fields[0] == "nr_throttled" {
+				return strconv.ParseUint(fields[1], 10, 64)
+			}
+		}
+
+		return 0, fmt.Errorf("%w of %s", ErrUnexpectedFormat, file)
+	}
+
+	return 0, fmt.Errorf("%w: cgroup CPU statistics", ErrUnavailable)
+}
+
+// Paths to the memory pressure stall information of the current
+// cgroup, and of the whole system.
+var memoryPressureFiles = []string{
+	"/sys/fs/cgroup/memory.pressure",
+	"/proc/pressure/memory",
+}
+
+// Reads the share of the last ten seconds, as a percentage, in which
+// some tasks, and all tasks, were stalled on memory.
+func readMemoryPressure() (some, full float64, err error) {
+	for _, file := range memoryPressureFiles {
+		data, err := os.ReadFile(file)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return 0, 0, err
+		}
+
+		return parsePressure(file, string(data))
+	}
+
+	return 0, 0, fmt.Errorf("%w: memory pressure", ErrUnavailable)
+}
+
+// Parses the avg10 values of a pressure file, which look like:
+//
+//	some avg10=1.53 avg60=0.87 avg300=0.22 total=10512
+//	full avg10=0.00 avg60=0.13 avg300=0.03 total=1280
+func parsePressure(file, data string) (some, full float64, err error) {
+	found := 0
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !strings.HasPrefix(fields[1], "avg10=") {
+			continue
+		}
+
+		avg, err := strconv.ParseFloat(strings.TrimPrefix(fields[1], "avg10="), 64)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		switch fields[0] {
+		case "some":
+			some, found = avg, found|1
+		case "full":
+			full, found = avg, found|2
+		}
+	}
+
+	if found != 3 {
+		return 0, 0, fmt.Errorf("%w of %s", ErrUnexpectedFormat, file)
+	}
+
+	return some, full, nil
+}
+
+// The path to the system's load averages.
+var loadAverageFile = "/proc/loadavg"
+
+// Reads the one-minute system load average from procfs.
+func readLoadAverage() (float64, error) {
+	data, err := os.ReadFile(loadAverageFile)
+	if err != nil {
+		return 0, err
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) < 1 {
+		return 0, fmt.Errorf("%w of %s", ErrUnexpectedFormat, loadAverageFile)
+	}
+
+	return strconv.ParseFloat(fields[0], 64)
+}
+
+// The kernel's clock ticks per second, in which CPU times are given.
+// It's 100 on every architecture Go supports.
+const clockTicks = 100
+
+// Reads the descendants of the process from procfs.
+func readProcessTree() ([]processInfo, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	children := make(map[int][]processInfo)
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+
+		// Processes may exit while we're looking.
+		if p, err := readProcessStat(pid); err == nil {
+			children[p.PPID] = append(children[p.PPID], p)
+		}
+	}
+
+	var tree []processInfo
+	queue := []int{os.Getpid()}
+	for len(queue) > 0 {
+		for _, p := range children[queue[0]] {
+			tree = append(tree, p)
+			queue = append(queue, p.PID)
+		}
+		queue = queue[1:]
+	}
+
+	return tree, nil
+}
+
+// Reads a process's status from /proc/<pid>/stat.
+func readProcessStat(pid int) (processInfo, error) {
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return processInfo{}, err
+	}
+
+	// The command is in parentheses, and may itself contain spaces
+	// and parentheses, so the fields after it are found from the end.
+	s := string(data)
+	open, end := strings.IndexByte(s, '('), strings.LastIndexByte(s, ')')
+	fields := strings.Fields(s[end+1:])
+	if open < 0 || end < open || len(fields) < 22 {
+		return processInfo{}, fmt.Errorf("%w of /proc/%d/stat", ErrUnexpectedFormat, pid)
+	}
+
+	p := processInfo{PID: pid, Command: s[open+1 : end], State: fields[0]}
+	p.PPID, _ = strconv.Atoi(fields[1])
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	p.CPU = float64(utime+stime) / clockTicks
+	rss, _ := strconv.ParseUint(fields[21], 10, 64)
+	p.RSS = rss * uint64(os.Getpagesize())
+	return p, nil
+}
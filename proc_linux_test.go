@@ -0,0 +1,170 @@
+package profile
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// Writes a fixture into a temporary directory, returning its path.
+func writeFixture(t *testing.T, name, data string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.Nil(t, os.WriteFile(path, []byte(data), 0644))
+	return path
+}
+
+func TestRSSAbove(t *testing.T) {
+	defer func(orig string) { statmFile = orig }(statmFile)
+	statmFile = writeFixture(t, "statm", "2048 300 150 1 0 500 0\n")
+	page := uint64(os.Getpagesize())
+
+	rss, err := readRSS()
+	assert.Nil(t, err)
+	assert.Equal(t, 300*page, rss)
+	assert.True(t, RSSAbove(299*page)())
+	assert.False(t, RSSAbove(300*page)())
+
+	statmFile = writeFixture(t, "statm", "2048\n")
+	_, err = readRSS()
+	assert.True(t, errors.Is(err, ErrUnexpectedFormat))
+	assert.False(t, RSSAbove(0)())
+
+	// The process's own RSS is above a low threshold.
+	statmFile = "/proc/self/statm"
+	assert.True(t, RSSAbove(1)())
+}
+
+func TestCgroupMemoryAbove(t *testing.T) {
+	defer func(orig []string) { cgroupMemoryFiles = orig }(cgroupMemoryFiles)
+	missing := filepath.Join(t.TempDir(), "missing")
+
+	// cgroups v2 are preferred.
+	v2 := writeFixture(t, "memory.current", "4096\n")
+	v1 := writeFixture(t, "memory.usage_in_bytes", "8192\n")
+	cgroupMemoryFiles = []string{v2, v1}
+	usage, err := readCgroupMemory()
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(4096), usage)
+
+	// cgroups v1 are used when v2 aren't mounted.
+	cgroupMemoryFiles = []string{missing, v1}
+	usage, err = readCgroupMemory()
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(8192), usage)
+	assert.True(t, CgroupMemoryAbove(8191)())
+	assert.False(t, CgroupMemoryAbove(8192)())
+
+	cgroupMemoryFiles = []string{missing}
+	_, err = readCgroupMemory()
+	assert.True(t, errors.Is(err, ErrUnavailable))
+	assert.False(t, CgroupMemoryAbove(0)())
+
+	cgroupMemoryFiles = []string{writeFixture(t, "memory.current", "max\n")}
+	_, err = readCgroupMemory()
+	assert.NotNil(t, err)
+}
+
+func TestCgroupThrottled(t *testing.T) {
+	defer func(orig []string) { cgroupCPUStatFiles = orig }(cgroupCPUStatFiles)
+	stat := func(throttled int) string {
+		return fmt.Sprintf("usage_usec 5000\nnr_periods 900\nnr_throttled %d\nthrottled_usec 1200\n", throttled)
+	}
+
+	cond := CgroupThrottled(5)
+	cgroupCPUStatFiles = []string{writeFixture(t, "cpu.stat", stat(100))}
+	assert.False(t, cond(), "the first check sets the baseline")
+
+	cgroupCPUStatFiles = []string{writeFixture(t, "cpu.stat", stat(105))}
+	assert.False(t, cond())
+	cgroupCPUStatFiles = []string{writeFixture(t, "cpu.stat", stat(111))}
+	assert.True(t, cond())
+	assert.False(t, cond())
+
+	// The counter resets if the cgroup is recreated.
+	cgroupCPUStatFiles = []string{writeFixture(t, "cpu.stat", stat(3))}
+	assert.False(t, cond())
+
+	cgroupCPUStatFiles = []string{writeFixture(t, "cpu.stat", "usage_usec 5000\n")}
+	_, err := readCgroupThrottled()
+	assert.True(t, errors.Is(err, ErrUnexpectedFormat))
+
+	cgroupCPUStatFiles = []string{filepath.Join(t.TempDir(), "missing")}
+	_, err = readCgroupThrottled()
+	assert.True(t, errors.Is(err, ErrUnavailable))
+}
+
+func TestMemoryPressureAbove(t *testing.T) {
+	defer func(orig []string) { memoryPressureFiles = orig }(memoryPressureFiles)
+	missing := filepath.Join(t.TempDir(), "missing")
+
+	cgroup := writeFixture(t, "memory.pressure",
+		"some avg10=12.50 avg60=3.10 avg300=0.80 total=120000\nfull avg10=2.25 avg60=0.40 avg300=0.10 total=9000\n")
+	system := writeFixture(t, "memory", "some avg10=0.00 avg60=0.00 avg300=0.00 total=0\nfull avg10=0.00 avg60=0.00 avg300=0.00 total=0\n")
+	memoryPressureFiles = []string{missing, cgroup, system}
+	some, full, err := readMemoryPressure()
+	assert.Nil(t, err)
+	assert.Equal(t, 12.5, some)
+	assert.Equal(t, 2.25, full)
+	assert.True(t, MemoryPressureAbove(10, 100)())
+	assert.True(t, MemoryPressureAbove(100, 2)())
+	assert.False(t, MemoryPressureAbove(12.5, 2.25)())
+
+	// Memory pressure always includes "full" pressure.
+	memoryPressureFiles = []string{writeFixture(t, "memory", "some avg10=0.00 avg60=0.00 avg300=0.00 total=0\n")}
+	_, _, err = readMemoryPressure()
+	assert.True(t, errors.Is(err, ErrUnexpectedFormat))
+
+	memoryPressureFiles = []string{missing}
+	_, _, err = readMemoryPressure()
+	assert.True(t, errors.Is(err, ErrUnavailable))
+	assert.False(t, MemoryPressureAbove(0, 0)())
+}
+
+func TestLoadAverageAbove(t *testing.T) {
+	defer func(orig string) { loadAverageFile = orig }(loadAverageFile)
+	loadAverageFile = writeFixture(t, "loadavg", "2.50 1.25 0.75 3/512 12345\n")
+
+	load, err := readLoadAverage()
+	assert.Nil(t, err)
+	assert.Equal(t, 2.5, load)
+	assert.True(t, LoadAverageAbove(2.4)())
+	assert.False(t, LoadAverageAbove(2.5)())
+
+	loadAverageFile = writeFixture(t, "loadavg", "")
+	_, err = readLoadAverage()
+	assert.True(t, errors.Is(err, ErrUnexpectedFormat))
+	assert.False(t, LoadAverageAbove(0)())
+}
+
+func TestDumpProcessTreeFindsChildren(t *testing.T) {
+	child := exec.Command("sleep", "10")
+	if err := child.Start(); err != nil {
+		t.Skip("cannot start child process:", err)
+	}
+	defer child.Wait()
+	defer child.Process.Kill()
+
+	buf := new(bytes.Buffer)
+	assert.Nil(t, DumpProcessTree().ToWriter(buf).Trigger(context.Background()))
+
+	var out struct{ Processes []processInfo }
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &out))
+	var found *processInfo
+	for i, p := range out.Processes {
+		if p.PID == child.Process.Pid {
+			found = &out.Processes[i]
+		}
+	}
+	if assert.NotNil(t, found) {
+		assert.Equal(t, "sleep", found.Command)
+		assert.NotZero(t, found.RSS)
+		assert.NotEmpty(t, found.State)
+	}
+}
@@ -0,0 +1,28 @@
+//go:build !linux
+// +build !linux
+
+package profile
+
+func readRSS() (uint64, error) {
+	return 0, ErrUnsupported
+}
+
+func readCgroupMemory() (uint64, error) {
+	return 0, ErrUnsupported
+}
+
+func readCgroupThrottled() (uint64, error) {
+	return 0, ErrUnsupported
+}
+
+func readMemoryPressure() (some, full float64, err error) {
+	return 0, 0, ErrUnsupported
+}
+
+func readLoadAverage() (float64, error) {
+	return 0, ErrUnsupported
+}
+
+func readProcessTree() ([]processInfo, error) {
+	return nil, ErrUnsupported
+}
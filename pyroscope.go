@@ -0,0 +1,130 @@
+package profile
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PyroscopeSink is a Sink which pushes pprof profiles to a Pyroscope
+// server's ingestion API, so that Swat can act as a minimal
+// continuous-profiling agent. Only binary pprof output, such as from
+// ProfileCPU or DumpPProfLookup with a debug of zero, can be pushed.
+// See PushToPyroscope for a ready-made pair of actions.
+type PyroscopeSink struct {
+	client   *http.Client
+	endpoint string
+	app      string
+	tags     map[string]string
+	token    string
+}
+
+// Creates a sink which pushes profiles to the server, like
+// "http://pyroscope:4040", under the application's name.
+func NewPyroscopeSink(server, app string) *PyroscopeSink {
+	return &PyroscopeSink{
+		client:   &http.Client{Timeout: 30 * time.Second},
+		endpoint: strings.TrimSuffix(server, "/") + "/ingest",
+		app:      app,
+		tags:     make(map[string]string),
+	}
+}
+
+// Tags the profiles, such as with "env=prod", so that they can be
+// filtered in Pyroscope.
+func (p *PyroscopeSink) Tag(key, value string) *PyroscopeSink {
+	p.tags[key] = value
+	return p
+}
+
+// Sets a token to authenticate with, sent as a bearer token.
+func (p *PyroscopeSink) AuthToken(token string) *PyroscopeSink {
+	p.token = token
+	return p
+}
+
+func (p *PyroscopeSink) String() string {
+	return "pyroscope " + p.endpoint + " as " + p.appName()
+}
+
+// Returns the application's name with its tags, as Pyroscope expects
+// it, like "app{env=prod,region=eu}".
+func (p *PyroscopeSink) appName() string {
+	if len(p.tags) == 0 {
+		return p.app
+	}
+
+	tags := make([]string, 0, len(p.tags))
+	for k, v := range p.tags {
+		tags = append(tags, k+"="+v)
+	}
+	sort.Strings(tags)
+
+	return p.app + "{" + strings.Join(tags, ",") + "}"
+}
+
+// Implements Sink.Open. The profile is pushed once the writer is
+// closed, and closing it returns any error from the server.
+func (p *PyroscopeSink) Open(info RunInfo) (io.WriteCloser, error) {
+	if info.Ext != "pb.gz" {
+		return nil, fmt.Errorf("pyroscope needs a pprof profile, not %q output", info.Ext)
+	}
+
+	return &bufferedWriter{info: info, flush: p.push}, nil
+}
+
+func (p *PyroscopeSink) push(info RunInfo, data []byte) error {
+	body := new(bytes.Buffer)
+	form := multipart.NewWriter(body)
+	part, err := form.CreateFormFile("profile", "profile.pprof")
+	if err != nil {
+		return err
+	}
+
+	part.Write(data)
+	if err := form.Close(); err != nil {
+		return err
+	}
+
+	// Profiles cover the run, such as the window of a CPU profile.
+	until := info.Start.Add(info.Duration)
+	q := url.Values{}
+	q.Set("name", p.appName())
+	q.Set("from", strconv.FormatInt(info.Start.Unix(), 10))
+	q.Set("until", strconv.FormatInt(until.Unix(), 10))
+	q.Set("format", "pprof")
+	q.Set("spyName", "gospy")
+
+	req, err := http.NewRequest("POST", p.endpoint+"?"+q.Encode(), body)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+
+	return upload(p.client, req)
+}
+
+// Returns a CPU profile and a heap profile which push to the sink
+// every interval, for continuous profiling with Pyroscope:
+//
+//	sink := profile.NewPyroscopeSink("http://pyroscope:4040", "checkout")
+//	swat, err := profile.Start(profile.PushToPyroscope(sink, 10*time.Second)...)
+//
+// The CPU is profiled continuously, in windows of the interval.
+func PushToPyroscope(sink *PyroscopeSink, interval time.Duration) []Action {
+	return []Action{
+		ProfileCPU(interval).Continuous().To(sink),
+		DumpPProfLookup("heap", 0).Every(interval).To(sink),
+	}
+}
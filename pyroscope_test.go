@@ -0,0 +1,33 @@
+package profile
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPyroscopeSinkPushesProfiles(t *testing.T) {
+	var query string
+	var profile []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("name") + " " + r.URL.Query().Get("format")
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		f, _, err := r.FormFile("profile")
+		if assert.Nil(t, err) {
+			profile, _ = io.ReadAll(f)
+		}
+	}))
+	defer srv.Close()
+
+	sink := NewPyroscopeSink(srv.URL, "checkout").Tag("region", "eu").Tag("env", "prod").AuthToken("secret")
+	assert.Equal(t, "pyroscope "+srv.URL+"/ingest as checkout{env=prod,region=eu}", sink.String())
+	assert.Nil(t, DumpPProfLookup("heap", 0).To(sink).Trigger(context.Background()))
+	assert.Equal(t, "checkout{env=prod,region=eu} pprof", query)
+	assert.Equal(t, []byte{0x1f, 0x8b}, profile[:2])
+
+	err := DumpHeap().To(sink).Trigger(context.Background())
+	assert.EqualError(t, err, `pyroscope needs a pprof profile, not "txt" output`)
+}
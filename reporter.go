@@ -0,0 +1,121 @@
+package profile
+
+import (
+	"fmt"
+	"io"
+	"runtime/debug"
+	"time"
+)
+
+// An ErrorEvent describes a failed run, for an ErrorReporter.
+type ErrorEvent struct {
+	// The name of the action.
+	Action string
+	// The action's triggers and output, as given by Describe, such as
+	// "heap every 5m0s → /var/dumps/heap.pprof".
+	Description string
+	// The start of the failed run.
+	Time time.Time
+	// The error the run failed with. Panics are reported with an error
+	// wrapping ErrPanicked.
+	Err error
+	// The stack of the goroutine which panicked, or nil if the run
+	// returned an error.
+	Stack []byte
+}
+
+// An ErrorReporter is told about every failed run of an action, and
+// every panic, whatever triggered the run, so that they can be tracked
+// with the rest of a program's errors. Panics are reported and then
+// passed on as usual.
+type ErrorReporter interface {
+	Report(e ErrorEvent)
+}
+
+// ErrorReporterFunc adapts a function to ErrorReporter.
+type ErrorReporterFunc func(e ErrorEvent)
+
+// Implements ErrorReporter.
+func (f ErrorReporterFunc) Report(e ErrorEvent) {
+	f(e)
+}
+
+// Returns an ErrorReporter which passes each error to capture, along
+// with its context as extras under "swat.action", "swat.description",
+// "swat.time", and, for panics, "swat.stack". This is the shape that
+// error trackers' clients take, so a reporter is one line away:
+//
+//	// Rollbar
+//	profile.CaptureErrors(func(err error, extras map[string]interface{}) {
+//	    rollbar.ErrorWithExtras(rollbar.ERR, err, extras)
+//	})
+//
+//	// Sentry
+//	profile.CaptureErrors(func(err error, extras map[string]interface{}) {
+//	    sentry.WithScope(func(scope *sentry.Scope) {
+//	        scope.SetExtras(extras)
+//	        sentry.CaptureException(err)
+//	    })
+//	})
+func CaptureErrors(capture func(err error, extras map[string]interface{})) ErrorReporter {
+	return ErrorReporterFunc(func(e ErrorEvent) {
+		extras := map[string]interface{}{
+			"swat.action":      e.Action,
+			"swat.description": e.Description,
+			"swat.time":        e.Time,
+		}
+		if e.Stack != nil {
+			extras["swat.stack"] = string(e.Stack)
+		}
+
+		capture(e.Err, extras)
+	})
+}
+
+// `ReportErrors` reports every failed run of the action, and every
+// panic, to the reporter. It takes precedence over a reporter given
+// to the Swat.
+func (b *BaseAction) ReportErrors(r ErrorReporter) *BaseAction {
+	b.reporter = r
+	return b
+}
+
+func (b *BaseAction) useReporter(r ErrorReporter) {
+	if b.reporter == nil {
+		b.reporter = r
+	}
+}
+
+// Actions which can share a Swat's error reporter implement this.
+type reporting interface {
+	useReporter(r ErrorReporter)
+}
+
+// Reports every failed run and panic of the Swat's actions, unless
+// they have their own reporter. It must be called before the actions
+// are booted.
+func (s *Swat) ReportErrors(r ErrorReporter) *Swat {
+	s.reporter = r
+	return s
+}
+
+// Calls the action's function, reporting any panic before passing it
+// on.
+func (r *runner) call(w io.Writer) error {
+	if r.reporter != nil {
+		defer func() {
+			if v := recover(); v != nil {
+				r.reporter.Report(ErrorEvent{
+					Action:      r.name,
+					Description: r.description,
+					Time:        time.Now(),
+					Err:         fmt.Errorf("%w: %v", ErrPanicked, v),
+					Stack:       debug.Stack(),
+				})
+				panic(v)
+			}
+		}()
+	}
+
+	return r.fn(w)
+}
@@ -0,0 +1,41 @@
+package profile
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestReportErrorsOnFailure(t *testing.T) {
+	var events []ErrorEvent
+	s := new(Swat).ReportErrors(ErrorReporterFunc(func(e ErrorEvent) { events = append(events, e) }))
+	a := NewAction(func(io.Writer) error { return errors.New("broken") }).
+		Name("heap").Every(time.Hour).After(time.Hour).OnError(func(error) {})
+	assert.Nil(t, s.Boot([]Action{a}))
+	defer s.End()
+
+	assert.NotNil(t, a.Trigger(context.Background()))
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "heap", events[0].Action)
+		assert.Equal(t, "heap after 1h0m0s every 1h0m0s → nowhere", events[0].Description)
+		assert.EqualError(t, events[0].Err, "broken")
+		assert.Nil(t, events[0].Stack)
+	}
+}
+
+func TestReportErrorsOnPanic(t *testing.T) {
+	var extras map[string]interface{}
+	var captured error
+	a := NewAction(func(io.Writer) error { panic("boom") }).Name("heap").
+		ReportErrors(CaptureErrors(func(err error, e map[string]interface{}) { captured, extras = err, e }))
+	assert.Nil(t, a.Start())
+	defer a.End()
+
+	assert.PanicsWithValue(t, "boom", func() { a.Trigger(context.Background()) })
+	assert.True(t, errors.Is(captured, ErrPanicked))
+	assert.Equal(t, "heap", extras["swat.action"])
+	assert.Contains(t, extras["swat.stack"], "reporter_test.go")
+}
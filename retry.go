@@ -0,0 +1,134 @@
+package profile
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+)
+
+// RetrySink wraps a network sink, such as a KafkaSink, retrying
+// failed deliveries with exponential backoff, so that transient
+// outages don't lose profiles. Dumps are buffered in memory so they
+// can be sent again. Retries hold up the action's next run, so use
+// Async if outages may be long.
+type RetrySink struct {
+	sink     Sink
+	attempts int
+	backoff  time.Duration
+	spill    string
+	sleep    func(time.Duration)
+	err      error
+}
+
+// Wraps the sink so that each dump is tried up to `attempts` times,
+// waiting `backoff` after the first failure and doubling the wait
+// after each one after that. At least one attempt is required; an
+// action written to a sink with fewer fails to start.
+func Retry(sink Sink, attempts int, backoff time.Duration) *RetrySink {
+	r := &RetrySink{sink: sink, attempts: attempts, backoff: backoff, sleep: time.Sleep}
+	if attempts < 1 {
+		r.err = fmt.Errorf("%w: 'Retry' requires at least one attempt", ErrInvalidOption)
+	}
+
+	return r
+}
+
+// Writes dumps which still fail after every attempt to a file in the
+// directory, named as by ToDir, rather than dropping them. The error
+// is still reported, noting the file.
+func (r *RetrySink) SpillTo(dir string) *RetrySink {
+	r.spill = dir
+	return r
+}
+
+// Implements invalidSink.
+func (r *RetrySink) invalid() error {
+	return r.err
+}
+
+// Implements checkedSink.
+func (r *RetrySink) check() error {
+	return checkSink(r.sink)
+}
+
+func (r *RetrySink) String() string {
+	return fmt.Sprintf("%s (%d attempts)", describeSink(r.sink), r.attempts)
+}
+
+// Implements Sink.Open.
+func (r *RetrySink) Open(info RunInfo) (io.WriteCloser, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	return &bufferedWriter{info: info, flush: r.deliver}, nil
+}
+
+func (r *RetrySink) deliver(info RunInfo, data []byte) error {
+	var err error
+	wait := r.backoff
+	for i := 0; i < r.attempts; i++ {
+		if i > 0 {
+			r.sleep(wait)
+			wait *= 2
+		}
+
+		if err = writeSink(r.sink, info, data); err == nil {
+			return nil
+		}
+	}
+
+	if r.spill == "" {
+		return err
+	}
+
+	path, serr := spill(r.spill, info, data)
+	if serr != nil {
+		return fmt.Errorf("%w (and spilling failed: %s)", err, serr)
+	}
+
+	return fmt.Errorf("%w (spilled to %s)", err, path)
+}
+
+// Writes a whole dump to the sink.
+func writeSink(s Sink, info RunInfo, data []byte) error {
+	w, err := s.Open(info)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// Writes a dump to a new file in the directory, returning its path.
+// As with ToDir, a numeric suffix is added rather than overwriting
+// another dump with the same name and time.
+func spill(dir string, info RunInfo, data []byte) (string, error) {
+	vars := newPathVars(info.Name, info.Ext, info.Start)
+	f, err := createUnique(filepath.Join(dir, vars.Name+"-"+vars.Time+"."+vars.Ext), vars.Ext)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	return f.Name(), err
+}
+
+// Describes the sink, as in Describe.
+func describeSink(s Sink) string {
+	if d, ok := s.(fmt.Stringer); ok {
+		return d.String()
+	}
+
+	return fmt.Sprintf("%T", s)
+}
@@ -0,0 +1,108 @@
+package profile
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetrySinkBacksOff(t *testing.T) {
+	p := &fakeProducer{err: errors.New("broker down")}
+	var waits []time.Duration
+	sink := Retry(NewKafkaSink(p, "dumps"), 3, time.Second)
+	sink.sleep = func(d time.Duration) { waits = append(waits, d) }
+
+	err := newOutputAction("heap", "dump").To(sink).Trigger(context.Background())
+	assert.True(t, errors.Is(err, p.err))
+	assert.Len(t, p.msgs, 3)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, waits)
+
+	// Recovering on a retry delivers the dump.
+	p.err, p.msgs, waits = nil, nil, nil
+	assert.Nil(t, newOutputAction("heap", "dump").To(sink).Trigger(context.Background()))
+	assert.Len(t, p.msgs, 1)
+	assert.Empty(t, waits)
+}
+
+func TestRetrySinkSpills(t *testing.T) {
+	dir := t.TempDir()
+	p := &fakeProducer{err: errors.New("broker down")}
+	sink := Retry(NewKafkaSink(p, "dumps"), 1, time.Second).SpillTo(dir)
+
+	err := newOutputAction("heap", "dump").To(sink).Trigger(context.Background())
+	assert.True(t, errors.Is(err, p.err))
+	assert.Contains(t, err.Error(), "spilled to "+dir)
+
+	files, _ := os.ReadDir(dir)
+	if assert.Len(t, files, 1) {
+		data, _ := os.ReadFile(dir + "/" + files[0].Name())
+		assert.Equal(t, "dump", string(data))
+	}
+}
+
+func TestToWithFallback(t *testing.T) {
+	dir := t.TempDir()
+	p := &fakeProducer{err: errors.New("broker down")}
+	a := newOutputAction("heap", "dump").ToWithFallback(NewKafkaSink(p, "dumps"), DirSink(dir))
+	assert.Equal(t, "heap → kafka topic dumps (falling back to "+dir+")", a.Describe())
+
+	err := a.Trigger(context.Background())
+	assert.True(t, errors.Is(err, p.err))
+	files, _ := os.ReadDir(dir)
+	assert.Len(t, files, 1)
+
+	p.err = nil
+	assert.Nil(t, a.Trigger(context.Background()))
+	files, _ = os.ReadDir(dir)
+	assert.Len(t, files, 1)
+}
+
+func TestSpillDoesNotOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	info := RunInfo{Name: "heap", Ext: "pb.gz", Start: time.Now()}
+	first, err := spill(dir, info, []byte("first"))
+	assert.Nil(t, err)
+	second, err := spill(dir, info, []byte("second"))
+	assert.Nil(t, err)
+
+	assert.NotEqual(t, first, second)
+	assert.True(t, strings.HasSuffix(second, "-1.pb.gz"), second)
+	data, _ := os.ReadFile(first)
+	assert.Equal(t, "first", string(data))
+	data, _ = os.ReadFile(second)
+	assert.Equal(t, "second", string(data))
+}
+
+func TestFallbackDoesNotCountTowardStopAfterFailures(t *testing.T) {
+	p := &fakeProducer{err: errors.New("broker down")}
+	var errs int32
+	a := newOutputAction("heap", "dump").Every(time.Millisecond).StopAfterFailures(2).
+		ToWithFallback(NewKafkaSink(p, "dumps"), WriterSink(io.Discard)).
+		OnError(func(err error) {
+			assert.False(t, errors.Is(err, ErrTooManyFailures))
+			atomic.AddInt32(&errs, 1)
+		})
+	assert.Nil(t, a.Start())
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&errs) >= 5 }, 5*time.Second, time.Millisecond)
+	a.End()
+}
+
+func TestRetrySinkRequiresAnAttempt(t *testing.T) {
+	p := &fakeProducer{}
+	for _, attempts := range []int{0, -1} {
+		sink := Retry(NewKafkaSink(p, "dumps"), attempts, time.Second)
+		err := newOutputAction("heap", "dump").To(sink).Start()
+		assert.True(t, errors.Is(err, ErrInvalidOption))
+
+		_, err = sink.Open(RunInfo{})
+		assert.True(t, errors.Is(err, ErrInvalidOption))
+	}
+
+	assert.Empty(t, p.msgs)
+}
@@ -0,0 +1,148 @@
+package profile
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// A Dump is the output of a single run, kept in memory by a Ring.
+type Dump struct {
+	RunInfo
+	Data []byte
+}
+
+// Ring is a Sink which keeps the most recent dumps in memory, for
+// read-only filesystems and "show me the last goroutine dump" UIs.
+// It may be shared between several actions. Ring is also an
+// http.Handler; see ServeHTTP.
+type Ring struct {
+	mu       sync.Mutex
+	max      int
+	maxBytes int64
+	size     int64
+	// dumps are kept oldest first.
+	dumps []Dump
+}
+
+// Creates a ring which retains at most `n` dumps, totalling at most
+// `maxBytes`. The oldest dumps are evicted to make room for new ones.
+func NewRing(n int, maxBytes int64) *Ring {
+	return &Ring{max: n, maxBytes: maxBytes}
+}
+
+func (r *Ring) String() string {
+	return fmt.Sprintf("ring of %d dumps", r.max)
+}
+
+// Implements Sink.Open. The dump is added to the ring once the
+// writer is closed. Dumps larger than the ring's byte limit aren't
+// kept, and closing their writer returns an error wrapping
+// ErrTooLarge.
+func (r *Ring) Open(info RunInfo) (io.WriteCloser, error) {
+	return &bufferedWriter{info: info, flush: r.add}, nil
+}
+
+// Returns the retained dumps, oldest first.
+func (r *Ring) Dumps() []Dump {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Dump(nil), r.dumps...)
+}
+
+// Returns the most recent dump from the action with the given name.
+func (r *Ring) Latest(name string) (Dump, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := len(r.dumps) - 1; i >= 0; i-- {
+		if r.dumps[i].Name == name {
+			return r.dumps[i], true
+		}
+	}
+
+	return Dump{}, false
+}
+
+func (r *Ring) add(info RunInfo, data []byte) error {
+	d := Dump{RunInfo: info, Data: data}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := int64(len(d.Data))
+	if n > r.maxBytes {
+		return fmt.Errorf("%w: %d bytes won't fit in a ring of %d", ErrTooLarge, n, r.maxBytes)
+	}
+
+	r.dumps = append(r.dumps, d)
+	r.size += n
+	for len(r.dumps) > r.max || r.size > r.maxBytes {
+		r.size -= int64(len(r.dumps[0].Data))
+		r.dumps[0] = Dump{}
+		r.dumps = r.dumps[1:]
+	}
+
+	return nil
+}
+
+// The listing of the dumps served by ServeHTTP.
+type ringListing struct {
+	// The version of the listing's format; see SchemaVersion.
+	SchemaVersion int           `json:"schema_version"`
+	Dumps         []dumpListing `json:"dumps"`
+}
+
+// The metadata of a dump, as listed by ServeHTTP.
+type dumpListing struct {
+	Name  string    `json:"name"`
+	Ext   string    `json:"ext"`
+	Start time.Time `json:"start"`
+	Bytes int64     `json:"bytes"`
+}
+
+// ServeHTTP serves the latest dump from the action named by the
+// "name" query parameter, such as "?name=goroutine". Without one, it
+// lists the retained dumps as JSON, oldest first, under "dumps".
+func (r *Ring) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	name := req.URL.Query().Get("name")
+	if name == "" {
+		dumps := r.Dumps()
+		list := ringListing{SchemaVersion: SchemaVersion, Dumps: make([]dumpListing, len(dumps))}
+		for i, d := range dumps {
+			list.Dumps[i] = dumpListing{d.Name, d.Ext, d.Start, d.Bytes}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&list)
+		return
+	}
+
+	d, ok := r.Latest(name)
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+
+	file := newPathVars(d.Name, d.Ext, d.Start)
+	w.Header().Set("Content-Type", contentType(d.Ext))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.%s"`, file.Name, file.Time, file.Ext))
+	w.Write(d.Data)
+}
+
+// Returns the MIME type of output with the given extension.
+func contentType(ext string) string {
+	switch ext {
+	case "json":
+		return "application/json"
+	case "txt":
+		return "text/plain; charset=utf-8"
+	case "zip":
+		return "application/zip"
+	default:
+		return "application/octet-stream"
+	}
+}
@@ -0,0 +1,67 @@
+package profile
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newOutputAction(name, output string) *BaseAction {
+	return NewAction(func(w io.Writer) error {
+		_, err := io.WriteString(w, output)
+		return err
+	}).Name(name)
+}
+
+func TestRingEvictsOldestDumps(t *testing.T) {
+	ring := NewRing(2, 10)
+	for _, out := range []string{"one", "two", "three"} {
+		assert.Nil(t, newOutputAction("a", out).To(ring).Trigger(context.Background()))
+	}
+
+	dumps := ring.Dumps()
+	assert.Len(t, dumps, 2)
+	assert.Equal(t, "two", string(dumps[0].Data))
+	assert.Equal(t, "three", string(dumps[1].Data))
+	assert.Equal(t, int64(5), dumps[1].Bytes)
+
+	// Evicting by size keeps the total under the limit.
+	assert.Nil(t, newOutputAction("a", "seven!!").To(ring).Trigger(context.Background()))
+	dumps = ring.Dumps()
+	assert.Len(t, dumps, 1)
+	assert.Equal(t, "seven!!", string(dumps[0].Data))
+
+	err := newOutputAction("a", "far too large").To(ring).Trigger(context.Background())
+	assert.True(t, errors.Is(err, ErrTooLarge))
+}
+
+func TestRingServesLatestDump(t *testing.T) {
+	ring := NewRing(10, 1024)
+	assert.Nil(t, newOutputAction("goroutine", "old").To(ring).Trigger(context.Background()))
+	assert.Nil(t, newOutputAction("goroutine", "new").To(ring).Trigger(context.Background()))
+	assert.Nil(t, newOutputAction("heap", "heap").To(ring).Trigger(context.Background()))
+
+	rec := httptest.NewRecorder()
+	ring.ServeHTTP(rec, httptest.NewRequest("GET", "/?name=goroutine", nil))
+	assert.Equal(t, "new", rec.Body.String())
+	assert.True(t, strings.HasPrefix(rec.Header().Get("Content-Disposition"), `attachment; filename="goroutine-`))
+
+	rec = httptest.NewRecorder()
+	ring.ServeHTTP(rec, httptest.NewRequest("GET", "/?name=missing", nil))
+	assert.Equal(t, 404, rec.Code)
+
+	rec = httptest.NewRecorder()
+	ring.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	assert.Contains(t, rec.Body.String(), `"schema_version":1`)
+	var listing ringListing
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &listing))
+	assert.Equal(t, SchemaVersion, listing.SchemaVersion)
+	list := listing.Dumps
+	assert.Len(t, list, 3)
+	assert.Equal(t, "heap", list[2].Name)
+}
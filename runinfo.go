@@ -0,0 +1,74 @@
+package profile
+
+import (
+	"io"
+	"time"
+)
+
+// RunInfo describes a single run of an action, whether it succeeded
+// or failed. For a failed run, Bytes and Path describe whatever output
+// was written before the failure, and may be empty.
+type RunInfo struct {
+	// The name of the action, or "action" if it has none.
+	Name string
+	// The extension of the action's output, such as "pb.gz".
+	Ext string
+	// The number of the run, counting from 1 across every start of
+	// the action. Skipped runs aren't counted.
+	Index uint64
+	// Why the run happened.
+	Source TriggerSource
+	// When the run started.
+	Start time.Time
+	// How long the run took, including writing its output.
+	Duration time.Duration
+	// The number of bytes of output written.
+	Bytes int64
+	// The path of the file the output was written to, if any.
+	Path string
+}
+
+// A TriggerSource says why a run happened, so that dumps and
+// notifications can explain themselves.
+type TriggerSource string
+
+const (
+	// The run was scheduled, as with Every.
+	SourceSchedule TriggerSource = "schedule"
+	// The process received one of the signals given to OnSignal, or
+	// a command given to OnPipeCommand.
+	SourceSignal TriggerSource = "signal"
+	// A condition held, or an event happened, as with When, HeapAbove
+	// or AfterRunOf.
+	SourceThreshold TriggerSource = "threshold"
+	// The run was triggered with Trigger, or from the console served
+	// by ServeConsole.
+	SourceManual TriggerSource = "manual"
+)
+
+// Triggers which aren't conditions or events implement this, to say
+// which source their runs have.
+type sourced interface {
+	source() TriggerSource
+}
+
+// Returns the source of the runs of the trigger.
+func sourceOf(t trigger) TriggerSource {
+	if s, ok := t.(sourced); ok {
+		return s.source()
+	}
+
+	return SourceThreshold
+}
+
+// Wraps a writer, counting the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,333 @@
+package profile
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A runner holds the configuration an action was started with, and
+// runs its function on behalf of its triggers.
+type runner struct {
+	fn       func(io.Writer) error
+	name     string
+	ext      string
+	target   *targeter
+	skipBusy bool
+	// burst is how many runs each firing of a trigger makes, gap
+	// apart.
+	burst    int
+	burstGap time.Duration
+	limiter  *rateLimiter
+	pipeline *asyncWriter
+	// skipped, runs and stats point at the action's, which outlive
+	// runners.
+	skipped *uint64
+	runs    *uint64
+	stats   *runStats
+	state   *stateFile
+	statsd  *StatsD
+	etw     *ETW
+	// manifest, if set, lists the runs' artifacts.
+	manifest *manifestFile
+	// clock stamps the start of each run.
+	clock Clock
+	// reporter, if set, is told about failures, and alert about
+	// conditions firing. Description describes the action for them.
+	reporter    ErrorReporter
+	alert       *alertWebhook
+	description string
+	// leader, if set, must be held for any run but a manual one to go
+	// ahead.
+	leader *leaderLock
+	// ctx is passed to context-aware functions, and is cancelled by
+	// cancel once the action ends.
+	ctx    context.Context
+	cancel context.CancelFunc
+	// serial is a semaphore held while the function runs, so that
+	// runs never overlap.
+	serial chan struct{}
+
+	onError     func(error)
+	onSuccess   func(RunInfo)
+	maxFailures int32
+	failures    int32
+	disabled    int32
+}
+
+// Returns the function a trigger runs, which runs the action's function
+// once, for the source, unless the run is rate limited or skipped
+// because the previous one is still in progress.
+func (r *runner) runFor(source TriggerSource) func() {
+	return func() { r.runWith(source, nil) }
+}
+
+// Runs the action's function as the function runFor returns does,
+// calling before, if it's set, once the run is sure to go ahead.
+func (r *runner) runWith(source TriggerSource, before func()) {
+	if atomic.LoadInt32(&r.disabled) == 1 {
+		return
+	}
+
+	if r.leader != nil {
+		held, err := r.leader.acquire()
+		if err != nil {
+			r.notify(err)
+		}
+
+		if !held {
+			atomic.AddUint64(r.skipped, 1)
+			return
+		}
+	}
+
+	if r.limiter != nil && !r.limiter.allow() {
+		atomic.AddUint64(r.skipped, 1)
+		return
+	}
+
+	if !r.skipBusy {
+		r.serial <- struct{}{}
+	} else {
+		select {
+		case r.serial <- struct{}{}:
+		default:
+			atomic.AddUint64(r.skipped, 1)
+			return
+		}
+	}
+	defer func() { <-r.serial }()
+
+	if before != nil {
+		before()
+	}
+
+	// Async writes are reported by the pipeline once they complete.
+	if info, err := r.write(source); err != nil || r.pipeline == nil {
+		r.report(info, err)
+	}
+}
+
+// Reports the result of a run. Successes are passed to the OnSuccess
+// callback, and errors to the OnError callback, or logged if there
+// is none. After too many consecutive failures, the runner is
+// disabled.
+func (r *runner) report(info RunInfo, err error) {
+	r.record(info, err)
+	if err == nil {
+		atomic.StoreInt32(&r.failures, 0)
+		if r.onSuccess != nil {
+			r.onSuccess(info)
+		}
+
+		return
+	}
+
+	r.notify(err)
+
+	// Dumps written to a fallback aren't lost, so they break a streak.
+	var fell *fallbackError
+	if errors.As(err, &fell) {
+		atomic.StoreInt32(&r.failures, 0)
+		return
+	}
+
+	if r.maxFailures > 0 && atomic.AddInt32(&r.failures, 1) == r.maxFailures {
+		atomic.StoreInt32(&r.disabled, 1)
+		r.notify(fmt.Errorf("%w: %s failed %d times in a row", ErrTooManyFailures, r.name, r.maxFailures))
+	}
+}
+
+// Records the outcome of a run, whether it was triggered manually
+// or not.
+func (r *runner) record(info RunInfo, err error) {
+	r.stats.record(info, err)
+	if err != nil && r.reporter != nil {
+		r.reporter.Report(ErrorEvent{Action: r.name, Description: r.description, Time: info.Start, Err: err})
+	}
+
+	if r.statsd != nil {
+		r.statsd.emit(info, err)
+	}
+
+	if r.etw != nil {
+		r.etw.stopped(info, err)
+	}
+
+	if err == nil && r.manifest != nil {
+		r.manifest.add(info)
+	}
+
+	if r.state != nil {
+		if serr := r.state.recordRun(info.Start); serr != nil {
+			log.Printf("Swat Error: %s", serr)
+		}
+	}
+}
+
+func (r *runner) notify(err error) {
+	if r.onError != nil {
+		r.onError(err)
+	} else {
+		log.Printf("Swat Error: %s", err)
+	}
+}
+
+// Runs the action's function once on demand, waiting for any run
+// in progress to finish first, and returns its error. Manual runs
+// aren't rate limited.
+func (r *runner) trigger(ctx context.Context) error {
+	select {
+	case r.serial <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-r.serial }()
+
+	info, err := r.write(SourceManual)
+	if err != nil || r.pipeline == nil {
+		r.record(info, err)
+	}
+	if err == nil && r.pipeline == nil && r.onSuccess != nil {
+		r.onSuccess(info)
+	}
+
+	return err
+}
+
+// Writes the output of the function, either directly to the target
+// or by capturing it and handing it to the async pipeline.
+func (r *runner) write(source TriggerSource) (RunInfo, error) {
+	info := RunInfo{
+		Name:   r.name,
+		Ext:    r.ext,
+		Index:  atomic.AddUint64(r.runs, 1),
+		Source: source,
+		Start:  r.clock.Now(),
+	}
+	began := time.Now()
+	if r.etw != nil {
+		r.etw.started(info)
+	}
+
+	// Formatting the time allocates, so it's only done for templates.
+	var vars pathVars
+	if r.target.template != nil {
+		vars = runPathVars(info)
+	}
+
+	if r.pipeline == nil {
+		err := r.target.write(&info, vars, r.call)
+		info.Duration = time.Since(began)
+		return info, err
+	}
+
+	buf := getBuffer()
+	if err := r.call(buf); err != nil {
+		putBuffer(buf)
+		return info, err
+	}
+
+	if !r.pipeline.enqueue(pendingWrite{info, vars, buf, began}) {
+		putBuffer(buf)
+		atomic.AddUint64(r.skipped, 1)
+	}
+
+	return info, nil
+}
+
+// Ends the runner, waiting for any pending async writes, and hands
+// over leadership to another process.
+func (r *runner) end() {
+	r.cancel()
+	if r.pipeline != nil {
+		r.pipeline.close()
+	}
+
+	if r.leader != nil {
+		r.leader.release()
+	}
+}
+
+// A dump which has been captured and is waiting to be written.
+type pendingWrite struct {
+	info  RunInfo
+	vars  pathVars
+	buf   *bytes.Buffer
+	began time.Time
+}
+
+// AsyncWriter writes captured dumps to a target in the background,
+// so that triggers only spend the time needed to collect them.
+type asyncWriter struct {
+	target *targeter
+	report func(RunInfo, error)
+	queue  chan pendingWrite
+	done   chan struct{}
+	// closed is set, under mu, once the queue is closed, since manual
+	// runs may still be finishing as the action ends.
+	mu     sync.Mutex
+	closed bool
+}
+
+func newAsyncWriter(target *targeter, size int, report func(RunInfo, error)) *asyncWriter {
+	a := &asyncWriter{
+		target: target,
+		report: report,
+		queue:  make(chan pendingWrite, size),
+		done:   make(chan struct{}),
+	}
+
+	go a.run()
+	return a
+}
+
+// Queues the buffer to be written, returning false if the queue
+// is full. The buffer is returned to the pool once written.
+func (a *asyncWriter) enqueue(p pendingWrite) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		return false
+	}
+
+	select {
+	case a.queue <- p:
+		return true
+	default:
+		return false
+	}
+}
+
+// Writes out anything still queued, then stops the writer.
+func (a *asyncWriter) close() {
+	a.mu.Lock()
+	a.closed = true
+	close(a.queue)
+	a.mu.Unlock()
+
+	<-a.done
+}
+
+func (a *asyncWriter) run() {
+	defer close(a.done)
+
+	for p := range a.queue {
+		err := a.target.write(&p.info, p.vars, func(w io.Writer) error {
+			_, err := p.buf.WriteTo(w)
+			return err
+		})
+
+		p.info.Duration = time.Since(p.began)
+		a.report(p.info, err)
+
+		putBuffer(p.buf)
+	}
+}
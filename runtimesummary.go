@@ -0,0 +1,82 @@
+package profile
+
+import (
+	"io"
+	"runtime/metrics"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The metrics in a runtime summary, and the keys they're written with.
+var runtimeSummaryMetrics = []struct{ key, name string }{
+	{"goroutines", "/sched/goroutines:goroutines"},
+	{"gomaxprocs", "/sched/gomaxprocs:threads"},
+	{"heap_bytes", "/memory/classes/heap/objects:bytes"},
+	{"heap_objects", "/gc/heap/objects:objects"},
+	{"heap_goal", "/gc/heap/goal:bytes"},
+	{"total_bytes", "/memory/classes/total:bytes"},
+	{"gc_cycles", "/gc/cycles/total:gc-cycles"},
+}
+
+// Writes runtime summaries. Its samples and buffer are reused between
+// runs, so that a summary taken every second doesn't allocate.
+type runtimeSummary struct {
+	mu      sync.Mutex
+	samples []metrics.Sample
+	buf     []byte
+}
+
+func newRuntimeSummary() *runtimeSummary {
+	s := &runtimeSummary{
+		samples: make([]metrics.Sample, len(runtimeSummaryMetrics)),
+		buf:     make([]byte, 0, 256),
+	}
+
+	for i, m := range runtimeSummaryMetrics {
+		s.samples[i].Name = m.name
+	}
+
+	return s
+}
+
+func (s *runtimeSummary) write(w io.Writer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	metrics.Read(s.samples)
+
+	b := append(s.buf[:0], "time="...)
+	b = time.Now().AppendFormat(b, time.RFC3339Nano)
+	for i, m := range runtimeSummaryMetrics {
+		b = append(b, ' ')
+		b = append(b, m.key...)
+		b = append(b, '=')
+		if s.samples[i].Value.Kind() == metrics.KindUint64 {
+			b = strconv.AppendUint(b, s.samples[i].Value.Uint64(), 10)
+		} else {
+			b = append(b, '-')
+		}
+	}
+	b = append(b, '\n')
+
+	s.buf = b
+	_, err := w.Write(b)
+	return err
+}
+
+// Returns an action that writes a one-line summary of the runtime,
+// such as the number of goroutines and the size of the heap, in
+// logfmt:
+//
+//	time=2024-05-01T12:00:00Z goroutines=12 gomaxprocs=8 heap_bytes=4194304 ...
+//
+// It reads runtime/metrics, which doesn't stop the world, and doesn't
+// allocate, so it's cheap enough to run every second without showing
+// up in the profiles other actions collect. Metrics the runtime
+// doesn't support are written as "-".
+func DumpRuntimeSummary() *BaseAction {
+	b := NewAction(newRuntimeSummary().write).Name("runtime")
+	b.ext = "txt"
+	return b
+}
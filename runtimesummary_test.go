@@ -0,0 +1,57 @@
+package profile
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDumpRuntimeSummary(t *testing.T) {
+	buf := new(bytes.Buffer)
+	assert.Nil(t, DumpRuntimeSummary().ToWriter(buf).Trigger(context.Background()))
+
+	line := buf.String()
+	assert.True(t, strings.HasPrefix(line, "time="))
+	assert.True(t, strings.HasSuffix(line, "\n"))
+	assert.Contains(t, line, " goroutines=")
+	assert.NotContains(t, line, "=-")
+}
+
+func TestRuntimeSummaryDoesNotAllocate(t *testing.T) {
+	s := newRuntimeSummary()
+	s.write(io.Discard)
+	assert.Zero(t, testing.AllocsPerRun(100, func() { s.write(io.Discard) }))
+}
+
+func TestRunsToWritersDoNotAllocate(t *testing.T) {
+	a := DumpRuntimeSummary().ToWriter(io.Discard)
+	assert.Nil(t, a.Start())
+	defer a.End()
+
+	run := a.runner.runFor(SourceSchedule)
+	run()
+	assert.Zero(t, testing.AllocsPerRun(100, run))
+}
+
+func BenchmarkRuntimeSummary(b *testing.B) {
+	s := newRuntimeSummary()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		s.write(io.Discard)
+	}
+}
+
+func BenchmarkRunnerRun(b *testing.B) {
+	a := DumpRuntimeSummary().ToWriter(io.Discard)
+	a.Start()
+	defer a.End()
+
+	run := a.runner.runFor(SourceSchedule)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		run()
+	}
+}
@@ -1,7 +1,14 @@
 package profile
 
 import (
-	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -11,27 +18,47 @@ import (
 //
 // Usage of this is a bit complex, but *should* be reasonably
 // natural to use.
-//  - `at` start something at a given time
-//  - `after` starts something at the given duration after the
-//    current time.
-//  - Omitting both `at` and `after` sets the start time at the
-//    current time.
-//  - `every` runs something at an interval after its start time
-//  - Omitting `every` runs something just once.
-//  - `for` specifies how long "every" runs
-//  - `until` specifies a time for "every" to stop running at
+//   - `at` start something at a given time
+//   - `after` starts something at the given duration after the
+//     current time.
+//   - Omitting both `at` and `after` sets the start time at the
+//     current time.
+//   - `every` runs something at an interval after its start time
+//   - Omitting `every` runs something just once.
+//   - `for` specifies how long "every" runs
+//   - `until` specifies a time for "every" to stop running at
 type scheduler struct {
-	fn     func()
 	at     time.Time
 	after  time.Duration
 	every  time.Duration
 	length time.Duration
 	until  time.Time
-	closer chan bool
-}
-
-func newScheduler(fn func()) *scheduler {
-	return &scheduler{fn: fn, closer: make(chan bool, 1)}
+	// splayed offsets the first run by a hash of the seed, which is
+	// resolved into the offset when the scheduler starts.
+	splayed bool
+	seed    string
+	offset  time.Duration
+	// blackouts are periods in which runs are skipped.
+	blackouts []blackout
+	// probability, if set, is the chance that each run goes ahead.
+	probability float64
+	// adaptive, if set, stretches the interval under load.
+	adaptive *adaptivity
+	// clock, if set, replaces the system's clock.
+	clock Clock
+	// next holds the time of the next run in Unix nanoseconds, or
+	// zero if there is none. It's shared with the running copy.
+	next *int64
+	// state persists the schedule across restarts. The schedule is
+	// resumed from it when the scheduler starts.
+	state       *stateFile
+	resumeAt    time.Time
+	resumeUntil time.Time
+	// runUntil is the deadline of the running copy, set once its first
+	// run is due, so that it's kept if the loop is restarted.
+	runUntil time.Time
+	closer   chan struct{}
+	done     chan struct{}
 }
 
 // `after` starts something at the given duration after the current time.
@@ -67,39 +94,165 @@ func (s *scheduler) Until(until time.Time) *scheduler {
 	return s
 }
 
+// `splayed` offsets the first run by a deterministic hash of the
+// seed, within the `every` interval. An empty seed uses the hostname.
+func (s *scheduler) Splayed(seed string) *scheduler {
+	s.splayed = true
+	s.seed = seed
+	return s
+}
+
+// `exceptBetween` skips runs between the start and end times.
+func (s *scheduler) ExceptBetween(start, end time.Time) *scheduler {
+	s.blackouts = append(s.blackouts, windowBlackout{start, end})
+	return s
+}
+
+// `exceptDaily` skips runs between two offsets from local midnight,
+// every day.
+func (s *scheduler) ExceptDaily(from, to time.Duration) *scheduler {
+	s.blackouts = append(s.blackouts, dailyBlackout{from, to})
+	return s
+}
+
+// `withProbability` makes each run go ahead with probability p.
+func (s *scheduler) WithProbability(p float64) *scheduler {
+	s.probability = p
+	return s
+}
+
+// Returns whether a run which is due should go ahead, given the
+// scheduler's probability.
+func (s *scheduler) sampled() bool {
+	return s.probability == 0 || rand.Float64() < s.probability
+}
+
+// Returns whether runs are suppressed at the given time.
+func (s *scheduler) blackedOut(t time.Time) bool {
+	for _, b := range s.blackouts {
+		if b.contains(t) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (s *scheduler) validate() error {
 	if !s.at.IsZero() && s.after > 0 {
-		return errors.New("Swat Error: Using both 'At' and 'After' will lead to unexepected results.")
+		return ErrConflictingStart
 	}
 
 	if !s.until.IsZero() && s.length > 0 {
-		return errors.New("Swat Error: Using both 'Until' and 'For' will lead to unexepected results.")
+		return ErrConflictingEnd
+	}
+
+	if (s.length > 0 || !s.until.IsZero() || s.splayed) && s.every == 0 {
+		return ErrMissingInterval
+	}
+
+	if a := s.adaptive; a != nil && (s.every == 0 || a.max < s.every || (a.cpu <= 0 && a.latency <= 0)) {
+		return fmt.Errorf("%w: 'Adaptive' requires 'Every', a limit no shorter than it, and a threshold", ErrInvalidOption)
+	}
+
+	if s.probability < 0 || s.probability > 1 {
+		return fmt.Errorf("%w: 'WithProbability' requires a probability between 0 and 1", ErrInvalidOption)
 	}
 
-	if (s.length > 0 || !s.until.IsZero()) && s.every == 0 {
-		return errors.New("Swat Error: 'Every' is required when using 'Until' or 'For'.")
+	for _, b := range s.blackouts {
+		if !b.valid() {
+			return fmt.Errorf("%w: invalid blackout window (%s)", ErrInvalidOption, b)
+		}
 	}
 
 	return nil
 }
 
 func (s *scheduler) end() {
-	select {
-	case <-s.closer:
-	case s.closer <- true:
-		<-s.closer
+	if s.done == nil {
+		return
+	}
+
+	close(s.closer)
+	<-s.done
+}
+
+// Describes the schedule, as in "after 5m0s every 1s for 1m0s".
+func (s *scheduler) String() string {
+	parts := []string{}
+	if s.after > 0 {
+		parts = append(parts, "after "+s.after.String())
+	} else if !s.at.IsZero() {
+		parts = append(parts, "at "+s.at.Format(time.RFC3339))
+	}
+
+	if s.every == 0 {
+		parts = append(parts, "once")
+	} else {
+		parts = append(parts, "every "+s.every.String())
+		if s.splayed {
+			parts = append(parts, "splayed")
+		}
+		if s.adaptive != nil {
+			parts = append(parts, s.adaptive.String())
+		}
+		if s.length > 0 {
+			parts = append(parts, "for "+s.length.String())
+		} else if !s.until.IsZero() {
+			parts = append(parts, "until "+s.until.Format(time.RFC3339))
+		}
+	}
+
+	if s.probability > 0 {
+		parts = append(parts, "with probability "+strconv.FormatFloat(s.probability, 'g', -1, 64))
+	}
+
+	for _, b := range s.blackouts {
+		parts = append(parts, b.String())
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// Returns the time of the next scheduled run, or the zero time if
+// there is none.
+func (s *scheduler) nextRun() time.Time {
+	if s.next == nil {
+		return time.Time{}
+	}
+
+	next := atomic.LoadInt64(s.next)
+	if next == 0 {
+		return time.Time{}
 	}
+
+	return time.Unix(0, next)
 }
 
 // gets the initial sleep time before starting calling the function.
 func (s *scheduler) resolveSleep() time.Duration {
-	if s.after > 0 {
-		return s.after
+	if !s.resumeAt.IsZero() {
+		return s.resumeAt.Sub(s.getClock().Now())
+	} else if s.after > 0 {
+		return s.after + s.offset
 	} else if !s.at.IsZero() {
-		return s.at.Sub(time.Now())
+		return s.at.Sub(s.getClock().Now()) + s.offset
 	}
 
-	return 0
+	return s.offset
+}
+
+// Returns an offset within the interval derived from a hash of the
+// seed, or of the hostname if the seed is empty, so that replicas
+// of a service spread their runs out rather than running at once.
+func splayOffset(seed string, every time.Duration) time.Duration {
+	if seed == "" {
+		seed, _ = os.Hostname()
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(seed))
+	return time.Duration(h.Sum64() % uint64(every))
 }
 
 // Returns whether there's enough data to qualify the scheduler
@@ -112,8 +265,10 @@ func (s *scheduler) isActivated() bool {
 
 // Returns the time that the scheduler should run until.
 func (s *scheduler) getUntil() time.Time {
-	if s.length > 0 {
-		return time.Now().Add(s.length)
+	if !s.resumeUntil.IsZero() {
+		return s.resumeUntil
+	} else if s.length > 0 {
+		return s.getClock().Now().Add(s.length)
 	} else if !s.until.IsZero() {
 		return s.until
 	}
@@ -123,35 +278,151 @@ func (s *scheduler) getUntil() time.Time {
 	return time.Unix(1<<62, 0)
 }
 
-func (s *scheduler) start() {
-	defer func() {
-		s.closer <- true
-	}()
+// Resumes the schedule from the persisted state, if any: the first
+// run is due one interval after the last, and a deadline set by
+// `For` is kept rather than restarted.
+func (s *scheduler) resume(state *stateFile) {
+	s.state = state
+	s.resumeAt, s.resumeUntil = time.Time{}, time.Time{}
+	if state == nil {
+		return
+	}
 
-	// Deactivate the scheduler if nothing useful was passed.
+	saved := state.snapshot()
+	if s.length > 0 {
+		s.resumeUntil = saved.Until
+	}
+
+	if s.every > 0 && !saved.LastRun.IsZero() {
+		s.resumeAt = saved.LastRun.Add(s.every)
+	}
+}
+
+func (s *scheduler) source() TriggerSource {
+	return SourceSchedule
+}
+
+// Returns a scheduler with the same schedule, which isn't running and
+// isn't resumed from any saved state.
+func (s *scheduler) clone() trigger {
+	return &scheduler{
+		at:          s.at,
+		after:       s.after,
+		every:       s.every,
+		length:      s.length,
+		until:       s.until,
+		splayed:     s.splayed,
+		seed:        s.seed,
+		blackouts:   append([]blackout(nil), s.blackouts...),
+		probability: s.probability,
+		adaptive:    s.adaptive,
+		clock:       s.clock,
+	}
+}
 
+func (s *scheduler) start(fn func()) error {
+	// Deactivate the scheduler if nothing useful was passed.
 	if !s.isActivated() {
-		return
+		s.closer, s.done = nil, nil
+		return nil
 	}
 
-	select {
-	case <-s.closer:
+	s.closer = make(chan struct{})
+	s.done = make(chan struct{})
+
+	s.next = new(int64)
+	s.offset = 0
+	if s.splayed {
+		s.offset = splayOffset(s.seed, s.every)
+	}
+
+	// The first run is scheduled before returning, so that NextRun
+	// knows of it, and fake clocks see the timer as soon as the action
+	// has started.
+	clock := s.getClock()
+	sleep := s.resolveSleep()
+	atomic.StoreInt64(s.next, clock.Now().Add(sleep).UnixNano())
+	timer := clock.NewTimer(sleep)
+
+	// Run on a copy, so that the schedule is frozen from here on and
+	// later calls to the setters don't race with the running loop.
+	frozen := *s
+	supervise(s, frozen.closer, frozen.done, func() error {
+		frozen.run(fn, clock, timer)
+		return nil
+	})
+
+	return nil
+}
+
+// Runs the schedule, first waiting for the timer. A single timer is
+// reused for every wait, rather than allocating a new one each
+// iteration with time.After. If the loop is restarted after a panic,
+// the run which panicked counts, so it waits a whole interval before
+// the next one, and a one-off schedule is finished.
+func (s *scheduler) run(fn func(), clock Clock, timer Timer) {
+	defer atomic.StoreInt64(s.next, 0)
+	defer timer.Stop()
+
+	if !s.runUntil.IsZero() {
+		if s.every == 0 {
+			return
+		}
+
+		atomic.StoreInt64(s.next, clock.Now().Add(s.every).UnixNano())
+		timer.Reset(s.every)
+	}
+
+	if !s.wait(timer) {
 		return
-	case <-time.After(s.resolveSleep()):
 	}
 
-	until := s.getUntil()
-	for time.Now().Before(until) {
-		s.fn()
+	if s.runUntil.IsZero() {
+		s.runUntil = s.getUntil()
+		if s.state != nil && s.length > 0 && s.resumeUntil.IsZero() {
+			if err := s.state.setUntil(s.runUntil); err != nil {
+				log.Printf("Swat Error: %s", err)
+			}
+		}
+	}
+	until := s.runUntil
+
+	var measure func() (float64, time.Duration)
+	if s.adaptive != nil {
+		measure = newLoadMeter()
+	}
+
+	interval := s.every
+	for now := clock.Now(); now.Before(until); now = clock.Now() {
+		if !s.blackedOut(now) && s.sampled() {
+			fn()
+		}
 
 		if s.every == 0 {
 			return
 		}
 
-		select {
-		case <-s.closer:
+		if measure != nil {
+			cpu, latency := measure()
+			interval = s.adaptive.adjust(interval, s.every, cpu, latency)
+		}
+
+		atomic.StoreInt64(s.next, clock.Now().Add(interval).UnixNano())
+		timer.Reset(interval)
+		if !s.wait(timer) {
 			return
-		case <-time.After(s.every):
 		}
 	}
 }
+
+// Waits for the timer to fire, returning false if the scheduler
+// was ended first. The timer is always drained when this returns
+// true, so it's safe to Reset afterwards.
+func (s *scheduler) wait(timer Timer) bool {
+	select {
+	case <-s.closer:
+		return false
+	case <-timer.C():
+		return true
+	}
+}
@@ -1,18 +1,30 @@
 package profile
 
 import (
+	"errors"
 	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
 
-func newTestScheduler() (*scheduler, *[]time.Time) {
+// Starts the scheduler, recording the times at which it runs. The
+// returned function gives a snapshot of the times recorded so far.
+func startTestScheduler(s *scheduler) func() []time.Time {
+	mu := new(sync.Mutex)
 	times := []time.Time{}
-	s := newScheduler(func() {
+	s.start(func() {
+		mu.Lock()
 		times = append(times, time.Now())
+		mu.Unlock()
 	})
 
-	return s, &times
+	return func() []time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]time.Time(nil), times...)
+	}
 }
 
 func assertTimeWithin(t *testing.T, t1, t2 time.Time, delta time.Duration) {
@@ -27,48 +39,238 @@ func assertTimeWithin(t *testing.T, t1, t2 time.Time, delta time.Duration) {
 	}
 }
 
+// Asserts that a run happened no earlier than expected, since timers
+// never fire early, and not much later, allowing for slow machines.
+func assertRunAt(t *testing.T, run, expected time.Time) {
+	if run.Before(expected.Add(-time.Millisecond)) {
+		t.Errorf("Expected a run no earlier than %s, but got %s", expected, run)
+	}
+
+	assertTimeWithin(t, run, expected, 50*time.Millisecond)
+}
+
+// Waits for a scheduler with a limited schedule to finish it.
+func waitForSchedule(t *testing.T, s *scheduler) {
+	select {
+	case <-s.done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the schedule didn't finish")
+	}
+}
+
 func TestScheduleAtOnce(t *testing.T) {
-	s, times := newTestScheduler()
+	s := new(scheduler)
 	start := time.Now()
 	s.At(start.Add(100 * time.Millisecond))
 
-	go s.start()
+	times := startTestScheduler(s)
 	defer s.end()
 
-	time.Sleep(200 * time.Millisecond)
-	assertTimeWithin(t, (*times)[0], start.Add(100*time.Millisecond), time.Millisecond*20)
-	assert.Equal(t, 1, len(*times))
+	waitForSchedule(t, s)
+	if assert.Equal(t, 1, len(times())) {
+		assertRunAt(t, times()[0], start.Add(100*time.Millisecond))
+	}
 }
 
 func TestScheduleAfterMany(t *testing.T) {
-	s, times := newTestScheduler()
+	s := new(scheduler)
 	start := time.Now()
 	s.After(500 * time.Millisecond).
 		Every(80 * time.Millisecond).
 		For(200 * time.Millisecond)
 
-	go s.start()
+	times := startTestScheduler(s)
 	defer s.end()
 
-	time.Sleep(800 * time.Millisecond)
-	assertTimeWithin(t, (*times)[0], start.Add(500*time.Millisecond), time.Millisecond*20)
-	assertTimeWithin(t, (*times)[1], start.Add(580*time.Millisecond), time.Millisecond*20)
-	assertTimeWithin(t, (*times)[2], start.Add(660*time.Millisecond), time.Millisecond*20)
-	assert.Equal(t, 3, len(*times))
+	waitForSchedule(t, s)
+	if assert.Equal(t, 3, len(times())) {
+		assertRunAt(t, times()[0], start.Add(500*time.Millisecond))
+		assertRunAt(t, times()[1], times()[0].Add(80*time.Millisecond))
+		assertRunAt(t, times()[2], times()[1].Add(80*time.Millisecond))
+	}
 }
 
 func TestScheduleImmediatelyUntil(t *testing.T) {
-	s, times := newTestScheduler()
+	s := new(scheduler)
 	start := time.Now()
 	s.Every(80 * time.Millisecond).Until(start.Add(300 * time.Millisecond))
 
-	go s.start()
+	times := startTestScheduler(s)
+	defer s.end()
+
+	waitForSchedule(t, s)
+	if assert.Equal(t, 4, len(times())) {
+		assertRunAt(t, times()[0], start)
+		assertRunAt(t, times()[1], times()[0].Add(80*time.Millisecond))
+		assertRunAt(t, times()[2], times()[1].Add(80*time.Millisecond))
+		assertRunAt(t, times()[3], times()[2].Add(80*time.Millisecond))
+	}
+}
+
+func TestSchedulerWaitDoesNotAllocate(t *testing.T) {
+	s := new(scheduler)
+	s.closer = make(chan struct{})
+	timer := systemClock{}.NewTimer(0)
+	defer timer.Stop()
+	s.wait(timer)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		timer.Reset(time.Nanosecond)
+		s.wait(timer)
+	})
+
+	assert.Equal(t, float64(0), allocs)
+}
+
+func TestSplayOffsetIsDeterministicAndBounded(t *testing.T) {
+	every := time.Minute
+	a := splayOffset("replica-a", every)
+	assert.Equal(t, a, splayOffset("replica-a", every))
+	assert.NotEqual(t, a, splayOffset("replica-b", every))
+
+	for _, seed := range []string{"x", "y", "z", ""} {
+		offset := splayOffset(seed, every)
+		assert.True(t, offset >= 0 && offset < every)
+	}
+}
+
+func TestBlackouts(t *testing.T) {
+	day := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	s := new(scheduler)
+	s.ExceptDaily(9*time.Hour, 17*time.Hour)
+	assert.True(t, s.blackedOut(day.Add(12*time.Hour)))
+	assert.False(t, s.blackedOut(day.Add(18*time.Hour)))
+
+	s = new(scheduler)
+	s.ExceptDaily(22*time.Hour, 2*time.Hour)
+	assert.True(t, s.blackedOut(day.Add(23*time.Hour)))
+	assert.True(t, s.blackedOut(day.Add(time.Hour)))
+	assert.False(t, s.blackedOut(day.Add(12*time.Hour)))
+
+	s = new(scheduler)
+	s.ExceptBetween(day, day.Add(time.Hour))
+	assert.True(t, s.blackedOut(day.Add(30*time.Minute)))
+	assert.False(t, s.blackedOut(day.Add(time.Hour)))
+
+	s.ExceptBetween(day.Add(time.Hour), day)
+	assert.True(t, errors.Is(s.validate(), ErrInvalidOption))
+}
+
+func TestWithProbability(t *testing.T) {
+	s := new(scheduler)
+	assert.True(t, s.sampled())
+
+	s.Every(time.Minute).WithProbability(0.25)
+	assert.Nil(t, s.validate())
+	assert.Equal(t, "every 1m0s with probability 0.25", s.String())
+	assert.Equal(t, 0.25, s.clone().(*scheduler).probability)
+
+	ran := 0
+	for i := 0; i < 10000; i++ {
+		if s.sampled() {
+			ran++
+		}
+	}
+	assert.InDelta(t, 2500, ran, 250)
+
+	s.WithProbability(1)
+	for i := 0; i < 100; i++ {
+		assert.True(t, s.sampled())
+	}
+
+	s.WithProbability(1.5)
+	assert.True(t, errors.Is(s.validate(), ErrInvalidOption))
+
+	a, _ := newCountingAction()
+	assert.True(t, errors.Is(a.Every(time.Minute).WithProbability(0).Start(), ErrInvalidOption))
+}
+
+func TestAdaptiveIntervals(t *testing.T) {
+	a := adaptivity{max: 8 * time.Second, cpu: 0.5, latency: 10 * time.Millisecond}
+	every := time.Second
+	assert.Equal(t, 2*time.Second, a.adjust(every, every, 0.9, 0))
+	assert.Equal(t, 2*time.Second, a.adjust(every, every, 0, 20*time.Millisecond))
+	assert.Equal(t, 8*time.Second, a.adjust(8*time.Second, every, 0.9, 0))
+	assert.Equal(t, 2*time.Second, a.adjust(4*time.Second, every, 0.1, 0))
+	assert.Equal(t, every, a.adjust(every, every, 0.1, 0))
+
+	s := new(scheduler)
+	s.Adaptive(time.Minute, 0.8, 0)
+	assert.True(t, errors.Is(s.validate(), ErrInvalidOption))
+	s.Every(time.Second)
+	assert.Nil(t, s.validate())
+	assert.Equal(t, "every 1s backing off to 1m0s on CPU above 0.8", s.String())
+}
+
+func TestAdaptiveAdjust(t *testing.T) {
+	a := adaptivity{max: time.Minute, cpu: 0.5, latency: time.Millisecond}
+	every := time.Second
+
+	assert.Equal(t, 2*time.Second, a.adjust(every, every, 0.6, 0))
+	assert.Equal(t, 4*time.Second, a.adjust(2*time.Second, every, 0, 2*time.Millisecond))
+	assert.Equal(t, time.Minute, a.adjust(40*time.Second, every, 0.6, 0))
+	assert.Equal(t, 2*time.Second, a.adjust(4*time.Second, every, 0.1, 0))
+	assert.Equal(t, every, a.adjust(every, every, 0.1, 0))
+
+	// Zero thresholds aren't checked.
+	a.latency = 0
+	assert.Equal(t, every, a.adjust(every, every, 0.1, time.Hour))
+}
+
+func TestAdaptiveBacksOffUnderLoad(t *testing.T) {
+	defer func(orig func() func() (float64, time.Duration)) { newLoadMeter = orig }(newLoadMeter)
+
+	// The load is high for the first four measurements, and then falls.
+	mu := new(sync.Mutex)
+	var measured []time.Time
+	newLoadMeter = func() func() (float64, time.Duration) {
+		return func() (float64, time.Duration) {
+			mu.Lock()
+			defer mu.Unlock()
+			measured = append(measured, time.Now())
+			if len(measured) <= 4 {
+				return 1, 0
+			}
+
+			return 0, 0
+		}
+	}
+
+	a, _ := newCountingAction()
+	a.Every(time.Millisecond).Adaptive(time.Hour, 0.5, 0)
+	assert.Nil(t, a.Start())
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(measured) >= 5
+	}, 10*time.Second, time.Millisecond)
+	a.End()
+
+	// The interval doubles after each measurement under load: 2, 4, 8
+	// and 16ms. Timers never fire early, so the gaps are at least that.
+	mu.Lock()
+	defer mu.Unlock()
+	for i := 1; i < 5; i++ {
+		gap, least := measured[i].Sub(measured[i-1]), time.Millisecond<<uint(i)
+		assert.True(t, gap >= least, "gap %d was %s, expected at least %s", i, gap, least)
+	}
+}
+
+func TestPanickingScheduleIsRestarted(t *testing.T) {
+	defer func(d time.Duration) { restartDelay = d }(restartDelay)
+	restartDelay = time.Millisecond
+
+	var runs int32
+	s := new(scheduler)
+	s.Every(5 * time.Millisecond)
+	assert.Nil(t, s.start(func() {
+		if atomic.AddInt32(&runs, 1) == 1 {
+			panic("run failed")
+		}
+	}))
 	defer s.end()
 
-	time.Sleep(400 * time.Millisecond)
-	assertTimeWithin(t, (*times)[0], start.Add(0*time.Millisecond), time.Millisecond*20)
-	assertTimeWithin(t, (*times)[1], start.Add(80*time.Millisecond), time.Millisecond*20)
-	assertTimeWithin(t, (*times)[2], start.Add(160*time.Millisecond), time.Millisecond*20)
-	assertTimeWithin(t, (*times)[3], start.Add(240*time.Millisecond), time.Millisecond*20)
-	assert.Equal(t, 4, len(*times))
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&runs) >= 3 }, 5*time.Second, time.Millisecond)
+	assert.False(t, isStalled(s))
 }
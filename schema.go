@@ -0,0 +1,15 @@
+package profile
+
+// The version of the JSON which Swat writes for tooling: the summary
+// written by SummaryTo, the manifest written by ManifestTo, the alerts
+// posted by AlertWebhook, the statuses published by PublishExpvar, and
+// the listing served by Ring. Each document has it as its
+// "SchemaVersion" field, except for the Ring's listing, whose fields
+// are all lowercase, where it's "schema_version". The replies of the
+// console served by ServeConsole are for humans, and aren't versioned.
+//
+// Within a version, fields are only ever added: none are removed,
+// renamed, or given another type or meaning, so tooling should ignore
+// fields it doesn't know. Any other change increments the version, so
+// tooling should check it, and refuse versions it doesn't know.
+const SchemaVersion = 1
@@ -0,0 +1,151 @@
+package profile
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SFTPSink is a Sink which ships each dump to a remote host over
+// SFTP, such as a bastion or collection host, for environments
+// without object storage or HTTP collectors. It runs the system's
+// `sftp` client in batch mode, authenticating with a private key, and
+// checks the host against the user's known_hosts as ssh would.
+type SFTPSink struct {
+	target  string
+	key     string
+	port    int
+	names   objectNamer
+	command string
+	timeout time.Duration
+}
+
+// How long an upload to an SFTP sink may take by default, including
+// connecting, before sftp is killed.
+const defaultSFTPTimeout = 2 * time.Minute
+
+// Creates a sink which uploads dumps to the target, as in
+// "dumps@collector.internal", authenticating with the private key
+// file. Remote paths are given by the text/template, which can use
+// the same fields as ToFileTemplate, as in
+// "/srv/dumps/{{.Name}}-{{.Time}}.{{.Ext}}"; relative paths are
+// relative to the remote user's home directory. Missing directories
+// are created. Uploads which take longer than two minutes are killed,
+// so that an unresponsive host doesn't hold up the action for good.
+func NewSFTPSink(target, keyFile, pattern string) (*SFTPSink, error) {
+	names, err := newObjectNamer(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SFTPSink{target: target, key: keyFile, names: names, command: "sftp", timeout: defaultSFTPTimeout}, nil
+}
+
+// Kills uploads which take longer than the timeout, rather than two
+// minutes. Connecting may take up to half of it.
+func (s *SFTPSink) Timeout(timeout time.Duration) *SFTPSink {
+	s.timeout = timeout
+	return s
+}
+
+// Connects to the port rather than 22.
+func (s *SFTPSink) Port(port int) *SFTPSink {
+	s.port = port
+	return s
+}
+
+func (s *SFTPSink) String() string {
+	return "sftp://" + s.target + "/" + s.names.tmpl.Root.String()
+}
+
+// Implements Sink.Open. The dump is uploaded once the writer is
+// closed, and closing it returns any error from sftp.
+func (s *SFTPSink) Open(info RunInfo) (io.WriteCloser, error) {
+	return &bufferedWriter{info: info, flush: s.upload}, nil
+}
+
+func (s *SFTPSink) upload(info RunInfo, data []byte) error {
+	remote, err := s.names.name(info)
+	if err != nil {
+		return err
+	}
+
+	// sftp can only put files from disk.
+	f, err := os.CreateTemp("", "swat-sftp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	batch, err := sftpBatch(f.Name(), remote)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, s.command, s.args()...)
+	cmd.Stdin = strings.NewReader(batch)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if ctx.Err() != nil {
+			err = fmt.Errorf("timed out after %s", s.timeout)
+		}
+
+		return fmt.Errorf("uploading to %s: %w: %s", s.target, err, bytes.TrimSpace(out))
+	}
+
+	return nil
+}
+
+// Returns the arguments to sftp, which read the batch from stdin and
+// never prompt for passwords or wait long to connect.
+func (s *SFTPSink) args() []string {
+	connect := int((s.timeout / 2).Seconds())
+	if connect < 1 {
+		connect = 1
+	}
+
+	args := []string{"-b", "-", "-o", "BatchMode=yes", "-o", "ConnectTimeout=" + strconv.Itoa(connect), "-i", s.key}
+	if s.port != 0 {
+		args = append(args, "-P", strconv.Itoa(s.port))
+	}
+
+	return append(args, s.target)
+}
+
+// Returns the sftp commands which upload the local file to the remote
+// path, first creating its directories. Failures to create them are
+// ignored, since they usually exist already.
+func sftpBatch(local, remote string) (string, error) {
+	if strings.ContainsAny(local+remote, "\"\n") {
+		return "", fmt.Errorf("%w: paths for sftp can't contain quotes or newlines: %q", ErrInvalidOption, remote)
+	}
+
+	var dirs []string
+	for dir := path.Dir(remote); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		dirs = append([]string{dir}, dirs...)
+	}
+
+	batch := new(strings.Builder)
+	for _, dir := range dirs {
+		fmt.Fprintf(batch, "-mkdir \"%s\"\n", dir)
+	}
+	fmt.Fprintf(batch, "put \"%s\" \"%s\"\n", local, remote)
+	return batch.String(), nil
+}
@@ -0,0 +1,56 @@
+package profile
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestSFTPBatch(t *testing.T) {
+	batch, err := sftpBatch("/tmp/dump", "/srv/dumps/heap/1.out")
+	assert.Nil(t, err)
+	assert.Equal(t, "-mkdir \"/srv\"\n-mkdir \"/srv/dumps\"\n-mkdir \"/srv/dumps/heap\"\n"+
+		"put \"/tmp/dump\" \"/srv/dumps/heap/1.out\"\n", batch)
+
+	batch, err = sftpBatch("/tmp/dump", "heap.out")
+	assert.Nil(t, err)
+	assert.Equal(t, "put \"/tmp/dump\" \"heap.out\"\n", batch)
+
+	_, err = sftpBatch("/tmp/dump", "bad\".out")
+	assert.NotNil(t, err)
+}
+
+func TestSFTPSinkTimesOut(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell script to stand in for sftp")
+	}
+
+	script := filepath.Join(t.TempDir(), "sftp")
+	assert.Nil(t, os.WriteFile(script, []byte("#!/bin/sh\nexec sleep 60\n"), 0755))
+
+	sink, _ := NewSFTPSink("dumps@collector", "/keys/id_ed25519", "")
+	sink.command = script
+	assert.Contains(t, sink.Timeout(50*time.Millisecond).args(), "ConnectTimeout=1")
+
+	start := time.Now()
+	err := newOutputAction("heap", "dump").To(sink).Trigger(context.Background())
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "timed out after 50ms")
+	}
+	assert.True(t, time.Since(start) < 30*time.Second)
+}
+
+func TestSFTPSinkReportsFailures(t *testing.T) {
+	sink, err := NewSFTPSink("dumps@collector", "/keys/id_ed25519", "")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"-b", "-", "-o", "BatchMode=yes", "-o", "ConnectTimeout=60", "-i", "/keys/id_ed25519", "-P", "2222", "dumps@collector"},
+		sink.Port(2222).args())
+
+	sink.command = "swat-no-such-sftp"
+	err = newOutputAction("heap", "dump").To(sink).Trigger(context.Background())
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,10 @@
+//go:build !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package profile
+
+import "os"
+
+// SIGINFO is sent to the foreground process by the terminal when the
+// user presses Ctrl+T. It's nil on platforms which don't have it.
+var SIGINFO os.Signal
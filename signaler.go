@@ -1,16 +1,29 @@
 package profile
 
 import (
+	"errors"
+	"log"
 	"os"
 	"os/signal"
+	"strings"
 )
 
 // Signaller is an embedded struct used to trigger actions when
 // a syscall is sent. It should not be used directly.
 type signaler struct {
-	fn      func()
 	signals []os.Signal
-	closer  chan bool
+	// forward returns the child processes received signals are
+	// passed on to.
+	forward func() []*os.Process
+	// reraise is set for signals whose default effect should still
+	// happen, once the action has handled them.
+	reraise bool
+	// ch receives the signals while the signaler is running, and fn
+	// is run for each of them.
+	ch     chan os.Signal
+	fn     func()
+	closer chan struct{}
+	done   chan struct{}
 }
 
 // Used to run an action when an OS signal is received.
@@ -18,32 +31,116 @@ func (s *signaler) OnSignal(signals ...os.Signal) {
 	s.signals = signals
 }
 
+func (s *signaler) String() string {
+	names := make([]string, len(s.signals))
+	for i, sig := range s.signals {
+		names[i] = sig.String()
+	}
+
+	return "on signal " + strings.Join(names, ", ")
+}
+
+func (s *signaler) source() TriggerSource {
+	return SourceSignal
+}
+
+func (s *signaler) clone() trigger {
+	return &signaler{
+		signals: append([]os.Signal(nil), s.signals...),
+		forward: s.forward,
+		reraise: s.reraise,
+	}
+}
+
 func (s *signaler) end() {
-	select {
-	case <-s.closer:
-	case s.closer <- true:
-		<-s.closer
+	if s.done == nil {
+		return
 	}
+
+	close(s.closer)
+	<-s.done
+	signal.Stop(s.ch)
+	s.fn = nil
 }
 
-func (s *signaler) start() {
-	defer func() {
-		s.closer <- true
-	}()
+// Returns a function which handles the signal as if it had been
+// received, or nil if the signaler isn't running or listening for it.
+func (s *signaler) handler(sig os.Signal) func() {
+	if s.fn == nil {
+		return nil
+	}
 
+	for _, listened := range s.signals {
+		if listened == sig {
+			fn, forward, again := s.fn, s.forward, s.reraise
+			return func() {
+				if forward != nil {
+					forwardSignal(sig, forward())
+				}
+
+				fn()
+				if again {
+					reraise(sig)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *signaler) start(fn func()) error {
 	if len(s.signals) == 0 {
-		return
+		s.closer, s.done = nil, nil
+		return nil
 	}
 
+	if !signalsSupported {
+		s.closer, s.done = nil, nil
+		return ErrUnsupported
+	}
+
+	// Signals are registered before returning, so that none sent
+	// after Start are missed.
 	ch := make(chan os.Signal, 1)
 	signal.Notify(ch, s.signals...)
 
+	s.closer = make(chan struct{})
+	s.done = make(chan struct{})
+	forward, again := s.forward, s.reraise
+	s.ch, s.fn = ch, fn
+	supervise(s, s.closer, s.done, func() error { s.run(fn, ch, forward, again); return nil })
+
+	return nil
+}
+
+// Runs fn for each signal received. If again is set, the signal is
+// then raised again, whether or not the run happened, since it may
+// have been dropped, such as while the action was paused.
+func (s *signaler) run(fn func(), ch chan os.Signal, forward func() []*os.Process, again bool) {
 	for {
 		select {
 		case <-s.closer:
 			return
-		case <-ch:
-			s.fn()
+		case sig := <-ch:
+			if forward != nil {
+				forwardSignal(sig, forward())
+			}
+
+			fn()
+			if again {
+				reraise(sig)
+			}
+		}
+	}
+}
+
+// Sends the signal to each of the processes, logging any failures.
+// Processes which have already exited are ignored.
+func forwardSignal(sig os.Signal, procs []*os.Process) {
+	for _, p := range procs {
+		if err := p.Signal(sig); err != nil && !errors.Is(err, os.ErrProcessDone) {
+			log.Printf("Swat Error: forwarding %s to %d: %s", sig, p.Pid, err)
 		}
 	}
 }
@@ -0,0 +1,80 @@
+//go:build unix
+// +build unix
+
+package profile
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"os/exec"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestForwardSignalReachesChildren(t *testing.T) {
+	child := exec.Command("sleep", "10")
+	if err := child.Start(); err != nil {
+		t.Skip("cannot start child process:", err)
+	}
+
+	a, runs := newCountingAction()
+	a.OnSignal(syscall.SIGUSR1).ForwardSignal(func() []*os.Process {
+		return []*os.Process{child.Process}
+	})
+	assert.Nil(t, a.Start())
+	defer a.End()
+
+	assert.Nil(t, syscall.Kill(os.Getpid(), syscall.SIGUSR1))
+	err := child.Wait()
+	if assert.IsType(t, &exec.ExitError{}, err) {
+		status := err.(*exec.ExitError).Sys().(syscall.WaitStatus)
+		assert.Equal(t, syscall.SIGUSR1, status.Signal())
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(runs))
+}
+
+func TestSignalsHonoredWhilePaused(t *testing.T) {
+	a, runs := newCountingAction()
+	a.OnSignal(syscall.SIGUSR2).Every(time.Millisecond)
+	s := new(Swat).HonorSignalsWhilePaused()
+	s.Pause()
+	assert.Nil(t, s.Boot([]Action{a}))
+	defer s.End()
+
+	time.Sleep(5 * time.Millisecond)
+	assert.Zero(t, atomic.LoadInt32(runs))
+	assert.Nil(t, syscall.Kill(os.Getpid(), syscall.SIGUSR2))
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(runs) == 1 }, 5*time.Second, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(runs))
+}
+
+func TestSIGQUITReraisedWhenRateLimited(t *testing.T) {
+	defer func(orig func(os.Signal)) { reraise = orig }(reraise)
+	reraised := make(chan os.Signal, 2)
+	reraise = func(sig os.Signal) { reraised <- sig }
+
+	dir := t.TempDir()
+	a := DiagnosticsOnSIGQUIT(dir)
+	s := new(Swat).LimitRate(1)
+	assert.Nil(t, s.Boot([]Action{a}))
+	defer s.End()
+
+	// The first signal runs the action, and the second is dropped by
+	// the limiter, but both are raised again.
+	for i := 0; i < 2; i++ {
+		assert.Nil(t, syscall.Kill(os.Getpid(), syscall.SIGQUIT))
+		select {
+		case sig := <-reraised:
+			assert.Equal(t, syscall.SIGQUIT, sig)
+		case <-time.After(5 * time.Second):
+			t.Fatal("SIGQUIT wasn't raised again")
+		}
+	}
+
+	assert.Equal(t, uint64(1), a.Status().Runs)
+}
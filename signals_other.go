@@ -0,0 +1,16 @@
+//go:build !plan9 && !js && !wasip1
+// +build !plan9,!js,!wasip1
+
+package profile
+
+import (
+	"os"
+	"syscall"
+)
+
+// Whether the process can receive signals at all.
+const signalsSupported = true
+
+// The signal which asks a process to dump its state and quit, or nil
+// on platforms which don't have one.
+var sigquit os.Signal = syscall.SIGQUIT
@@ -0,0 +1,10 @@
+package profile
+
+import "os"
+
+// Plan 9 delivers notes, such as os.Interrupt, as signals.
+const signalsSupported = true
+
+// The signal which asks a process to dump its state and quit, or nil
+// on platforms which don't have one.
+var sigquit os.Signal
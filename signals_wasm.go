@@ -0,0 +1,13 @@
+//go:build js || wasip1
+// +build js wasip1
+
+package profile
+
+import "os"
+
+// WebAssembly hosts don't deliver signals.
+const signalsSupported = false
+
+// The signal which asks a process to dump its state and quit, or nil
+// on platforms which don't have one.
+var sigquit os.Signal
@@ -0,0 +1,168 @@
+package profile
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+)
+
+// A Sink receives the output of an action's runs, for destinations
+// other than writers and files. Open is called at the start of each
+// run, with the action's name and extension and the start time, and
+// the writer it returns is closed once the run's output is written.
+// Sinks may be shared between actions, so Open must be safe to call
+// concurrently.
+type Sink interface {
+	Open(info RunInfo) (io.WriteCloser, error)
+}
+
+// Implemented by sinks which can be misconfigured, so that actions
+// written to them fail to start rather than failing every run.
+type invalidSink interface {
+	invalid() error
+}
+
+// Implemented by sinks which can check that dumps can be written to
+// them, for Healthy, such as DirSink, which checks that its directory
+// is writable.
+type checkedSink interface {
+	check() error
+}
+
+// Checks the sink, if it can be checked.
+func checkSink(s Sink) error {
+	if c, ok := s.(checkedSink); ok {
+		return c.check()
+	}
+
+	return nil
+}
+
+// Buffers a run's output, and passes it to flush when closed, with
+// the info's Bytes and Duration filled in. Sinks which deliver each
+// dump as a whole use it.
+type bufferedWriter struct {
+	bytes.Buffer
+	info  RunInfo
+	flush func(info RunInfo, data []byte) error
+}
+
+func (w *bufferedWriter) Close() error {
+	w.info.Bytes = int64(w.Len())
+	w.info.Duration = time.Since(w.info.Start)
+	return w.flush(w.info, w.Bytes())
+}
+
+// Splits the data into chunks of at most `size` bytes, or none if
+// size isn't positive. Empty data is a single empty chunk, so that
+// empty dumps are still delivered.
+func chunk(data []byte, size int) [][]byte {
+	if size <= 0 || len(data) <= size {
+		return [][]byte{data}
+	}
+
+	chunks := make([][]byte, 0, (len(data)+size-1)/size)
+	for len(data) > size {
+		chunks = append(chunks, data[:size])
+		data = data[size:]
+	}
+
+	return append(chunks, data)
+}
+
+// WriterSink is a Sink which writes every dump to the writer, such as
+// os.Stderr.
+func WriterSink(w io.Writer) Sink {
+	return writerSink{w}
+}
+
+type writerSink struct {
+	w io.Writer
+}
+
+func (s writerSink) Open(info RunInfo) (io.WriteCloser, error) {
+	return nopCloser{s.w}, nil
+}
+
+func (s writerSink) String() string {
+	return (&targeter{writer: s.w}).describe(pathVars{})
+}
+
+// DirSink is a Sink which writes each dump to a new file in the
+// directory, named as by ToDir.
+func DirSink(dir string) Sink {
+	return dirSink(dir)
+}
+
+type dirSink string
+
+func (s dirSink) Open(info RunInfo) (io.WriteCloser, error) {
+	return &bufferedWriter{info: info, flush: func(info RunInfo, data []byte) error {
+		_, err := spill(string(s), info, data)
+		return err
+	}}, nil
+}
+
+func (s dirSink) String() string {
+	return string(s)
+}
+
+// Implements checkedSink.
+func (s dirSink) check() error {
+	return dirWritable(string(s))
+}
+
+// Writes each dump to the primary sink, or to the fallback if the
+// primary fails.
+type fallbackSink struct {
+	primary, fallback Sink
+}
+
+func (f fallbackSink) String() string {
+	return describeSink(f.primary) + " (falling back to " + describeSink(f.fallback) + ")"
+}
+
+// Implements checkedSink. Dumps can be written as long as either sink
+// can take them.
+func (f fallbackSink) check() error {
+	err := checkSink(f.primary)
+	if err == nil {
+		return nil
+	}
+
+	return checkSink(f.fallback)
+}
+
+func (f fallbackSink) Open(info RunInfo) (io.WriteCloser, error) {
+	return &bufferedWriter{info: info, flush: f.deliver}, nil
+}
+
+func (f fallbackSink) deliver(info RunInfo, data []byte) error {
+	err := writeSink(f.primary, info, data)
+	if err == nil {
+		return nil
+	}
+
+	if ferr := writeSink(f.fallback, info, data); ferr != nil {
+		return fmt.Errorf("%w (and the fallback failed: %s)", err, ferr)
+	}
+
+	return &fallbackError{err: err, fallback: describeSink(f.fallback)}
+}
+
+// The error from a primary sink whose dump was written to the
+// fallback instead. Since the dump isn't lost, the run doesn't count
+// toward StopAfterFailures.
+type fallbackError struct {
+	err      error
+	fallback string
+}
+
+func (f *fallbackError) Error() string {
+	return fmt.Sprintf("%s (written to %s instead)", f.err, f.fallback)
+}
+
+func (f *fallbackError) Unwrap() error {
+	return f.err
+}
@@ -0,0 +1,124 @@
+package profile
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// The schema of speedscope's file format, which is described at
+// https://github.com/jlfwong/speedscope/wiki/Importing-from-custom-sources.
+const speedscopeSchema = "https://www.speedscope.app/file-format-schema.json"
+
+type speedscopeFile struct {
+	Schema   string              `json:"$schema"`
+	Name     string              `json:"name"`
+	Exporter string              `json:"exporter"`
+	Shared   speedscopeShared    `json:"shared"`
+	Profiles []speedscopeProfile `json:"profiles"`
+}
+
+type speedscopeShared struct {
+	Frames []speedscopeFrame `json:"frames"`
+}
+
+type speedscopeFrame struct {
+	Name string `json:"name"`
+	File string `json:"file,omitempty"`
+}
+
+type speedscopeProfile struct {
+	Type       string  `json:"type"`
+	Name       string  `json:"name"`
+	Unit       string  `json:"unit"`
+	StartValue int64   `json:"startValue"`
+	EndValue   int64   `json:"endValue"`
+	Samples    [][]int `json:"samples"`
+	Weights    []int64 `json:"weights"`
+}
+
+// Converts a pprof profile into speedscope's format, with a sampled
+// profile for each of its sample types, such as "alloc_space" and
+// "inuse_space" for heap profiles.
+func toSpeedscope(name string, p *pprofProfile) *speedscopeFile {
+	out := &speedscopeFile{
+		Schema:   speedscopeSchema,
+		Name:     name,
+		Exporter: "swat",
+		Profiles: make([]speedscopeProfile, len(p.sampleTypes)),
+	}
+
+	// Frames are functions, so that samples from different lines of
+	// the same function are merged, as pprof does by default.
+	frames := make(map[uint64]int)
+	frame := func(fn uint64) int {
+		if i, ok := frames[fn]; ok {
+			return i
+		}
+
+		f := p.functions[fn]
+		frames[fn] = len(out.Shared.Frames)
+		out.Shared.Frames = append(out.Shared.Frames, speedscopeFrame{p.str(f.name), p.str(f.file)})
+		return frames[fn]
+	}
+
+	for i, st := range p.sampleTypes {
+		out.Profiles[i] = speedscopeProfile{
+			Type:    "sampled",
+			Name:    p.str(st.typ),
+			Unit:    speedscopeUnit(p.str(st.unit)),
+			Samples: [][]int{},
+			Weights: []int64{},
+		}
+	}
+
+	for _, s := range p.samples {
+		// Speedscope wants stacks root first, whereas pprof lists
+		// locations, and the inlined lines within them, leaf first.
+		var stack []int
+		for i := len(s.locations) - 1; i >= 0; i-- {
+			lines := p.locations[s.locations[i]]
+			for j := len(lines) - 1; j >= 0; j-- {
+				stack = append(stack, frame(lines[j].function))
+			}
+		}
+
+		for i, v := range s.values {
+			if i >= len(out.Profiles) || v == 0 {
+				continue
+			}
+
+			prof := &out.Profiles[i]
+			prof.Samples = append(prof.Samples, stack)
+			prof.Weights = append(prof.Weights, v)
+			prof.EndValue += v
+		}
+	}
+
+	return out
+}
+
+// Returns speedscope's name for a pprof unit.
+func speedscopeUnit(unit string) string {
+	switch unit {
+	case "nanoseconds", "microseconds", "milliseconds", "seconds", "bytes":
+		return unit
+	default:
+		return "none"
+	}
+}
+
+// Wraps the function, which must write a pprof profile, so that it
+// writes the profile in speedscope's format instead.
+func speedscope(name string, fn func(io.Writer) error) func(io.Writer) error {
+	return func(w io.Writer) error {
+		return buffered(fn, func(buf *bytes.Buffer) error {
+			p, err := parsePProf(buf.Bytes())
+			if err != nil {
+				return err
+			}
+
+			return json.NewEncoder(w).Encode(toSpeedscope(name, p))
+		})
+	}
+}
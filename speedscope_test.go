@@ -0,0 +1,45 @@
+package profile
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestSpeedscopeConvertsProfiles(t *testing.T) {
+	buf := new(bytes.Buffer)
+	a := DumpPProfLookup("goroutine", 0).Speedscope().ToWriter(buf)
+	assert.Nil(t, a.Trigger(context.Background()))
+	assert.Equal(t, "speedscope.json", a.outputExt())
+
+	var out speedscopeFile
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &out))
+	assert.Equal(t, speedscopeSchema, out.Schema)
+	assert.Equal(t, "goroutine", out.Name)
+	if assert.Len(t, out.Profiles, 1) {
+		prof := out.Profiles[0]
+		assert.Equal(t, "goroutine", prof.Name)
+		assert.Equal(t, "none", prof.Unit)
+		assert.NotEmpty(t, prof.Samples)
+		assert.Len(t, prof.Weights, len(prof.Samples))
+
+		// Stacks are root first, so this test is under testing.tRunner.
+		var names []string
+		for _, stack := range prof.Samples {
+			for _, i := range stack {
+				names = append(names, out.Shared.Frames[i].Name)
+			}
+		}
+		assert.Contains(t, names, "testing.tRunner")
+		assert.Contains(t, strings.Join(names, " "), ".TestSpeedscopeConvertsProfiles")
+	}
+}
+
+func TestSpeedscopeRejectsText(t *testing.T) {
+	err := DumpGoroutine().Speedscope().ToWriter(new(bytes.Buffer)).Trigger(context.Background())
+	assert.True(t, errors.Is(err, ErrUnexpectedFormat), "%v", err)
+}
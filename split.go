@@ -0,0 +1,136 @@
+package profile
+
+import (
+	"fmt"
+	"io"
+)
+
+// SplitSink wraps a sink, splitting each dump across numbered parts
+// of at most a maximum size, for collectors and object stores which
+// cap the size of each object. Each part is opened on the sink in
+// turn, with the part's number, from 001, appended to the extension,
+// so that a heap profile becomes "pb.gz.001", "pb.gz.002" and so on.
+// Concatenating the parts in order restores the dump:
+//
+//	cat heap-*.pb.gz.* > heap.pb.gz
+//
+// Parts are written as the action produces its output, so only one
+// is open at a time, and a dump is never buffered whole.
+type SplitSink struct {
+	sink Sink
+	size int64
+	err  error
+}
+
+// Wraps the sink so that dumps are split into parts of at most size
+// bytes. The size must be positive; an action written to a sink with
+// a smaller one fails to start.
+func Split(sink Sink, size int64) *SplitSink {
+	s := &SplitSink{sink: sink, size: size}
+	if size < 1 {
+		s.err = fmt.Errorf("%w: 'Split' requires a positive size", ErrInvalidOption)
+	}
+
+	return s
+}
+
+// Implements invalidSink.
+func (s *SplitSink) invalid() error {
+	return s.err
+}
+
+// Implements checkedSink.
+func (s *SplitSink) check() error {
+	return checkSink(s.sink)
+}
+
+func (s *SplitSink) String() string {
+	return fmt.Sprintf("%s (in parts of %d bytes)", describeSink(s.sink), s.size)
+}
+
+// Implements Sink.Open. Closing the writer closes the last part, and
+// returns the first error from any part.
+func (s *SplitSink) Open(info RunInfo) (io.WriteCloser, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return &splitWriter{sink: s, info: info}, nil
+}
+
+// Writes a dump to the sink's parts. Part is the number of the last
+// part opened, and left the room in it, if it's still open.
+type splitWriter struct {
+	sink *SplitSink
+	info RunInfo
+	part int
+	cur  io.WriteCloser
+	left int64
+	err  error
+}
+
+func (w *splitWriter) next() error {
+	w.part++
+	info := w.info
+	info.Ext = fmt.Sprintf("%s.%03d", info.Ext, w.part)
+	cur, err := w.sink.sink.Open(info)
+	if err != nil {
+		return fmt.Errorf("opening part %d: %w", w.part, err)
+	}
+
+	w.cur, w.left = cur, w.sink.size
+	return nil
+}
+
+func (w *splitWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 && w.err == nil {
+		if w.cur == nil {
+			if w.err = w.next(); w.err != nil {
+				break
+			}
+		}
+
+		chunk := p
+		if int64(len(chunk)) > w.left {
+			chunk = chunk[:w.left]
+		}
+
+		n, err := w.cur.Write(chunk)
+		written += n
+		w.left -= int64(n)
+		p = p[n:]
+		if err != nil {
+			w.err = err
+		} else if w.left == 0 {
+			w.err = w.closePart()
+		}
+	}
+
+	return written, w.err
+}
+
+func (w *splitWriter) closePart() error {
+	cur := w.cur
+	w.cur = nil
+	if err := cur.Close(); err != nil {
+		return fmt.Errorf("closing part %d: %w", w.part, err)
+	}
+
+	return nil
+}
+
+// Empty dumps are still delivered, as a single empty part.
+func (w *splitWriter) Close() error {
+	if w.err == nil && w.part == 0 {
+		w.err = w.next()
+	}
+
+	if w.cur != nil {
+		if err := w.closePart(); w.err == nil {
+			w.err = err
+		}
+	}
+
+	return w.err
+}
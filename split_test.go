@@ -0,0 +1,60 @@
+package profile
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Reads the parts in the directory, keyed by their extension.
+func readParts(t *testing.T, dir string) map[string]string {
+	files, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+
+	parts := map[string]string{}
+	for _, f := range files {
+		data, err := os.ReadFile(filepath.Join(dir, f.Name()))
+		assert.Nil(t, err)
+		parts[f.Name()[strings.Index(f.Name(), ".dump.")+1:]] = string(data)
+	}
+
+	return parts
+}
+
+func TestSplitSinkWritesNumberedParts(t *testing.T) {
+	dir := t.TempDir()
+	a := newOutputAction("heap", "0123456789").To(Split(DirSink(dir), 4))
+	a.ext = "dump"
+	assert.Equal(t, "heap → "+dir+" (in parts of 4 bytes)", a.Describe())
+	assert.Nil(t, a.Trigger(context.Background()))
+	assert.Equal(t, map[string]string{"dump.001": "0123", "dump.002": "4567", "dump.003": "89"}, readParts(t, dir))
+
+	// Filling the last part exactly doesn't leave an empty one.
+	dir = t.TempDir()
+	a = newOutputAction("heap", "01234567").To(Split(DirSink(dir), 4))
+	a.ext = "dump"
+	assert.Nil(t, a.Trigger(context.Background()))
+	assert.Equal(t, map[string]string{"dump.001": "0123", "dump.002": "4567"}, readParts(t, dir))
+
+	// Empty dumps are still delivered.
+	dir = t.TempDir()
+	a = newOutputAction("heap", "").To(Split(DirSink(dir), 4))
+	a.ext = "dump"
+	assert.Nil(t, a.Trigger(context.Background()))
+	assert.Equal(t, map[string]string{"dump.001": ""}, readParts(t, dir))
+}
+
+func TestSplitSinkReportsFailedParts(t *testing.T) {
+	p := &fakeProducer{err: errors.New("broker down")}
+	err := newOutputAction("heap", "0123456789").To(Split(NewKafkaSink(p, "dumps"), 4)).Trigger(context.Background())
+	assert.True(t, errors.Is(err, p.err))
+	assert.Contains(t, err.Error(), "part 1")
+}
+
+func TestSplitRequiresPositiveSize(t *testing.T) {
+	assert.True(t, errors.Is(newOutputAction("heap", "").To(Split(DirSink(t.TempDir()), 0)).Start(), ErrInvalidOption))
+}
@@ -0,0 +1,95 @@
+package profile
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"time"
+)
+
+// The schema of SQLiteSink's archive. Times are stored as RFC 3339
+// text in UTC, which SQLite's date functions understand, with fixed
+// width fractional seconds, so that they sort chronologically.
+var sqliteSchema = []string{
+	`CREATE TABLE IF NOT EXISTS swat_dumps (
+		id INTEGER PRIMARY KEY,
+		action TEXT NOT NULL,
+		source TEXT NOT NULL,
+		started_at TEXT NOT NULL,
+		ext TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		data BLOB NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS swat_dumps_action ON swat_dumps (action, started_at)`,
+	`CREATE INDEX IF NOT EXISTS swat_dumps_started_at ON swat_dumps (started_at)`,
+}
+
+const sqliteTime = "2006-01-02T15:04:05.000000000Z"
+
+// SQLiteSink is a Sink which archives each dump as a row in a local
+// SQLite database, so that historical dumps can be found, read back
+// and pruned with SQL. Dumps are stored in the swat_dumps table:
+//
+//	id          the row's ID
+//	action      the name of the action
+//	source      why the run happened, such as "signal"
+//	started_at  the start of the run, in RFC 3339 format, in UTC
+//	ext         the extension of the output, such as "pb.gz"
+//	size        the size of the dump, in bytes
+//	data        the dump
+//
+// which is indexed by action and time, so that, for example, the
+// latest heap profile is
+//
+//	SELECT data FROM swat_dumps WHERE action = 'heap' ORDER BY started_at DESC LIMIT 1
+//
+// Swat doesn't depend on a SQLite driver; open the database with the
+// one your program already uses, such as modernc.org/sqlite.
+type SQLiteSink struct {
+	db *sql.DB
+}
+
+// Creates a sink which archives dumps to the database, creating the
+// table and its indexes if they don't exist.
+func NewSQLiteSink(db *sql.DB) (*SQLiteSink, error) {
+	for _, stmt := range sqliteSchema {
+		if _, err := db.Exec(stmt); err != nil {
+			return nil, fmt.Errorf("creating the swat_dumps table: %w", err)
+		}
+	}
+
+	return &SQLiteSink{db: db}, nil
+}
+
+func (s *SQLiteSink) String() string {
+	return "sqlite table swat_dumps"
+}
+
+// Implements Sink.Open. The dump is stored once the writer is closed,
+// and closing it returns any error from the database.
+func (s *SQLiteSink) Open(info RunInfo) (io.WriteCloser, error) {
+	return &bufferedWriter{info: info, flush: s.insert}, nil
+}
+
+func (s *SQLiteSink) insert(info RunInfo, data []byte) error {
+	_, err := s.db.Exec(
+		"INSERT INTO swat_dumps (action, source, started_at, ext, size, data) VALUES (?, ?, ?, ?, ?, ?)",
+		info.Name, string(info.Source), info.Start.UTC().Format(sqliteTime), info.Ext, len(data), data)
+	if err != nil {
+		return fmt.Errorf("archiving to sqlite: %w", err)
+	}
+
+	return nil
+}
+
+// Deletes the dumps from runs which started before the time, returning
+// how many were deleted. SQLite doesn't shrink the file as rows are
+// deleted; run VACUUM to reclaim the space.
+func (s *SQLiteSink) Prune(before time.Time) (int64, error) {
+	res, err := s.db.Exec("DELETE FROM swat_dumps WHERE started_at < ?", before.UTC().Format(sqliteTime))
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,87 @@
+package profile
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A database/sql driver which records the statements it executes.
+type recordingDriver struct {
+	mu    sync.Mutex
+	execs []recordedExec
+	err   error
+}
+
+type recordedExec struct {
+	query string
+	args  []driver.Value
+}
+
+func (d *recordingDriver) Open(string) (driver.Conn, error) { return recordingConn{d}, nil }
+
+type recordingConn struct{ d *recordingDriver }
+
+func (c recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return recordingStmt{c.d, query}, nil
+}
+func (c recordingConn) Close() error              { return nil }
+func (c recordingConn) Begin() (driver.Tx, error) { return nil, errors.New("unsupported") }
+
+type recordingStmt struct {
+	d     *recordingDriver
+	query string
+}
+
+func (s recordingStmt) Close() error  { return nil }
+func (s recordingStmt) NumInput() int { return -1 }
+func (s recordingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	s.d.execs = append(s.d.execs, recordedExec{s.query, args})
+	return driver.RowsAffected(2), s.d.err
+}
+func (s recordingStmt) Query([]driver.Value) (driver.Rows, error) {
+	return nil, errors.New("unsupported")
+}
+
+func TestSQLiteSinkArchivesDumps(t *testing.T) {
+	d := new(recordingDriver)
+	db := sql.OpenDB(driverConnector{d})
+	defer db.Close()
+
+	sink, err := NewSQLiteSink(db)
+	assert.Nil(t, err)
+	assert.Len(t, d.execs, len(sqliteSchema))
+
+	start := time.Date(2024, 3, 1, 12, 0, 5, 0, time.FixedZone("", 3600))
+	w, err := sink.Open(RunInfo{Name: "heap", Ext: "pb.gz", Source: SourceSignal, Start: start})
+	assert.Nil(t, err)
+	w.Write([]byte("dump"))
+	assert.Nil(t, w.Close())
+
+	if assert.Len(t, d.execs, len(sqliteSchema)+1) {
+		insert := d.execs[len(sqliteSchema)]
+		assert.Contains(t, insert.query, "INSERT INTO swat_dumps")
+		assert.Equal(t, []driver.Value{"heap", "signal", "2024-03-01T11:00:05.000000000Z", "pb.gz", int64(4), []byte("dump")}, insert.args)
+	}
+
+	n, err := sink.Prune(start)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, n)
+	assert.Equal(t, []driver.Value{"2024-03-01T11:00:05.000000000Z"}, d.execs[len(d.execs)-1].args)
+
+	d.err = errors.New("disk full")
+	err = newOutputAction("heap", "dump").To(sink).Trigger(context.Background())
+	assert.True(t, errors.Is(err, d.err))
+}
+
+type driverConnector struct{ d driver.Driver }
+
+func (c driverConnector) Connect(context.Context) (driver.Conn, error) { return c.d.Open("") }
+func (c driverConnector) Driver() driver.Driver                        { return c.d }
@@ -0,0 +1,169 @@
+package profile
+
+import (
+	"sync"
+	"time"
+)
+
+// Stats records the outcomes of an action's runs. It outlives any
+// single start of the action.
+type runStats struct {
+	mu      sync.Mutex
+	lastRun time.Time
+	lastErr error
+	runs    uint64
+	errors  uint64
+	bytes   int64
+	// The wall time and size of the latest and largest runs, and the
+	// total wall time of all of them.
+	lastDuration  time.Duration
+	maxDuration   time.Duration
+	totalDuration time.Duration
+	lastBytes     int64
+	maxBytes      int64
+	// paths are the latest maxPaths files written, in the order they
+	// were first written.
+	paths []string
+	seen  map[string]bool
+}
+
+// How many of the files an action wrote are remembered for its
+// summary, so that actions writing a new file each run don't grow
+// without bound.
+const maxPaths = 1000
+
+// Records the outcome of a run.
+func (s *runStats) record(info RunInfo, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastRun = info.Start
+	s.lastErr = err
+	s.runs++
+	s.bytes += info.Bytes
+	if err != nil {
+		s.errors++
+	}
+
+	s.lastDuration, s.lastBytes = info.Duration, info.Bytes
+	s.totalDuration += info.Duration
+	if info.Duration > s.maxDuration {
+		s.maxDuration = info.Duration
+	}
+	if info.Bytes > s.maxBytes {
+		s.maxBytes = info.Bytes
+	}
+
+	if info.Path != "" && !s.seen[info.Path] {
+		if s.seen == nil {
+			s.seen = make(map[string]bool)
+		}
+
+		s.seen[info.Path] = true
+		if len(s.paths) < maxPaths {
+			s.paths = append(s.paths, info.Path)
+			return
+		}
+
+		delete(s.seen, s.paths[0])
+		copy(s.paths, s.paths[1:])
+		s.paths[len(s.paths)-1] = info.Path
+	}
+}
+
+// Returns the start time and error of the most recent run.
+func (s *runStats) last() (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.lastRun, s.lastErr
+}
+
+// A Summary describes everything the actions of a Swat did while it
+// ran, as written by SummaryTo.
+type Summary struct {
+	// The version of the summary's format; see SchemaVersion.
+	SchemaVersion int
+	Actions       []ActionSummary
+}
+
+// An ActionSummary describes everything an action did while a Swat
+// ran it.
+type ActionSummary struct {
+	// The action's description, as given by Describe.
+	Action string
+	Runs   uint64
+	Errors uint64
+	// The total number of bytes written, and the total wall time of
+	// the runs.
+	Bytes     int64
+	Duration  time.Duration
+	LastRun   time.Time `json:",omitempty"`
+	LastError string    `json:",omitempty"`
+	// The files written, if any, or the latest thousand of them.
+	Paths []string `json:",omitempty"`
+}
+
+func (s *runStats) summary() ActionSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sum := ActionSummary{
+		Runs:     s.runs,
+		Errors:   s.errors,
+		Bytes:    s.bytes,
+		Duration: s.totalDuration,
+		LastRun:  s.lastRun,
+		Paths:    append([]string(nil), s.paths...),
+	}
+	if s.lastErr != nil {
+		sum.LastError = s.lastErr.Error()
+	}
+
+	return sum
+}
+
+// An ActionStatus describes the state of an action, and what its runs
+// have cost so far, so that operators can check that a schedule is
+// cheap enough for production. Durations are wall time, including
+// writing the output.
+type ActionStatus struct {
+	Running bool
+	// The time of the next scheduled run, or zero if there is none.
+	NextRun time.Time
+	// The number of runs, of failed runs, and of runs which were
+	// skipped, as counted by Skipped.
+	Runs    uint64
+	Errors  uint64
+	Skipped uint64
+	// The start and error of the latest run.
+	LastRun   time.Time
+	LastError error
+	// The wall time of the latest and longest runs, and of all of them.
+	LastDuration  time.Duration
+	MaxDuration   time.Duration
+	TotalDuration time.Duration
+	// The bytes written by the latest and largest runs, and by all of
+	// them.
+	LastBytes  int64
+	MaxBytes   int64
+	TotalBytes int64
+}
+
+func (s *runStats) status() ActionStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return ActionStatus{
+		Runs:          s.runs,
+		Errors:        s.errors,
+		LastRun:       s.lastRun,
+		LastError:     s.lastErr,
+		LastDuration:  s.lastDuration,
+		MaxDuration:   s.maxDuration,
+		TotalDuration: s.totalDuration,
+		LastBytes:     s.lastBytes,
+		MaxBytes:      s.maxBytes,
+		TotalBytes:    s.bytes,
+	}
+}
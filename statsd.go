@@ -0,0 +1,95 @@
+package profile
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// StatsD emits metrics about runs to a StatsD server over UDP, for
+// graphing dump activity without Prometheus. Each run emits:
+//
+//	<prefix>.runs      a count of runs
+//	<prefix>.errors    a count of failed runs
+//	<prefix>.duration  a timing of the run, in milliseconds
+//	<prefix>.bytes     a count of the bytes written
+//
+// The action's name is part of each metric's name, as in
+// "swat.heap.runs", or with DogStatsD, a tag, as in "swat.runs" tagged
+// "action:heap". Metrics are sent on a best-effort basis, so a StatsD
+// server which is down doesn't affect the actions.
+type StatsD struct {
+	conn   net.Conn
+	prefix string
+	tags   bool
+}
+
+// Creates a client which emits metrics to the StatsD server at the
+// address, like "localhost:8125", prefixed with "swat".
+func NewStatsD(addr string) (*StatsD, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to statsd: %w", err)
+	}
+
+	return &StatsD{conn: conn, prefix: "swat"}, nil
+}
+
+// Sets the prefix of the metrics' names.
+func (s *StatsD) Prefix(prefix string) *StatsD {
+	s.prefix = prefix
+	return s
+}
+
+// Tags metrics with the action's name in the DogStatsD format, rather
+// than including it in their names.
+func (s *StatsD) DogStatsD() *StatsD {
+	s.tags = true
+	return s
+}
+
+// Closes the connection to the server.
+func (s *StatsD) Close() error {
+	return s.conn.Close()
+}
+
+// Sends the metrics for a run in a single packet.
+func (s *StatsD) emit(info RunInfo, err error) {
+	prefix, suffix := s.prefix+"."+sanitizeMetric(info.Name)+".", ""
+	if s.tags {
+		prefix, suffix = s.prefix+".", "|#action:"+info.Name
+	}
+
+	failed := 0
+	if err != nil {
+		failed = 1
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%sruns:1|c%s\n", prefix, suffix)
+	fmt.Fprintf(&b, "%serrors:%d|c%s\n", prefix, failed, suffix)
+	fmt.Fprintf(&b, "%sduration:%s|ms%s\n", prefix,
+		strconv.FormatFloat(float64(info.Duration.Microseconds())/1000, 'f', -1, 64), suffix)
+	fmt.Fprintf(&b, "%sbytes:%d|c%s", prefix, info.Bytes, suffix)
+	s.conn.Write([]byte(b.String()))
+}
+
+// Replaces the characters that StatsD treats specially in names.
+func sanitizeMetric(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '|', '@', '#', ' ', '\n':
+			return '_'
+		}
+
+		return r
+	}, name)
+}
+
+// `EmitStatsD` emits metrics for every run of the action, whatever
+// triggered it, to the StatsD server. See StatsD for the metrics.
+func (b *BaseAction) EmitStatsD(s *StatsD) *BaseAction {
+	b.statsd = s
+	return b
+}
@@ -0,0 +1,42 @@
+package profile
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestStatsDEmitsRunMetrics(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	s, err := NewStatsD(conn.LocalAddr().String())
+	assert.Nil(t, err)
+	defer s.Close()
+
+	read := func() []string {
+		buf := make([]byte, 1024)
+		n, _, err := conn.ReadFrom(buf)
+		assert.Nil(t, err)
+		return strings.Split(string(buf[:n]), "\n")
+	}
+
+	assert.Nil(t, newOutputAction("heap", "dump").EmitStatsD(s).Trigger(context.Background()))
+	lines := read()
+	assert.Equal(t, "swat.heap.runs:1|c", lines[0])
+	assert.Equal(t, "swat.heap.errors:0|c", lines[1])
+	assert.True(t, strings.HasPrefix(lines[2], "swat.heap.duration:"))
+	assert.Equal(t, "swat.heap.bytes:4|c", lines[3])
+
+	s.DogStatsD()
+	failing := NewAction(func(io.Writer) error { return errors.New("broken") }).Name("heap").EmitStatsD(s)
+	assert.NotNil(t, failing.Trigger(context.Background()))
+	lines = read()
+	assert.Equal(t, "swat.runs:1|c|#action:heap", lines[0])
+	assert.Equal(t, "swat.errors:1|c|#action:heap", lines[1])
+}
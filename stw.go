@@ -0,0 +1,71 @@
+package profile
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// The slots shared by every action which stops the world, such as
+// heap and goroutine profiles. It's replaced by LimitConcurrentDumps.
+var stw = struct {
+	sync.Mutex
+	slots chan struct{}
+}{slots: make(chan struct{}, 1)}
+
+// Waits for a free slot to stop the world in, and returns a function
+// which frees it again.
+func acquireSTW() (release func()) {
+	stw.Lock()
+	slots := stw.slots
+	stw.Unlock()
+
+	slots <- struct{}{}
+	return func() { <-slots }
+}
+
+// Returns whether writing the named pprof profile stops the world.
+// Others, such as block, mutex, threadcreate and custom profiles, are
+// written straight to their target.
+func stopsTheWorld(profile string) bool {
+	switch profile {
+	case "goroutine", "heap", "allocs":
+		return true
+	default:
+		return false
+	}
+}
+
+// Wraps an action's function, so that it only runs while it holds a
+// slot to stop the world in. The output is captured in memory, and
+// written out once the slot is freed, so that slow targets don't hold
+// up other dumps.
+func stoppingTheWorld(fn func(io.Writer) error) func(io.Writer) error {
+	return func(w io.Writer) error {
+		release := acquireSTW()
+		return buffered(func(buf io.Writer) error {
+			defer release()
+			return fn(buf)
+		}, func(buf *bytes.Buffer) error {
+			_, err := buf.WriteTo(w)
+			return err
+		})
+	}
+}
+
+// Limits how many of Swat's actions which stop the world, such as
+// heap, goroutine, and memory statistics dumps, can collect at once,
+// across every action in the process. By default only one can, so
+// that Swat never stacks up stop-the-world pauses, and others wait
+// their turn. Dumps already waiting or running keep the old limit.
+func LimitConcurrentDumps(n int) error {
+	if n <= 0 {
+		return fmt.Errorf("%w: 'LimitConcurrentDumps' requires a positive count", ErrInvalidOption)
+	}
+
+	stw.Lock()
+	stw.slots = make(chan struct{}, n)
+	stw.Unlock()
+	return nil
+}
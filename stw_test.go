@@ -0,0 +1,100 @@
+package profile
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStoppingTheWorldNeverOverlaps(t *testing.T) {
+	var running, most int32
+	fn := stoppingTheWorld(func(io.Writer) error {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&most)
+			if n <= m || atomic.CompareAndSwapInt32(&most, m, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return nil
+	})
+
+	wg := new(sync.WaitGroup)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn(io.Discard)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), most)
+}
+
+func TestLimitConcurrentDumps(t *testing.T) {
+	assert.True(t, errors.Is(LimitConcurrentDumps(0), ErrInvalidOption))
+	assert.Nil(t, LimitConcurrentDumps(2))
+	defer LimitConcurrentDumps(1)
+
+	release := acquireSTW()
+	acquireSTW()()
+	release()
+}
+
+type blockingWriter struct{ unblock chan struct{} }
+
+func (b blockingWriter) Write(p []byte) (int, error) {
+	<-b.unblock
+	return len(p), nil
+}
+
+func TestStoppingTheWorldFreesSlotBeforeWriting(t *testing.T) {
+	fn := stoppingTheWorld(func(w io.Writer) error {
+		_, err := w.Write([]byte("dump"))
+		return err
+	})
+
+	w := blockingWriter{make(chan struct{})}
+	done := make(chan error)
+	go func() { done <- fn(w) }()
+
+	// Another dump can stop the world while the first is written.
+	other := make(chan error)
+	go func() { other <- fn(io.Discard) }()
+	select {
+	case err := <-other:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("slot was held while writing")
+	}
+
+	close(w.unblock)
+	assert.Nil(t, <-done)
+}
+
+func TestOnlyStoppingProfilesWaitForSlot(t *testing.T) {
+	assert.True(t, stopsTheWorld("goroutine"))
+	assert.True(t, stopsTheWorld("heap"))
+	assert.True(t, stopsTheWorld("allocs"))
+	assert.False(t, stopsTheWorld("block"))
+	assert.False(t, stopsTheWorld("mutex"))
+
+	// A block profile is taken while the slot is held.
+	release := acquireSTW()
+	defer release()
+	done := make(chan error)
+	go func() { done <- DumpPProfLookup("block", 1).ToWriter(io.Discard).Trigger(context.Background()) }()
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("block profile waited for the slot")
+	}
+}
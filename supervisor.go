@@ -0,0 +1,92 @@
+package profile
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// How long to wait before restarting a trigger which panicked, so
+// that one which panics every time doesn't spin.
+var restartDelay = time.Second
+
+// The triggers whose loops have stopped before they were ended, for
+// Healthy: either they're waiting to be restarted after a panic, or
+// they returned an error, and won't fire again. Triggers are forgotten
+// once they're ended.
+var stalled = struct {
+	sync.Mutex
+	triggers map[fmt.Stringer]bool
+}{triggers: map[fmt.Stringer]bool{}}
+
+func setStalled(t fmt.Stringer, stopped bool) {
+	stalled.Lock()
+	defer stalled.Unlock()
+
+	if stopped {
+		stalled.triggers[t] = true
+	} else {
+		delete(stalled.triggers, t)
+	}
+}
+
+func isStalled(t fmt.Stringer) bool {
+	stalled.Lock()
+	defer stalled.Unlock()
+
+	return stalled.triggers[t]
+}
+
+// Runs a trigger's loop in a goroutine, closing done once it returns.
+// If the loop panics, such as when the action or a condition does,
+// the panic is logged and the loop is restarted after a delay, so
+// that the trigger doesn't silently stop firing. It's not restarted
+// once closer is closed. The loop returns nil once it has finished,
+// such as when it's ended or the channel it reads is closed, and an
+// error if it stopped unexpectedly, which is logged and reported by
+// Healthy.
+func supervise(t fmt.Stringer, closer <-chan struct{}, done chan struct{}, loop func() error) {
+	go func() {
+		defer close(done)
+
+		for {
+			clean, err := exitedCleanly(loop)
+			if clean {
+				if err != nil {
+					log.Printf("Swat Error: trigger %q stopped unexpectedly: %s", t, err)
+					setStalled(t, true)
+				}
+
+				return
+			}
+
+			log.Printf("Swat Error: trigger %q stopped unexpectedly; restarting it in %s", t, restartDelay)
+			setStalled(t, true)
+
+			timer := time.NewTimer(restartDelay)
+			select {
+			case <-closer:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			setStalled(t, false)
+		}
+	}()
+}
+
+// Runs the loop, returning false if it panicked, and otherwise its
+// error.
+func exitedCleanly(loop func() error) (clean bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Swat Error: panic in trigger: %v\n%s", r, debug.Stack())
+			clean = false
+		}
+	}()
+
+	return true, loop()
+}
@@ -1,7 +1,13 @@
 package profile
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
 	"sync"
+	"sync/atomic"
 )
 
 // An "action" is the basic unit of Swat. It is started and should
@@ -12,10 +18,29 @@ type Action interface {
 	Start() error
 	// End should signal the action to stop, and block until it does.
 	End()
+	// Trigger should run the action once, synchronously, and return
+	// the error it produced, if any.
+	Trigger(ctx context.Context) error
 }
 
 type Swat struct {
-	actions []Action
+	actions     []Action
+	limiter     *rateLimiter
+	summaryPath string
+	pauser      pauser
+	reporter    ErrorReporter
+	manifest    *manifestFile
+	// lastErr holds the first invalid option, which Boot returns.
+	lastErr error
+	// expvar is set by PublishExpvar, and expvarKeys holds the names
+	// the actions were published under.
+	expvar     bool
+	expvarKeys []string
+}
+
+// Actions which can summarize their runs, as BaseAction does.
+type summarized interface {
+	Summary() ActionSummary
 }
 
 // Creates a Swat with the given actions, and boots them
@@ -25,16 +50,100 @@ func Start(actions ...Action) (*Swat, error) {
 	return s, s.Boot(actions)
 }
 
+// Limits the total number of runs across all actions, whatever
+// triggered them, to n per minute. This is a safety net against
+// misconfigured schedules and thresholds in production. It must be
+// called before the actions are booted, and the limit must be
+// positive.
+func (s *Swat) LimitRate(perMinute int) *Swat {
+	if s.lastErr == nil && perMinute < 1 {
+		s.lastErr = fmt.Errorf("%w: 'LimitRate' requires a positive limit", ErrInvalidOption)
+	}
+
+	s.limiter = newRateLimiter(perMinute)
+	return s
+}
+
+// Writes a summary of every action to the file when the Swat ends, as
+// a Summary in JSON, listing each action's runs, errors, bytes
+// written, and the paths of the files it wrote, so that whoever is
+// responding to an incident knows exactly what artifacts exist once
+// the tooling is shut down.
+func (s *Swat) SummaryTo(path string) *Swat {
+	s.summaryPath = path
+	return s
+}
+
+// Keeps signals triggering runs while the Swat is paused, so that an
+// operator can still ask for a dump by hand. It must be called before
+// the actions are booted.
+func (s *Swat) HonorSignalsWhilePaused() *Swat {
+	s.pauser.signals = true
+	return s
+}
+
+// Pauses all actions, so that their schedules and conditions no longer
+// trigger runs until Resume is called, for use during deploys or data
+// migrations when dumps would just add noise. Schedules keep ticking
+// while paused, so runs which fall due are skipped rather than delayed.
+// Signals are ignored too, unless HonorSignalsWhilePaused was called,
+// and manual triggers always run.
+func (s *Swat) Pause() {
+	atomic.StoreInt32(&s.pauser.paused, 1)
+}
+
+// Resumes the actions after Pause.
+func (s *Swat) Resume() {
+	atomic.StoreInt32(&s.pauser.paused, 0)
+}
+
+// Returns whether the Swat is paused.
+func (s *Swat) Paused() bool {
+	return s.pauser.isPaused()
+}
+
 // Starts all associated actions. If an action's Start method returns
 // an error, then no actions are run.
 func (s *Swat) Boot(actions []Action) error {
+	if s.lastErr != nil {
+		return s.lastErr
+	}
+
+	if s.manifest != nil {
+		s.manifest.mu.Lock()
+		err := s.manifest.write()
+		s.manifest.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+
 	for _, action := range actions {
+		if r, ok := action.(rateLimited); ok && s.limiter != nil {
+			r.useLimiter(s.limiter)
+		}
+
+		if r, ok := action.(reporting); ok && s.reporter != nil {
+			r.useReporter(s.reporter)
+		}
+
+		if m, ok := action.(manifested); ok && s.manifest != nil {
+			m.useManifest(s.manifest)
+		}
+
+		if p, ok := action.(pausable); ok {
+			p.usePauser(&s.pauser)
+		}
+
 		if err := action.Start(); err != nil {
 			s.End()
 			return err
 		}
 
 		s.actions = append(s.actions, action)
+		if s.expvar {
+			s.publish(action)
+		}
 	}
 
 	return nil
@@ -52,4 +161,27 @@ func (s *Swat) End() {
 	}
 
 	wg.Wait()
+	s.unpublish()
+
+	if s.summaryPath != "" {
+		if err := s.writeSummary(); err != nil {
+			log.Printf("Swat Error: writing summary: %s", err)
+		}
+	}
+}
+
+func (s *Swat) writeSummary() error {
+	summary := Summary{SchemaVersion: SchemaVersion, Actions: []ActionSummary{}}
+	for _, action := range s.actions {
+		if a, ok := action.(summarized); ok {
+			summary.Actions = append(summary.Actions, a.Summary())
+		}
+	}
+
+	data, err := json.MarshalIndent(&summary, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(s.summaryPath, append(data, '\n'), 0666)
 }
@@ -0,0 +1,165 @@
+package profile
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSummaryWrittenOnEnd(t *testing.T) {
+	dir := t.TempDir()
+	ok := newOutputAction("heap", "dump").Every(time.Hour).After(time.Hour).ToDir(dir)
+	failing := NewAction(func(w io.Writer) error { return errors.New("broken") }).
+		Name("broken").OnError(func(error) {})
+
+	s, err := Start(ok, failing)
+	assert.Nil(t, err)
+	s.SummaryTo(filepath.Join(dir, "summary.json"))
+	assert.Nil(t, ok.Trigger(context.Background()))
+	assert.Nil(t, ok.Trigger(context.Background()))
+	assert.NotNil(t, failing.Trigger(context.Background()))
+	s.End()
+
+	data, err := os.ReadFile(filepath.Join(dir, "summary.json"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"SchemaVersion": 1`)
+	var summary Summary
+	assert.Nil(t, json.Unmarshal(data, &summary))
+	assert.Equal(t, SchemaVersion, summary.SchemaVersion)
+	summaries := summary.Actions
+	if assert.Len(t, summaries, 2) {
+		assert.Equal(t, uint64(2), summaries[0].Runs)
+		assert.Equal(t, int64(8), summaries[0].Bytes)
+		assert.Len(t, summaries[0].Paths, 2)
+		assert.Contains(t, summaries[0].Action, "heap after 1h0m0s")
+
+		assert.Equal(t, uint64(1), summaries[1].Errors)
+		assert.Equal(t, "broken", summaries[1].LastError)
+	}
+}
+
+func TestManifestListsArtifacts(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "manifest.json")
+	read := func() Manifest {
+		var m Manifest
+		data, err := os.ReadFile(path)
+		assert.Nil(t, err)
+		assert.Nil(t, json.Unmarshal(data, &m))
+		return m
+	}
+
+	heap := newOutputAction("heap", "dump").ToDir(dir)
+	failing := NewAction(func(w io.Writer) error { return errors.New("broken") }).
+		Name("broken").OnError(func(error) {})
+	s := new(Swat).ManifestTo(path)
+	assert.Nil(t, s.Boot([]Action{heap, failing}))
+	defer s.End()
+
+	m := read()
+	assert.Equal(t, SchemaVersion, m.SchemaVersion)
+	assert.NotZero(t, m.Started)
+	assert.Empty(t, m.Artifacts)
+
+	assert.Nil(t, heap.Trigger(context.Background()))
+	assert.NotNil(t, failing.Trigger(context.Background()))
+	m = read()
+	if assert.Len(t, m.Artifacts, 1) {
+		a := m.Artifacts[0]
+		assert.Equal(t, "heap", a.Name)
+		assert.Equal(t, SourceManual, a.Source)
+		assert.EqualValues(t, 4, a.Bytes)
+		assert.Equal(t, dir, filepath.Dir(a.Path))
+	}
+
+	// Besides the dump, there's just the manifest; its temporary
+	// files are renamed into place.
+	files, _ := os.ReadDir(dir)
+	assert.Len(t, files, 2)
+}
+
+func TestActorRunsUntilInterrupted(t *testing.T) {
+	a, runs := newCountingAction()
+	a.Every(time.Millisecond)
+	execute, interrupt := Actor(a)
+
+	done := make(chan error)
+	go func() { done <- execute() }()
+	time.Sleep(10 * time.Millisecond)
+	interrupt(nil)
+	assert.Nil(t, <-done)
+
+	n := atomic.LoadInt32(runs)
+	assert.NotZero(t, n)
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(t, n, atomic.LoadInt32(runs))
+}
+
+func TestRunReportsStartErrors(t *testing.T) {
+	a, _ := newCountingAction()
+	a.At(time.Now()).After(time.Second)
+	assert.Equal(t, ErrConflictingStart, Run(context.Background(), a))
+}
+
+func TestPauseSkipsScheduledRuns(t *testing.T) {
+	a, runs := newCountingAction()
+	a.Every(time.Millisecond)
+
+	// Pausing before booting means no run can slip in before the pause.
+	s := new(Swat)
+	s.Pause()
+	assert.True(t, s.Paused())
+	assert.Nil(t, s.Boot([]Action{a}))
+	defer s.End()
+
+	time.Sleep(10 * time.Millisecond)
+	assert.Zero(t, atomic.LoadInt32(runs))
+
+	// Manual triggers still run while paused.
+	assert.Nil(t, a.Trigger(context.Background()))
+	assert.Equal(t, int32(1), atomic.LoadInt32(runs))
+
+	s.Resume()
+	assert.False(t, s.Paused())
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(runs) > 1 }, 5*time.Second, time.Millisecond)
+}
+
+func TestSelectByLabel(t *testing.T) {
+	payments, paymentRuns := newCountingAction()
+	payments.Label("team", "payments").Every(time.Millisecond)
+	search, searchRuns := newCountingAction()
+	search.Label("team", "search").Every(time.Millisecond)
+	unlabeled, _ := newCountingAction()
+	unlabeled.Every(time.Hour).After(time.Hour)
+	s, err := Start(payments, search, unlabeled)
+	assert.Nil(t, err)
+	defer s.End()
+
+	sel := s.Select(map[string]string{"team": "payments"})
+	assert.Equal(t, []Action{payments}, sel.Actions())
+	// Empty selectors select unlabeled actions too.
+	assert.Len(t, s.Select(nil).Actions(), 3)
+
+	sel.Pause()
+	time.Sleep(5 * time.Millisecond)
+	paused, running := atomic.LoadInt32(paymentRuns), atomic.LoadInt32(searchRuns)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, paused, atomic.LoadInt32(paymentRuns))
+	assert.True(t, atomic.LoadInt32(searchRuns) > running)
+
+	assert.Nil(t, sel.Trigger(context.Background()))
+	assert.Equal(t, paused+1, atomic.LoadInt32(paymentRuns))
+	sel.End()
+}
+
+func TestLabelRequiresKey(t *testing.T) {
+	a, _ := newCountingAction()
+	assert.True(t, errors.Is(a.Label("", "x").Start(), ErrInvalidOption))
+}
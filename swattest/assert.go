@@ -0,0 +1,85 @@
+package swattest
+
+import (
+	"testing"
+	"time"
+)
+
+// Fails the test unless the sink has recorded exactly n runs. Returns
+// whether the assertion held.
+func AssertRuns(t testing.TB, s *Sink, n int) bool {
+	t.Helper()
+
+	if got := s.Count(); got != n {
+		t.Errorf("expected %d runs, but got %d", n, got)
+		return false
+	}
+
+	return true
+}
+
+// Fails the test unless the runs recorded by the sink started at
+// exactly the times, as told by the clock the actions run by. Returns
+// whether the assertion held.
+func AssertRunTimes(t testing.TB, s *Sink, times ...time.Time) bool {
+	t.Helper()
+
+	runs := s.Runs()
+	if len(runs) != len(times) {
+		t.Errorf("expected %d runs, but got %d", len(times), len(runs))
+		return false
+	}
+
+	ok := true
+	for i, run := range runs {
+		if !run.Start.Equal(times[i]) {
+			t.Errorf("expected run %d to start at %s, but it started at %s", i, times[i], run.Start)
+			ok = false
+		}
+	}
+
+	return ok
+}
+
+// Fails the test unless the runs recorded by the sink started exactly
+// the interval apart, as told by the clock the actions run by. Returns
+// whether the assertion held.
+func AssertEvery(t testing.TB, s *Sink, interval time.Duration) bool {
+	t.Helper()
+
+	runs, ok := s.Runs(), true
+	for i := 1; i < len(runs); i++ {
+		if gap := runs[i].Start.Sub(runs[i-1].Start); gap != interval {
+			t.Errorf("expected runs every %s, but runs %d and %d were %s apart", interval, i-1, i, gap)
+			ok = false
+		}
+	}
+
+	return ok
+}
+
+// Waits for the sink to have recorded at least n runs, for actions
+// whose runs aren't done when Advance or Signal return, such as Async
+// ones or ones with conditions, and fails the test if it hasn't within
+// the timeout. Returns whether it had.
+func WaitForRuns(t testing.TB, s *Sink, n int, timeout time.Duration) bool {
+	t.Helper()
+
+	deadline := time.After(timeout)
+	for {
+		s.mu.Lock()
+		got, changed := len(s.runs), s.changed
+		s.mu.Unlock()
+
+		if got >= n {
+			return true
+		}
+
+		select {
+		case <-changed:
+		case <-deadline:
+			t.Errorf("expected %d runs within %s, but got %d", n, timeout, got)
+			return false
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package swattest
+
+import (
+	profile "github.com/WatchBeam/swat"
+	"sync"
+	"time"
+)
+
+// How long Advance waits for the owner of a timer which fired to Reset
+// or Stop it, before moving on regardless.
+const ackTimeout = 10 * time.Second
+
+// Clock is a fake profile.Clock, whose time only moves when it's
+// advanced. Give it to actions with BaseAction.Clock.
+type Clock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*timer
+	// changed is closed and replaced whenever a timer is reset or
+	// stopped, so that Advance can wait for that.
+	changed chan struct{}
+}
+
+// Creates a clock whose time is now.
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now, changed: make(chan struct{})}
+}
+
+// Implements profile.Clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// Implements profile.Clock. Timers for durations which aren't positive
+// fire at once.
+func (c *Clock) NewTimer(d time.Duration) profile.Timer {
+	t := &timer{clock: c, ch: make(chan time.Time, 1)}
+	c.mu.Lock()
+	c.timers = append(c.timers, t)
+	c.mu.Unlock()
+
+	t.Reset(d)
+	return t
+}
+
+// Moves the time forward by d, firing every timer which falls due on
+// the way, in order, at the time it's due. After firing a timer, it
+// waits for its owner to handle it and Reset or Stop it, as actions do
+// once their runs are done, so that when Advance returns every run due
+// by then has happened.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	target := c.now.Add(d)
+	c.mu.Unlock()
+
+	for {
+		c.awaitFired()
+
+		c.mu.Lock()
+		next := c.due(target)
+		if next == nil {
+			c.now = target
+			c.mu.Unlock()
+			return
+		}
+
+		if next.at.After(c.now) {
+			c.now = next.at
+		}
+		next.fire(c.now)
+		c.mu.Unlock()
+	}
+}
+
+// Returns the earliest armed timer due by the target. The clock must
+// be locked.
+func (c *Clock) due(target time.Time) *timer {
+	var next *timer
+	for _, t := range c.timers {
+		if t.armed && !t.at.After(target) && (next == nil || t.at.Before(next.at)) {
+			next = t
+		}
+	}
+
+	return next
+}
+
+// Waits for the owners of timers which have fired to Reset or Stop
+// them.
+func (c *Clock) awaitFired() {
+	deadline := time.After(ackTimeout)
+	for {
+		c.mu.Lock()
+		pending, changed := false, c.changed
+		for _, t := range c.timers {
+			pending = pending || t.fired
+		}
+		c.mu.Unlock()
+
+		if !pending {
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-deadline:
+			return
+		}
+	}
+}
+
+// Wakes up Advance. The clock must be locked.
+func (c *Clock) notify() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// A timer made by a Clock. It's armed while waiting to fire, and fired
+// once it has until its owner resets or stops it.
+type timer struct {
+	clock *Clock
+	ch    chan time.Time
+	at    time.Time
+	armed bool
+	fired bool
+}
+
+func (t *timer) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *timer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	wasArmed := t.armed
+	t.at, t.armed, t.fired = t.clock.now.Add(d), true, false
+	if d <= 0 {
+		t.fire(t.clock.now)
+	}
+
+	t.clock.notify()
+	return wasArmed
+}
+
+func (t *timer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	wasArmed := t.armed
+	t.armed, t.fired = false, false
+	t.clock.notify()
+	return wasArmed
+}
+
+// Sends the time on the timer's channel. The clock must be locked.
+func (t *timer) fire(now time.Time) {
+	t.armed, t.fired = false, true
+	select {
+	case t.ch <- now:
+	default:
+	}
+}
@@ -0,0 +1,14 @@
+// Package swattest helps test Swat configurations without real sleeps
+// or real files. Actions are run by a fake Clock, write to an in-memory
+// Sink, and are sent signals with Signal:
+//
+//	clock := swattest.NewClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+//	sink := swattest.NewSink()
+//	a := profile.DumpHeap().Every(time.Minute).Clock(clock).To(sink)
+//	a.Start()
+//	defer a.End()
+//
+//	clock.Advance(3 * time.Minute)
+//	swattest.AssertRuns(t, sink, 4)
+//	swattest.AssertEvery(t, sink, time.Minute)
+package swattest
@@ -0,0 +1,22 @@
+package swattest
+
+import (
+	profile "github.com/WatchBeam/swat"
+	"os"
+	"testing"
+)
+
+// Delivers the signal to each of the actions as if the process had
+// received it, returning once their runs are done. Unlike sending a
+// real signal, it's synchronous, works on every platform, and doesn't
+// reach other listeners in the process. The test fails if any of the
+// actions isn't running or listening for the signal.
+func Signal(t testing.TB, sig os.Signal, actions ...*profile.BaseAction) {
+	t.Helper()
+
+	for _, a := range actions {
+		if !a.Signal(sig) {
+			t.Errorf("%s isn't listening for %s", a.Describe(), sig)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package swattest
+
+import (
+	"bytes"
+	profile "github.com/WatchBeam/swat"
+	"io"
+	"sync"
+)
+
+// A Run is the output of one run recorded by a Sink.
+type Run struct {
+	profile.RunInfo
+	Data []byte
+}
+
+// Sink is a profile.Sink which records the output of each run in
+// memory, so that tests can check what was dumped and when.
+type Sink struct {
+	mu   sync.Mutex
+	runs []Run
+	// changed is closed and replaced whenever a run is recorded, so
+	// that WaitForRuns can wait for runs.
+	changed chan struct{}
+}
+
+// Creates an empty sink.
+func NewSink() *Sink {
+	return &Sink{changed: make(chan struct{})}
+}
+
+func (s *Sink) String() string {
+	return "memory"
+}
+
+// Implements profile.Sink. The run is recorded once the writer is
+// closed.
+func (s *Sink) Open(info profile.RunInfo) (io.WriteCloser, error) {
+	return &sinkWriter{sink: s, info: info}, nil
+}
+
+// Returns the runs recorded so far, in the order they finished.
+func (s *Sink) Runs() []Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Run(nil), s.runs...)
+}
+
+// Returns the number of runs recorded so far.
+func (s *Sink) Count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.runs)
+}
+
+// Returns the data written by the latest run, or nil if there has
+// been none.
+func (s *Sink) Last() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.runs) == 0 {
+		return nil
+	}
+
+	return s.runs[len(s.runs)-1].Data
+}
+
+// Forgets the runs recorded so far.
+func (s *Sink) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.runs = nil
+}
+
+func (s *Sink) record(run Run) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.runs = append(s.runs, run)
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+type sinkWriter struct {
+	bytes.Buffer
+	sink *Sink
+	info profile.RunInfo
+}
+
+func (w *sinkWriter) Close() error {
+	w.info.Bytes = int64(w.Len())
+	w.sink.record(Run{RunInfo: w.info, Data: w.Bytes()})
+	return nil
+}
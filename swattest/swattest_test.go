@@ -0,0 +1,80 @@
+package swattest
+
+import (
+	"context"
+	profile "github.com/WatchBeam/swat"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func newAction() *profile.BaseAction {
+	return profile.NewAction(func(w io.Writer) error {
+		_, err := io.WriteString(w, "dump")
+		return err
+	})
+}
+
+func TestClockRunsSchedules(t *testing.T) {
+	clock, sink := NewClock(epoch), NewSink()
+	a := newAction().Every(time.Minute).Clock(clock).To(sink)
+	assert.Nil(t, a.Start())
+	defer a.End()
+
+	clock.Advance(3 * time.Minute)
+	AssertRunTimes(t, sink, epoch, epoch.Add(time.Minute), epoch.Add(2*time.Minute), epoch.Add(3*time.Minute))
+	AssertEvery(t, sink, time.Minute)
+	assert.Equal(t, "dump", string(sink.Last()))
+	assert.True(t, epoch.Add(4*time.Minute).Equal(a.NextRun()))
+
+	// Time between runs doesn't run the action.
+	clock.Advance(30 * time.Second)
+	AssertRuns(t, sink, 4)
+	assert.Equal(t, epoch.Add(3*time.Minute+30*time.Second), clock.Now())
+}
+
+func TestClockRunsLimitedSchedules(t *testing.T) {
+	clock, sink := NewClock(epoch), NewSink()
+	a := newAction().After(time.Hour).Every(10 * time.Minute).For(30 * time.Minute).Clock(clock).To(sink)
+	assert.Nil(t, a.Start())
+	defer a.End()
+
+	clock.Advance(59 * time.Minute)
+	AssertRuns(t, sink, 0)
+
+	clock.Advance(2 * time.Hour)
+	start := epoch.Add(time.Hour)
+	AssertRunTimes(t, sink, start, start.Add(10*time.Minute), start.Add(20*time.Minute))
+	assert.True(t, a.NextRun().IsZero())
+}
+
+func TestSignal(t *testing.T) {
+	clock, sink := NewClock(epoch), NewSink()
+	a := newAction().OnSignal(os.Interrupt).Clock(clock).To(sink)
+	assert.False(t, a.Signal(os.Interrupt))
+
+	assert.Nil(t, a.Start())
+	defer a.End()
+	Signal(t, os.Interrupt, a)
+	Signal(t, os.Interrupt, a)
+	AssertRunTimes(t, sink, epoch, epoch)
+	assert.False(t, a.Signal(os.Kill))
+}
+
+func TestWaitForRuns(t *testing.T) {
+	sink := NewSink()
+	a := newAction().Async(1).To(sink)
+	assert.Nil(t, a.Start())
+	defer a.End()
+
+	assert.Nil(t, a.Trigger(context.Background()))
+	assert.True(t, WaitForRuns(t, sink, 1, 5*time.Second))
+	assert.Equal(t, int64(4), sink.Runs()[0].Bytes)
+
+	sink.Reset()
+	AssertRuns(t, sink, 0)
+}
@@ -1,28 +1,242 @@
 package profile
 
 import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
 	"io"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
 )
 
+// The default template for files written by ToDir.
+const dirTemplate = "{{.Name}}-{{.Time}}.{{.Ext}}"
+
+// The layout of times in file names. It avoids colons, which aren't
+// allowed in file names on Windows.
+const fileTimeLayout = "2006-01-02T15-04-05.000"
+
+// The values available to file templates.
+type pathVars struct {
+	// The name of the action, or "action" if it has none.
+	Name string
+	// The extension of the action's output, such as "txt".
+	Ext string
+	// The time of the run, formatted for use in file names.
+	Time string
+	// The number of the run, and why it happened.
+	Index  uint64
+	Source TriggerSource
+}
+
+func newPathVars(name, ext string, at time.Time) pathVars {
+	return pathVars{Name: name, Ext: ext, Time: at.Format(fileTimeLayout)}
+}
+
+// Returns the variables for the paths of the run's output.
+func runPathVars(info RunInfo) pathVars {
+	vars := newPathVars(info.Name, info.Ext, info.Start)
+	vars.Index, vars.Source = info.Index, info.Source
+	return vars
+}
+
 // Targeter is embedded and used to set the output for actions.
 type targeter struct {
-	writer io.Writer
-	closer io.Closer
+	writer   io.Writer
+	closer   io.Closer
+	file     string
+	template *template.Template
+	sink     Sink
+	// hashNames and sidecars are set by HashNames and Checksums.
+	hashNames bool
+	sidecars  bool
+	// synced is set by Synced.
+	synced bool
+	// pipe is the write side of the pipe returned by ToPipe.
+	pipe *io.PipeWriter
+	// counter is reused by each run, so that runs don't allocate. Runs
+	// through one targeter never overlap, since each runner has its own
+	// copy.
+	counter countingWriter
+	// created is set once the file has been created, so that it's
+	// appended to rather than truncated if the action is restarted.
+	created bool
 }
 
 // Writes the output of the action to the writer.
 func (t *targeter) ToWriter(w io.Writer) {
 	t.writer = w
+	t.file = ""
+	t.template = nil
+	t.sink = nil
+	t.pipe = nil
+}
+
+// Opens a writer from the sink for each run.
+func (t *targeter) To(s Sink) {
+	t.ToWriter(nil)
+	t.sink = s
+}
+
+// Writes the output of the action into a pipe, and returns its read
+// side.
+func (t *targeter) ToPipe() io.Reader {
+	r, w := io.Pipe()
+	t.ToWriter(w)
+	t.pipe = w
+	return r
 }
 
 // Writes the output of the action to the file specified by the path.
-func (t *targeter) ToFile(file string) error {
-	f, err := os.Create(file)
+// The file, and its directory, are created when the action starts.
+func (t *targeter) ToFile(file string) {
+	if file != t.file {
+		t.created = false
+	}
+
+	t.file = file
+	t.template = nil
+	t.sink = nil
+	t.pipe = nil
+}
+
+// Writes the output of each run to a new file, whose path is given
+// by executing the template. See pathVars for the available fields.
+func (t *targeter) ToFileTemplate(pattern string) error {
+	tmpl, err := template.New("path").Parse(pattern)
 	if err != nil {
 		return err
 	}
 
+	t.writer = nil
+	t.file = ""
+	t.template = tmpl
+	t.sink = nil
+	t.pipe = nil
+	return nil
+}
+
+// Writes the output of each run to a new file in the directory,
+// named after the action and the time of the run.
+func (t *targeter) ToDir(dir string) error {
+	return t.ToFileTemplate(filepath.Join(dir, dirTemplate))
+}
+
+// Describes where output is written. Templated paths are described
+// with the given variables.
+func (t *targeter) describe(vars pathVars) string {
+	switch {
+	case t.file != "":
+		return t.file
+	case t.template != nil:
+		buf := new(strings.Builder)
+		if t.template.Execute(buf, vars) != nil {
+			return t.template.Root.String()
+		}
+
+		return buf.String()
+	case t.sink != nil:
+		return describeSink(t.sink)
+	case t.pipe != nil:
+		return "pipe"
+	case t.writer == os.Stdout:
+		return "stdout"
+	case t.writer == os.Stderr:
+		return "stderr"
+	case t.writer == nil:
+		return "nowhere"
+	default:
+		return fmt.Sprintf("%T", t.writer)
+	}
+}
+
+// Checks that output can be written: that the directory templated
+// files are created in is writable, or that the sink can be written
+// to, if it can be checked. Templates are rendered with the given
+// variables.
+func (t *targeter) check(vars pathVars) error {
+	switch {
+	case t.template != nil:
+		buf := new(strings.Builder)
+		if err := t.template.Execute(buf, vars); err != nil {
+			return err
+		}
+
+		return dirWritable(filepath.Dir(buf.String()))
+	case t.sink != nil:
+		return checkSink(t.sink)
+	default:
+		return nil
+	}
+}
+
+// Returns an error if files can't be created in the directory. If it
+// doesn't exist yet, its nearest existing parent is checked instead,
+// since it's created when needed. Nothing is written, so that health
+// checks don't churn the directory.
+func dirWritable(dir string) error {
+	for {
+		info, err := os.Stat(dir)
+		if err == nil && !info.IsDir() {
+			return fmt.Errorf("%s isn't a directory", dir)
+		}
+		if err == nil {
+			return canWrite(dir, info)
+		}
+
+		parent := filepath.Dir(dir)
+		if !os.IsNotExist(err) || parent == dir {
+			return err
+		}
+		dir = parent
+	}
+}
+
+// Returns a targeter with the same target. Files are opened afresh by
+// the copy, and the pipe returned by ToPipe isn't shared, so output is
+// discarded until the copy is given another target.
+func (t *targeter) clone() *targeter {
+	c := &targeter{
+		writer:    t.writer,
+		file:      t.file,
+		template:  t.template,
+		sink:      t.sink,
+		hashNames: t.hashNames,
+		sidecars:  t.sidecars,
+		synced:    t.synced,
+	}
+
+	if t.file != "" || t.pipe != nil {
+		c.writer = nil
+	}
+
+	return c
+}
+
+func (t *targeter) start() error {
+	if t.file == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(t.file), 0777); err != nil {
+		return err
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if t.created {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+
+	f, err := os.OpenFile(t.file, flags, 0666)
+	if err != nil {
+		return err
+	}
+
+	t.created = true
 	t.writer = f
 	t.closer = f
 	return nil
@@ -31,5 +245,158 @@ func (t *targeter) ToFile(file string) error {
 func (t *targeter) end() {
 	if t.closer != nil {
 		t.closer.Close()
+		t.closer = nil
 	}
 }
+
+// Hands over the file opened by start to the caller, who must close
+// it once done with it, rather than leaving it to end.
+func (t *targeter) detach() io.Closer {
+	closer := t.closer
+	t.closer = nil
+	return closer
+}
+
+// Closes the pipe returned by ToPipe, if any. Writes blocked on a
+// reader which has stopped reading fail, and the reader sees EOF.
+func (t *targeter) closePipe() {
+	if t.pipe != nil {
+		t.pipe.Close()
+	}
+}
+
+// Opens the output of a single run to a sink or templated file, which
+// must be closed once the run is complete.
+func (t *targeter) open(info RunInfo, vars pathVars) (w io.WriteCloser, path string, err error) {
+	if t.sink != nil {
+		w, err := t.sink.Open(info)
+		return w, "", err
+	}
+
+	f, err := t.create(vars)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return f, f.Name(), nil
+}
+
+// Opens the output for a run, writes to it with fn, and closes it.
+// The path and number of bytes written are recorded in the info.
+func (t *targeter) write(info *RunInfo, vars pathVars, fn func(io.Writer) error) error {
+	// Writers which outlive the run need nothing opened or closed, so
+	// frequent runs to them don't allocate.
+	if t.sink == nil && t.template == nil {
+		w := t.writer
+		if w == nil {
+			w = io.Discard
+		}
+
+		t.counter = countingWriter{w: w}
+		err := fn(&t.counter)
+		if f, ok := w.(*os.File); ok && err == nil && t.synced && t.file != "" {
+			err = f.Sync()
+		}
+
+		info.Path = t.file
+		info.Bytes = t.counter.n
+		return err
+	}
+
+	w, path, err := t.open(*info, vars)
+	if err != nil {
+		return err
+	}
+
+	f, _ := w.(*os.File)
+
+	var sum hash.Hash
+	if t.template != nil && (t.hashNames || t.sidecars) {
+		sum = sha256.New()
+		w = teeCloser{w, sum}
+	}
+
+	t.counter = countingWriter{w: w}
+	err = fn(&t.counter)
+	if err == nil && t.synced && f != nil {
+		err = f.Sync()
+	}
+
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+
+	if err == nil && sum != nil {
+		path, err = t.checksum(path, vars, sum.Sum(nil))
+	}
+
+	// The new file's directory entry must be synced too, or the file
+	// may not survive a crash.
+	if err == nil && t.synced && f != nil {
+		syncDir(filepath.Dir(path))
+	}
+
+	info.Path = path
+	info.Bytes = t.counter.n
+	return err
+}
+
+// Creates a new file for a run from the template. If the file
+// already exists, a numeric suffix is added rather than overwriting.
+func (t *targeter) create(vars pathVars) (*os.File, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := t.template.Execute(buf, vars); err != nil {
+		return nil, err
+	}
+
+	return createUnique(buf.String(), vars.Ext)
+}
+
+// Creates a new file at the path, and any missing directories. If the
+// file already exists, a numeric suffix is added before the extension,
+// such as "pb.gz", rather than overwriting it.
+func createUnique(path, whole string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return nil, err
+	}
+
+	// Add suffixes before the whole extension, even if it has several
+	// parts like "pb.gz".
+	ext := filepath.Ext(path)
+	if strings.HasSuffix(path, "."+whole) {
+		ext = "." + whole
+	}
+	base := path[:len(path)-len(ext)]
+	for i := 1; ; i++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+		if !os.IsExist(err) {
+			return f, err
+		}
+
+		path = base + "-" + strconv.Itoa(i) + ext
+	}
+}
+
+// Syncs the directory, so that entries created or renamed in it are
+// durable. This isn't possible on every platform, such as Windows, so
+// errors are ignored.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+
+	d.Sync()
+	d.Close()
+}
+
+// Wraps a writer with a Close method that does nothing, for outputs
+// which outlive a single run.
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
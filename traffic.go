@@ -0,0 +1,141 @@
+package profile
+
+import (
+	"math"
+	"net/http"
+	"sync/atomic"
+)
+
+// TrafficStats counts the requests an HTTP server handles, and those
+// which fail, so that dumps can be triggered when traffic looks
+// anomalous. Wire it into a server with Wrap, and use its conditions,
+// RequestRateDeviates and ErrorRateDeviates, with When:
+//
+//	traffic := new(profile.TrafficStats)
+//	http.ListenAndServe(addr, traffic.Wrap(mux))
+//	profile.DumpGoroutine().When(traffic.ErrorRateDeviates(0.05), 10*time.Second)
+//
+// The zero value is ready to use, and one TrafficStats may be shared
+// by several handlers. Each condition keeps its own history, so it
+// should only be used by one action.
+type TrafficStats struct {
+	requests, errors int64
+}
+
+// How many polls the moving averages of the conditions cover, and
+// how many they must see before the average is trusted.
+const trafficWindow = 10
+
+// Wraps the handler, counting its requests. A request fails if the
+// handler responds with a 5xx status, or panics.
+func (t *TrafficStats) Wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&t.requests, 1)
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		failed := true
+		defer func() {
+			if failed || sw.status >= 500 {
+				atomic.AddInt64(&t.errors, 1)
+			}
+		}()
+
+		h.ServeHTTP(sw, r)
+		failed = false
+	})
+}
+
+// Returns the number of requests handled so far, and how many failed.
+func (t *TrafficStats) Counts() (requests, errors int64) {
+	return atomic.LoadInt64(&t.requests), atomic.LoadInt64(&t.errors)
+}
+
+// Returns a condition which holds when the number of requests since it
+// was last checked differs from its moving average by more than the
+// fraction of the average, such as 0.5 for half as many or half as
+// many again. Both spikes and drops in traffic are anomalies. Each
+// check is one sample, so the condition should be polled at a fixed
+// interval, and it doesn't hold until it's seen enough samples to have
+// an average.
+func (t *TrafficStats) RequestRateDeviates(fraction float64) func() bool {
+	var avg movingAverage
+	var last int64
+	return func() bool {
+		requests, _ := t.Counts()
+		n := float64(requests - last)
+		last = requests
+
+		mean, ok := avg.add(n)
+		return ok && math.Abs(n-mean) > fraction*mean
+	}
+}
+
+// Returns a condition which holds when the proportion of requests
+// which failed since it was last checked exceeds its moving average
+// by more than the difference, such as 0.05 for five percentage
+// points. Checks which saw no requests are ignored. Otherwise, like
+// RequestRateDeviates, each check is a sample of the average.
+func (t *TrafficStats) ErrorRateDeviates(difference float64) func() bool {
+	var avg movingAverage
+	var lastRequests, lastErrors int64
+	return func() bool {
+		requests, errors := t.Counts()
+		n, failed := requests-lastRequests, errors-lastErrors
+		lastRequests, lastErrors = requests, errors
+		if n == 0 {
+			return false
+		}
+
+		rate := float64(failed) / float64(n)
+		mean, ok := avg.add(rate)
+		return ok && rate-mean > difference
+	}
+}
+
+// An exponential moving average of samples, weighted to cover about
+// trafficWindow of them. It starts as the plain average of the first
+// window, so that the first sample doesn't dominate.
+type movingAverage struct {
+	mean    float64
+	samples int
+}
+
+// Adds the sample, returning the average before it, and whether that
+// average covers enough samples to compare the sample with.
+func (m *movingAverage) add(sample float64) (float64, bool) {
+	mean, ok := m.mean, m.samples >= trafficWindow
+	if !ok {
+		m.mean += (sample - m.mean) / float64(m.samples+1)
+	} else {
+		m.mean += (sample - m.mean) * 2 / (trafficWindow + 1)
+	}
+
+	m.samples++
+	return mean, ok
+}
+
+// Records the status a handler responds with.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	wrote  bool
+}
+
+func (s *statusWriter) WriteHeader(status int) {
+	// Informational responses precede the final one.
+	if !s.wrote && status >= 200 {
+		s.status, s.wrote = status, true
+	}
+
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusWriter) Write(b []byte) (int, error) {
+	s.wrote = true
+	return s.ResponseWriter.Write(b)
+}
+
+// Lets http.ResponseController reach the underlying writer, so that
+// wrapped handlers can still flush and hijack.
+func (s *statusWriter) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
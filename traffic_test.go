@@ -0,0 +1,73 @@
+package profile
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrafficStatsCountsFailures(t *testing.T) {
+	stats := new(TrafficStats)
+	h := stats.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/fail":
+			w.WriteHeader(http.StatusBadGateway)
+		case "/panic":
+			panic("oops")
+		case "/missing":
+			http.NotFound(w, r)
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+
+	for _, path := range []string{"/", "/fail", "/missing", "/"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	assert.Panics(t, func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/panic", nil))
+	})
+
+	requests, errors := stats.Counts()
+	assert.EqualValues(t, 5, requests)
+	assert.EqualValues(t, 2, errors)
+}
+
+func TestRequestRateDeviates(t *testing.T) {
+	stats := new(TrafficStats)
+	cond := stats.RequestRateDeviates(0.5)
+	poll := func(requests int64) bool {
+		stats.requests += requests
+		return cond()
+	}
+
+	// The average isn't trusted until it covers a window of polls.
+	assert.False(t, poll(1000))
+	for i := 0; i < trafficWindow; i++ {
+		assert.False(t, poll(100+int64(i%2)*20))
+	}
+
+	assert.False(t, poll(140))
+	assert.True(t, poll(300))
+	assert.True(t, poll(10))
+}
+
+func TestErrorRateDeviates(t *testing.T) {
+	stats := new(TrafficStats)
+	cond := stats.ErrorRateDeviates(0.05)
+	poll := func(requests, errors int64) bool {
+		stats.requests += requests
+		stats.errors += errors
+		return cond()
+	}
+
+	for i := 0; i < trafficWindow; i++ {
+		assert.False(t, poll(100, 1))
+	}
+
+	assert.False(t, poll(0, 0))
+	assert.False(t, poll(100, 0))
+	assert.False(t, poll(100, 5))
+	assert.True(t, poll(100, 20))
+}
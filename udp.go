@@ -0,0 +1,71 @@
+package profile
+
+import (
+	"bytes"
+	"net"
+)
+
+// UdpTrigger is a trigger which runs an action when a UDP packet
+// containing a magic payload is received.
+type udpTrigger struct {
+	addr   string
+	magic  []byte
+	conn   net.PacketConn
+	closer chan struct{}
+	done   chan struct{}
+}
+
+func newUDPTrigger(addr string, magic []byte) *udpTrigger {
+	return &udpTrigger{addr: addr, magic: magic}
+}
+
+func (u *udpTrigger) String() string {
+	return "on UDP packet to " + u.addr
+}
+
+func (u *udpTrigger) clone() trigger {
+	return newUDPTrigger(u.addr, u.magic)
+}
+
+func (u *udpTrigger) end() {
+	close(u.closer)
+	u.conn.Close()
+	<-u.done
+}
+
+func (u *udpTrigger) start(fn func()) error {
+	conn, err := net.ListenPacket("udp", u.addr)
+	if err != nil {
+		return err
+	}
+
+	u.conn = conn
+	u.closer = make(chan struct{})
+	u.done = make(chan struct{})
+	supervise(u, u.closer, u.done, func() error { return u.run(fn) })
+
+	return nil
+}
+
+// Runs fn for each packet holding the magic payload, until the
+// trigger is ended, or reading fails for some other reason, which is
+// returned.
+func (u *udpTrigger) run(fn func()) error {
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := u.conn.ReadFrom(buf)
+		if err != nil {
+			if isClosed(u.closer) {
+				return nil
+			}
+
+			return err
+		}
+
+		// Trim so that payloads sent with tools like `echo | nc -u`,
+		// which append a newline, still match.
+		if bytes.Equal(bytes.TrimSpace(buf[:n]), u.magic) {
+			fn()
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package profile
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestUDPTriggerMatchesMagic(t *testing.T) {
+	u := newUDPTrigger("127.0.0.1:0", []byte("swat"))
+
+	var runs int32
+	assert.Nil(t, u.start(func() { atomic.AddInt32(&runs, 1) }))
+	defer u.end()
+
+	conn, err := net.Dial("udp", u.conn.LocalAddr().String())
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	conn.Write([]byte("swat\n"))
+	conn.Write([]byte("nope"))
+	conn.Write([]byte("swat"))
+	time.Sleep(50 * time.Millisecond)
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&runs))
+}
+
+func TestUDPTriggerStallsWhenReadingFails(t *testing.T) {
+	u := newUDPTrigger("127.0.0.1:0", []byte("swat"))
+	assert.Nil(t, u.start(func() {}))
+	defer setStalled(u, false)
+	assert.False(t, isStalled(u))
+
+	// Closing the connection behind the trigger's back is unexpected.
+	u.conn.Close()
+	assert.Eventually(t, func() bool { return isStalled(u) }, 5*time.Second, time.Millisecond)
+	u.end()
+}
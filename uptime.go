@@ -0,0 +1,94 @@
+package profile
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// When the process started, as near as the package can tell: when it
+// was initialized. It's a variable so that tests can age the process.
+var processStart = time.Now()
+
+// UptimeTrigger is a trigger which runs an action when the process
+// reaches each of a set of ages.
+type uptimeTrigger struct {
+	milestones []time.Duration
+	closer     chan struct{}
+	done       chan struct{}
+}
+
+func newUptimeTrigger(milestones []time.Duration) *uptimeTrigger {
+	sorted := append([]time.Duration(nil), milestones...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return &uptimeTrigger{milestones: sorted}
+}
+
+func (u *uptimeTrigger) String() string {
+	ages := make([]string, len(u.milestones))
+	for i, m := range u.milestones {
+		ages[i] = m.String()
+	}
+
+	return "at uptime " + strings.Join(ages, ", ")
+}
+
+func (u *uptimeTrigger) source() TriggerSource {
+	return SourceSchedule
+}
+
+func (u *uptimeTrigger) clone() trigger {
+	return newUptimeTrigger(u.milestones)
+}
+
+func (u *uptimeTrigger) end() {
+	close(u.closer)
+	<-u.done
+}
+
+func (u *uptimeTrigger) start(fn func()) error {
+	u.closer = make(chan struct{})
+	u.done = make(chan struct{})
+	supervise(u, u.closer, u.done, func() error { u.run(fn); return nil })
+
+	return nil
+}
+
+// Milestones which have passed are skipped, so that restarting the
+// action doesn't repeat them.
+func (u *uptimeTrigger) run(fn func()) {
+	for _, m := range u.milestones {
+		wait := time.Until(processStart.Add(m))
+		if wait < 0 {
+			continue
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-u.closer:
+			timer.Stop()
+			return
+		case <-timer.C:
+			fn()
+		}
+	}
+
+	<-u.closer
+}
+
+// `AtUptime` runs the action when the process reaches each of the
+// ages, such as 1h, 24h and 7d, giving comparable snapshots over its
+// lifetime for studying slow leaks. Ages are measured from when the
+// package was initialized, and those already passed when the action
+// starts are skipped.
+func (b *BaseAction) AtUptime(milestones ...time.Duration) *BaseAction {
+	for _, m := range milestones {
+		if b.lastErr == nil && m <= 0 {
+			b.lastErr = fmt.Errorf("%w: 'AtUptime' requires positive ages", ErrInvalidOption)
+		}
+	}
+
+	b.triggers = append(b.triggers, newUptimeTrigger(milestones))
+	return b
+}
@@ -0,0 +1,34 @@
+package profile
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAtUptimeFiresAtMilestones(t *testing.T) {
+	defer func(orig time.Time) { processStart = orig }(processStart)
+	processStart = time.Now().Add(-time.Hour)
+
+	var sources []TriggerSource
+	a, runs := newCountingAction()
+	a.OnSuccess(func(info RunInfo) { sources = append(sources, info.Source) })
+	a.AtUptime(time.Hour+30*time.Millisecond, 30*time.Minute, time.Hour+60*time.Millisecond, 24*time.Hour)
+	assert.Equal(t, "at uptime 30m0s, 1h0m0.03s, 1h0m0.06s, 24h0m0s", a.triggers[0].String())
+	assert.Nil(t, a.Start())
+	defer a.End()
+
+	// The milestone which passed before the action started is skipped.
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(runs) == 2 }, time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	assert.EqualValues(t, 2, atomic.LoadInt32(runs))
+	a.End()
+	assert.Equal(t, []TriggerSource{SourceSchedule, SourceSchedule}, sources)
+}
+
+func TestAtUptimeRequiresPositiveAges(t *testing.T) {
+	a, _ := newCountingAction()
+	assert.True(t, errors.Is(a.AtUptime(time.Hour, 0).Start(), ErrInvalidOption))
+}
@@ -9,7 +9,10 @@ func parallel(fns ...func()) {
 	wg := new(sync.WaitGroup)
 	for _, fn := range fns {
 		wg.Add(1)
-		go fn()
+		go func(fn func()) {
+			defer wg.Done()
+			fn()
+		}(fn)
 	}
 
 	wg.Wait()
@@ -0,0 +1,74 @@
+package profile
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Sends a state notification to systemd over the socket given by
+// NOTIFY_SOCKET, like sd_notify. Abstract socket names start with
+// "@", which the net package understands.
+func sdNotify(socket, state string) error {
+	if socket == "" {
+		return fmt.Errorf("%w: NOTIFY_SOCKET is not set", ErrUnsupported)
+	}
+
+	conn, err := net.Dial("unixgram", socket)
+	if err != nil {
+		return fmt.Errorf("connecting to systemd: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := io.WriteString(conn, state); err != nil {
+		return fmt.Errorf("notifying systemd: %w", err)
+	}
+
+	return nil
+}
+
+// Returns the watchdog interval systemd expects from WATCHDOG_USEC,
+// or zero if the watchdog isn't enabled for this process.
+func watchdogInterval() time.Duration {
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+
+	return time.Duration(usec) * time.Microsecond
+}
+
+// Returns an action that pets systemd's watchdog by sending
+// WATCHDOG=1 on each run, so that Swat's scheduler keeps the service
+// alive, with the same logging, stats, and failure handling as any
+// other action. If the service has WatchdogSec set, the action runs
+// at half the watchdog interval, as systemd recommends; otherwise,
+// give it a schedule with Every. Each run also writes "WATCHDOG=1" to
+// the output, which is nowhere by default.
+//
+// Runs fail with ErrUnsupported if the process wasn't started by
+// systemd with NOTIFY_SOCKET set.
+func NotifyWatchdog() *BaseAction {
+	b := NewAction(func(w io.Writer) error {
+		if err := sdNotify(os.Getenv("NOTIFY_SOCKET"), "WATCHDOG=1"); err != nil {
+			return err
+		}
+
+		_, err := io.WriteString(w, "WATCHDOG=1\n")
+		return err
+	}).Name("watchdog")
+
+	if interval := watchdogInterval(); interval > 0 {
+		b.Every(interval / 2)
+	}
+
+	b.ext = "txt"
+	return b
+}
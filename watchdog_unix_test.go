@@ -0,0 +1,40 @@
+//go:build unix
+// +build unix
+
+package profile
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNotifyWatchdog(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Skip("cannot listen on a datagram socket:", err)
+	}
+	defer conn.Close()
+
+	t.Setenv("NOTIFY_SOCKET", socket)
+	t.Setenv("WATCHDOG_USEC", "4000000")
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	a := NotifyWatchdog()
+	assert.Equal(t, 2*time.Second, a.every)
+	assert.Nil(t, a.Trigger(context.Background()))
+
+	buf := make([]byte, 64)
+	n, err := conn.Read(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "WATCHDOG=1", string(buf[:n]))
+
+	t.Setenv("NOTIFY_SOCKET", "")
+	assert.True(t, errors.Is(a.Trigger(context.Background()), ErrUnsupported))
+}
@@ -0,0 +1,19 @@
+//go:build !unix
+// +build !unix
+
+package profile
+
+import (
+	"fmt"
+	"os"
+)
+
+// Returns an error if the directory is read-only. Without access(2),
+// only its permission bits are checked.
+func canWrite(dir string, info os.FileInfo) error {
+	if info.Mode().Perm()&0200 == 0 {
+		return fmt.Errorf("%s isn't writable", dir)
+	}
+
+	return nil
+}
@@ -0,0 +1,23 @@
+//go:build unix
+// +build unix
+
+package profile
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// The mode access checks for, W_OK|X_OK, to create files in a
+// directory.
+const accessCreate = 0x2 | 0x1
+
+// Returns an error if the process can't create files in the directory.
+func canWrite(dir string, info os.FileInfo) error {
+	if err := syscall.Access(dir, accessCreate); err != nil {
+		return fmt.Errorf("%s isn't writable: %w", dir, err)
+	}
+
+	return nil
+}